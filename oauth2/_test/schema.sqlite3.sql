@@ -1,88 +0,0 @@
---
--- example schema
--- --------------
--- schema file for sqlite3 database
--- to be used in this example
---
-
---
--- example data
---
-DROP TABLE IF EXISTS posts;
-CREATE TABLE posts (
-  `id`    TEXT PRIMARY KEY,
-  `uid`   INTEGER,
-  `title` TEXT,
-  `body`  TEXT,
-  `size`  INTEGER,
-  `date`  INTEGER
-);
-
-DROP TABLE IF EXISTS comments;
-CREATE TABLE comments (
-  `id`      TEXT PRIMARY KEY,
-  `post_id` INTEGER,
-  `title`   TEXT,
-  `body`    TEXT,
-  `size`    INTEGER,
-  `date`    INTEGER
-);
-
-
---
--- example user table
---
-
-DROP TABLE IF EXISTS user;
-CREATE TABLE user (
-  `id`        TEXT PRIMARY KEY,
-  `username`  TEXT,
-  `email`     TEXT,
-  `password`  TEXT,
-  `name`      TEXT,
-  `meta_json` TEXT,
-  `token`     TEXT,
-  `created`   INTEGER,
-  `updated`   INTEGER
-);
-
-
---
--- OAuth2 related tables
---
-DROP TABLE IF EXISTS oauth2_client;
-CREATE TABLE oauth2_client (
-  `id`           TEXT PRIMARY KEY,
-  `secret`       TEXT,
-  `redirect_uri` TEXT,
-  `user_id`      TEXT
-);
-
-DROP TABLE IF EXISTS oauth2_auth;
-CREATE TABLE oauth2_auth (
-  `id`           TEXT PRIMARY KEY,
-  `client_id`    TEXT,
-  `code`         TEXT,
-  `expires_in`   INTEGER,
-  `scope`        TEXT,
-  `redirect_uri` TEXT,
-  `state`        TEXT,
-  `created_at`   INTEGER,
-  `user_id`      TEXT
-);
-
-DROP TABLE IF EXISTS oauth2_access;
-CREATE TABLE oauth2_access (
-  `id`                TEXT PRIMARY KEY,
-  `client_id`         TEXT,
-  `auth_data_json`    TEXT,
-  `access_token`      TEXT,
-  `refresh_token`     TEXT,
-  `access_data_json`  TEXT,
-  `expires_in`        INTEGER,
-  `scope`             TEXT,
-  `redirect_uri`      TEXT,
-  `state`             TEXT,
-  `created_at`        INTEGER,
-  `user_id`           TEXT
-);
@@ -1,12 +1,13 @@
 package oauth2_test
 
 import (
-	"bytes"
 	"log"
 	"os"
-	"os/exec"
+	"path/filepath"
 
+	"github.com/gourd/kit/oauth2"
 	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/migrate"
 	"github.com/gourd/kit/store/upperio"
 	"upper.io/db/sqlite"
 )
@@ -15,33 +16,15 @@ const dbpath = "_test/sqlite3.db"
 
 func init() {
 
-	// find sqlite3 command
-	sqlite3, err := exec.LookPath("sqlite3")
-	if err != nil {
-		log.Fatalf("error finding sqlite3 in system: %#v", err.Error())
+	// initialize test database with migrations
+	if err := os.MkdirAll(filepath.Dir(dbpath), 0755); err != nil {
+		log.Fatalf("error creating test directory: %#v", err.Error())
 	}
-	log.Printf("sqlite3 path: %#v", sqlite3)
-
-	// open the schema file
-	file, err := os.Open("_test/schema.sqlite3.sql")
-	if err != nil {
-		log.Fatalf("error opening test schema: %#v", err.Error())
+	if err := os.Remove(dbpath); err != nil && !os.IsNotExist(err) {
+		log.Fatalf("error removing test database: %#v", err.Error())
 	}
-
-	// initialize test database with sql file
-	cmd := exec.Command(sqlite3, dbpath)
-	var outstd bytes.Buffer
-	var outerr bytes.Buffer
-
-	cmd.Stdin = file
-	cmd.Stdout = &outstd
-	cmd.Stderr = &outerr
-
-	if err := cmd.Run(); err != nil {
-		log.Printf("output: %#v", outstd.String())
-		log.Printf("error:  %#v", outerr.String())
-
-		log.Fatalf("Failed to run sqlite command")
+	if _, err := migrate.New(defaultTestSrc(), oauth2.Migrations()...).Up(); err != nil {
+		log.Fatalf("error migrating test database: %#v", err.Error())
 	}
 }
 
@@ -0,0 +1,82 @@
+package oauth2
+
+import (
+	"database/sql"
+
+	"github.com/gourd/kit/store/migrate"
+)
+
+// Migrations returns the migrations of the tables of the user,
+// client, authorize data and access data stores, in SQL of
+// SQLite and MySQL (see migrate.New)
+func Migrations() []migrate.Migration {
+	return []migrate.Migration{
+		migrate.SQL(1, "create_user", `
+			CREATE TABLE user (
+				id         VARCHAR(255) PRIMARY KEY,
+				username   TEXT,
+				email      TEXT,
+				password   TEXT,
+				name       TEXT,
+				meta_json  TEXT,
+				token      TEXT,
+				created    BIGINT,
+				updated    BIGINT,
+				version    BIGINT DEFAULT 0,
+				deleted_at BIGINT
+			)`,
+			`DROP TABLE user`),
+		migrate.SQL(2, "create_oauth2_client", `
+			CREATE TABLE oauth2_client (
+				id           VARCHAR(255) PRIMARY KEY,
+				secret       TEXT,
+				redirect_uri TEXT,
+				user_id      TEXT
+			)`,
+			`DROP TABLE oauth2_client`),
+		migrate.SQL(3, "create_oauth2_auth", `
+			CREATE TABLE oauth2_auth (
+				id           VARCHAR(255) PRIMARY KEY,
+				client_id    TEXT,
+				code         TEXT,
+				expires_in   BIGINT,
+				scope        TEXT,
+				redirect_uri TEXT,
+				state        TEXT,
+				created_at   BIGINT,
+				user_id      TEXT
+			)`,
+			`DROP TABLE oauth2_auth`),
+		{
+			Version: 4,
+			Name:    "create_oauth2_access",
+			Up: func(tx *sql.Tx) (err error) {
+				if _, err = tx.Exec(`
+					CREATE TABLE oauth2_access (
+						id               VARCHAR(255) PRIMARY KEY,
+						client_id        TEXT,
+						auth_data_json   TEXT,
+						access_token     VARCHAR(255),
+						refresh_token    TEXT,
+						access_data_json TEXT,
+						expires_in       BIGINT,
+						scope            TEXT,
+						redirect_uri     TEXT,
+						state            TEXT,
+						created_at       BIGINT,
+						user_id          TEXT
+					)`); err != nil {
+					return
+				}
+				_, err = tx.Exec(`
+					CREATE UNIQUE INDEX oauth2_access_access_token
+					ON oauth2_access (access_token)`)
+				return
+			},
+			Down: func(tx *sql.Tx) (err error) {
+				_, err = tx.Exec(`DROP TABLE oauth2_access`)
+				return
+			},
+		},
+	}
+}
@@ -263,8 +263,9 @@ func (storage *Storage) SaveAccess(ad *osin.AccessData) (err error) {
 		e.AccessDataJSON = string(b)
 	}
 
-	// create in database
-	if err = srv.Create(store.NewConds(), e); err != nil {
+	// create, or update if the access token exists, in database
+	cond := store.NewConds().Add("access_token", e.AccessToken)
+	if err = store.Upsert(srv, cond, e); err != nil {
 		serr := store.ExpandError(err)
 		errLogger.Log(
 			"method", "SaveAccess",
@@ -0,0 +1,37 @@
+package oauth2
+
+import (
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/upperio"
+)
+
+var (
+	userStoreProvider          = upperio.NewStore("user", User{})
+	clientStoreProvider        = upperio.NewStore("oauth2_client", Client{})
+	accessDataStoreProvider    = upperio.NewStore("oauth2_access", AccessData{})
+	authorizeDataStoreProvider = upperio.NewStore("oauth2_auth", AuthorizeData{})
+)
+
+// UserStoreProvider implements store.Provider interface
+// provides upperio.Store of User
+func UserStoreProvider(sess interface{}) (store.Store, error) {
+	return userStoreProvider(sess)
+}
+
+// ClientStoreProvider implements store.Provider interface
+// provides upperio.Store of Client
+func ClientStoreProvider(sess interface{}) (store.Store, error) {
+	return clientStoreProvider(sess)
+}
+
+// AccessDataStoreProvider implements store.Provider interface
+// provides upperio.Store of AccessData
+func AccessDataStoreProvider(sess interface{}) (store.Store, error) {
+	return accessDataStoreProvider(sess)
+}
+
+// AuthorizeDataStoreProvider implements store.Provider interface
+// provides upperio.Store of AuthorizeData
+func AuthorizeDataStoreProvider(sess interface{}) (store.Store, error) {
+	return authorizeDataStoreProvider(sess)
+}
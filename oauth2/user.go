@@ -23,14 +23,21 @@ type OAuth2User interface {
 // User of the API server
 type User struct {
 	ID       string    `db:"id,omitempty" json:"id"`
-	Username string    `db:"username" json:"username"`
-	Email    string    `db:"email" json:"email"`
+	Username string    `db:"username" json:"username" validate:"required,max=255"`
+	Email    string    `db:"email" json:"email" validate:"email,max=255"`
 	Password string    `db:"password,omitempty" json:"-"`
 	Name     string    `db:"name" json:"name"`
 	MetaJSON string    `db:"meta_json" json:"-"`
 	Token    string    `db:"token" json:"-"` // token for lost password request
 	Created  time.Time `db:"created" json:"created"`
 	Updated  time.Time `db:"updated" json:"updated"`
+
+	// Version is incremented on every update, for
+	// optimistic concurrency control
+	Version int64 `db:"version" json:"-" gourdupdate:"version"`
+
+	// DeletedAt is the time of soft deletion, nil if not deleted
+	DeletedAt *time.Time `db:"deleted_at,omitempty" json:"-" gourddelete:"soft"`
 }
 
 // userJSON is the struct for marshaling and unmarshaling
@@ -121,6 +128,7 @@ func (u User) MarshalDB() (v interface{}, err error) {
 	vmap["token"] = u.Token
 	vmap["created"] = u.Created
 	vmap["updated"] = u.Updated
+	vmap["version"] = u.Version
 
 	if u.MetaJSON == "" {
 		vmap["meta_json"] = "{}"
@@ -11,8 +11,6 @@ import (
 	httpservice "github.com/gourd/kit/service/http"
 	"github.com/gourd/kit/store"
 	"golang.org/x/net/context"
-
-	"fmt"
 )
 
 // UserStoreEndpoints return CURD endpoints for UserStore
@@ -21,18 +19,19 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 	// variables to use later
 	allocEntityList := func() *[]User { return &[]User{} }
 	storeKey := KeyUser
-	getStore := func(ctx context.Context) (s *UserStore, err error) {
-		raw, err := store.Get(ctx, storeKey)
-		if err != nil {
-			return
-		}
+	getStore := func(ctx context.Context) (s store.Store, err error) {
+		return store.Get(ctx, storeKey)
+	}
 
-		s, ok := raw.(*UserStore)
-		if !ok {
-			err = fmt.Errorf("store.Get(\"UserStore\") does not return *UserStore")
-			return
+	// error of store operations, with status and type of
+	// the StoreError (e.g. 404 or hook veto) kept as is. Other
+	// errors are internal, with the message kept to the server
+	storeError := func(err error, msg string, v ...interface{}) error {
+		// ExpandError wraps errors other than StoreError as cause
+		if serr := store.ExpandError(err); serr.Cause != err {
+			return serr.New().TellServer(msg, v...)
 		}
-		return
+		return store.ErrorInternal.Wrap(err).TellServer(msg, v...)
 	}
 
 	// store endpoints here
@@ -44,18 +43,15 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 		// get context information
 		r := gourdctx.HTTPRequest(ctx)
 		if r == nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = "missing request in context"
-			err = serr
+			err = store.ErrorInternal.TellServer("missing request in context")
 			return
 		}
 
 		// get store
 		s, err := getStore(ctx)
 		if err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf("error obtaining %s store (%s)", storeKey, err)
-			err = serr
+			err = storeError(err,
+				"error obtaining %v store (%s)", storeKey, err)
 			return
 		}
 		defer s.Close()
@@ -63,10 +59,8 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 		// create entity
 		err = s.Create(nil, e)
 		if err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf(
+			err = storeError(err,
 				"error creating %s: %#v, entity: %#v", noun, err.Error(), e)
-			err = serr
 			return
 		}
 
@@ -86,18 +80,15 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 		// get context information
 		r := gourdctx.HTTPRequest(ctx)
 		if r == nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = "missing request in context"
-			err = serr
+			err = store.ErrorInternal.TellServer("missing request in context")
 			return
 		}
 
 		// get store
 		s, err := getStore(ctx)
 		if err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf("error obtaining %s store (%s)", storeKey, err)
-			err = serr
+			err = storeError(err,
+				"error obtaining %v store (%s)", storeKey, err)
 			return
 		}
 		defer s.Close()
@@ -108,10 +99,8 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 		// retrieve
 		err = s.Search(q).All(el)
 		if err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf("error searching %s: %s",
-				noun, err)
-			err = serr
+			err = storeError(err,
+				"error searching %s: %s", noun, err)
 			return
 		}
 
@@ -120,6 +109,7 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 			err = store.ErrorNotFound
 			return
 		}
+		httpservice.SetETag(ctx, &(*el)[0])
 
 		res = map[string]interface{}{
 			nounp: el,
@@ -136,18 +126,15 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 		// get context information
 		r := gourdctx.HTTPRequest(ctx)
 		if r == nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = "missing request in context"
-			err = serr
+			err = store.ErrorInternal.TellServer("missing request in context")
 			return
 		}
 
 		// get store
 		s, err := getStore(ctx)
 		if err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf("error obtaining %s store (%s)", storeKey, err)
-			err = serr
+			err = storeError(err,
+				"error obtaining %v store (%s)", storeKey, err)
 			return
 		}
 		defer s.Close()
@@ -158,19 +145,15 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 		results := s.Search(q)
 		count, err := results.Count()
 		if err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf("error counting %s: %s",
-				noun, err)
-			err = serr
+			err = storeError(err,
+				"error counting %s: %s", noun, err)
 			return
 		}
 
 		err = results.All(el)
 		if err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf("error searching %s: %s",
-				noun, err)
-			err = serr
+			err = storeError(err,
+				"error searching %s: %s", noun, err)
 			return
 		}
 
@@ -179,7 +162,8 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 			nounp: el,
 			"paging": store.NewPager().
 				SetTotal(int(count)).
-				SetLimit(int(q.GetLimit()), int(q.GetOffset())),
+				SetLimit(int(q.GetLimit()), int(q.GetOffset())).
+				SetCursors(store.Cursors(q, el)),
 		}
 		return
 	}
@@ -194,32 +178,28 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 		// get context information
 		r := gourdctx.HTTPRequest(ctx)
 		if r == nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = "missing request in context"
-			err = serr
+			err = store.ErrorInternal.TellServer("missing request in context")
 			return
 		}
 
 		// get store
 		s, err := getStore(ctx)
 		if err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf("error obtaining %s store (%s)", storeKey, err)
-			err = serr
+			err = storeError(err,
+				"error obtaining %v store (%s)", storeKey, err)
 			return
 		}
 		defer s.Close()
 
-		// update entity
+		// update entity, report version conflict as is
 		if err = s.Update(cond, e); err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf(
-				"error encoding %s list: %s",
-				noun, err)
-			err = serr
+			err = storeError(err,
+				"error updating %s: %s", noun, err)
 			return
 		}
 
+		httpservice.SetETag(ctx, e)
+
 		res = map[string]interface{}{
 			nounp: &[]User{*e.(*User)},
 		}
@@ -239,18 +219,15 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 		// get context information
 		r := gourdctx.HTTPRequest(ctx)
 		if r == nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = "missing request in context"
-			err = serr
+			err = store.ErrorInternal.TellServer("missing request in context")
 			return
 		}
 
 		// get store
 		s, err := getStore(ctx)
 		if err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf("error obtaining %s store (%s)", storeKey, err)
-			err = serr
+			err = storeError(err,
+				"error obtaining %v store (%s)", storeKey, err)
 			return
 		}
 		defer s.Close()
@@ -258,20 +235,15 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 		// find the content of the id
 		err = s.Search(q).All(el)
 		if err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf("error searching %s: %s",
-				noun, err)
-			err = serr
+			err = storeError(err,
+				"error searching %s: %s", noun, err)
 			return
 		}
 
 		// delete entity
 		if err = s.Delete(cond); err != nil {
-			serr := store.ErrorInternal
-			serr.ServerMsg = fmt.Sprintf(
-				"error encoding %s list: %s",
-				noun, err)
-			err = serr
+			err = storeError(err,
+				"error deleting %s: %s", noun, err)
 			return
 		}
 
@@ -281,5 +253,92 @@ func UserStoreEndpoints(noun, nounp string) (endpoints map[string]endpoint.Endpo
 		return
 	}
 
+	endpoints["createMany"] = func(ctx context.Context, request interface{}) (res interface{}, err error) {
+
+		el := request.(*[]User)
+
+		// get store
+		s, err := getStore(ctx)
+		if err != nil {
+			err = storeError(err,
+				"error obtaining %v store (%s)", storeKey, err)
+			return
+		}
+		defer s.Close()
+
+		// create entities, report failed items along with the list
+		vmap := map[string]interface{}{
+			nounp: el,
+		}
+		if err = store.CreateMany(s, nil, el); err != nil {
+			berr, ok := err.(store.BulkError)
+			if !ok {
+				err = storeError(err,
+					"error creating %s: %s", nounp, err)
+				return
+			}
+			vmap["errors"], err = berr, nil
+		}
+
+		res = vmap
+		return
+	}
+
+	endpoints["updateMany"] = func(ctx context.Context, request interface{}) (res interface{}, err error) {
+
+		sReq := request.(*httpservice.Request)
+		cond := sReq.Query.GetConds()
+		fields := sReq.Payload.(map[string]interface{})
+
+		// get store
+		s, err := getStore(ctx)
+		if err != nil {
+			err = storeError(err,
+				"error obtaining %v store (%s)", storeKey, err)
+			return
+		}
+		defer s.Close()
+
+		// update entities, report failed items if any
+		vmap := map[string]interface{}{}
+		if err = store.UpdateMany(s, cond, fields); err != nil {
+			berr, ok := err.(store.BulkError)
+			if !ok {
+				err = storeError(err,
+					"error updating %s: %s", nounp, err)
+				return
+			}
+			vmap["errors"], err = berr, nil
+		}
+
+		res = vmap
+		return
+	}
+
+	endpoints["deleteMany"] = func(ctx context.Context, request interface{}) (res interface{}, err error) {
+
+		sReq := request.(*httpservice.Request)
+		ids := sReq.Payload.([]interface{})
+
+		// get store
+		s, err := getStore(ctx)
+		if err != nil {
+			err = storeError(err,
+				"error obtaining %v store (%s)", storeKey, err)
+			return
+		}
+		defer s.Close()
+
+		// delete entities
+		if err = store.DeleteMany(s, ids); err != nil {
+			err = storeError(err,
+				"error deleting %s: %s", nounp, err)
+			return
+		}
+
+		res = map[string]interface{}{}
+		return
+	}
+
 	return
 }
@@ -15,11 +15,9 @@ import (
 	"golang.org/x/net/context"
 
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
-	"strconv"
-	"strings"
+	"time"
 )
 
 func UserStoreServices(paths httpservice.Paths, endpoints map[string]endpoint.Endpoint) (handlers httpservice.Services) {
@@ -27,18 +25,19 @@ func UserStoreServices(paths httpservice.Paths, endpoints map[string]endpoint.En
 	// variables to use later
 	noun := paths.Noun()
 	storeKey := KeyUser
-	getStore := func(ctx context.Context) (s *UserStore, err error) {
-		raw, err := store.Get(ctx, storeKey)
-		if err != nil {
-			return
-		}
+	getStore := func(ctx context.Context) (s store.Store, err error) {
+		return store.Get(ctx, storeKey)
+	}
 
-		s, ok := raw.(*UserStore)
-		if !ok {
-			err = fmt.Errorf(`store.Get(KeyUser) does not return *KeyUser`)
-			return
+	// error of store operations, with status and type of
+	// the StoreError (e.g. 404 or hook veto) kept as is. Other
+	// errors are internal, with the message kept to the server
+	storeError := func(err error, msg string, v ...interface{}) error {
+		// ExpandError wraps errors other than StoreError as cause
+		if serr := store.ExpandError(err); serr.Cause != err {
+			return serr.New().TellServer(msg, v...)
 		}
-		return
+		return store.ErrorInternal.Wrap(err).TellServer(msg, v...)
 	}
 
 	// define default middlewares
@@ -46,7 +45,40 @@ func UserStoreServices(paths httpservice.Paths, endpoints map[string]endpoint.En
 		return func(ctx context.Context, request interface{}) (respond interface{}, err error) {
 			// placeholder: anything you want to do with the entity
 			//              before append to database
+			request.(*User).ID = "" // id is generated by the store
 			httpservice.EnforceCreate(request)
+			if err = httpservice.Validate(request); err != nil {
+				return
+			}
+			return inner(ctx, request)
+		}
+	}
+
+	var prepareCreateMany endpoint.Middleware = func(inner endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (respond interface{}, err error) {
+			// placeholder: anything you want to do with the entities
+			//              before append to database
+			el := request.(*[]User)
+			for i := range *el {
+				(*el)[i].ID = "" // id is generated by the store
+				httpservice.EnforceCreate(&(*el)[i])
+			}
+			if err = httpservice.Validate(el); err != nil {
+				return
+			}
+			return inner(ctx, request)
+		}
+	}
+
+	var prepareUpdateMany endpoint.Middleware = func(inner endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (respond interface{}, err error) {
+			// placeholder: anything you want to do with the fields
+			//              before update to database
+			sReq := request.(*httpservice.Request)
+			fields := sReq.Payload.(map[string]interface{})
+			if err = httpservice.ValidateFields(&User{}, fields); err != nil {
+				return
+			}
 			return inner(ctx, request)
 		}
 	}
@@ -59,9 +91,7 @@ func UserStoreServices(paths httpservice.Paths, endpoints map[string]endpoint.En
 			// get context information
 			r := gourdctx.HTTPRequest(ctx)
 			if r == nil {
-				serr := store.ErrorInternal
-				serr.ServerMsg = "missing request in context"
-				err = serr
+				err = store.ErrorInternal.TellServer("missing request in context")
 				return
 			}
 
@@ -71,9 +101,8 @@ func UserStoreServices(paths httpservice.Paths, endpoints map[string]endpoint.En
 			// get store
 			s, err := getStore(ctx)
 			if err != nil {
-				serr := store.ErrorInternal
-				serr.ServerMsg = fmt.Sprintf("error obtaining %s store (%s)", storeKey, err)
-				err = serr
+				err = storeError(err,
+					"error obtaining %v store (%s)", storeKey, err)
 				return
 			}
 			defer s.Close()
@@ -81,10 +110,8 @@ func UserStoreServices(paths httpservice.Paths, endpoints map[string]endpoint.En
 			// find the previous content of the id
 			err = s.Search(q).All(el)
 			if err != nil {
-				serr := store.ErrorInternal
-				serr.ServerMsg = fmt.Sprintf("error searching %s: %s",
-					noun.Singular(), err)
-				err = serr
+				err = storeError(err,
+					"error searching %s: %s", noun.Singular(), err)
 				return
 			}
 
@@ -96,6 +123,16 @@ func UserStoreServices(paths httpservice.Paths, endpoints map[string]endpoint.En
 			// enforce agreement on sReq.Payload with previous sReq.Entity
 			httpservice.EnforceUpdate(sReq.Previous, sReq.Payload)
 
+			// update only the version in If-Match header, if any
+			if err = httpservice.EnforceVersion(r, sReq.Payload); err != nil {
+				return
+			}
+
+			// validate the payload as updated
+			if err = httpservice.Validate(sReq.Payload); err != nil {
+				return
+			}
+
 			// placeholder: anything you want to do with the entity
 			//              before update to database
 			return inner(ctx, sReq)
@@ -214,46 +251,12 @@ func UserStoreServices(paths httpservice.Paths, endpoints map[string]endpoint.En
 	}
 
 	// decodeListReq decode query for list endpoint
-	var decodeListReq httptransport.DecodeRequestFunc = func(ctx context.Context, r *http.Request) (request interface{}, err error) {
-
-		sReq := &httpservice.Request{
-			Request: r,
-			Query:   store.NewQuery(),
-		}
-
-		// parse sort parameter
-		sortStr := r.FormValue("sorts")
-		if sortStr != "" {
-			sorts := strings.Split(sortStr, ",")
-			for _, sort := range sorts {
-				sReq.Query.Sort(sort)
-			}
-		}
-
-		// parse paging request parameter
-		offset, limit := func(r *http.Request) (o, l uint64) {
-			ostr := r.FormValue("offset")
-			lstr := r.FormValue("limit")
-			if ostr != "" {
-				if ot, err := strconv.ParseUint(ostr, 10, 64); err == nil {
-					o = ot
-				}
-			}
-			if lstr != "" {
-				if lt, err := strconv.ParseUint(lstr, 10, 64); err == nil {
-					l = lt
-				}
-			}
-			return
-		}(r)
-
-		// retrieve
-		sReq.Query.SetOffset(offset)
-		sReq.Query.SetLimit(limit)
-
-		request = sReq
-		return
-	}
+	var decodeListReq = httpservice.DecodeListFunc(&store.QueryOptions{
+		Filters: []string{"id", "username", "email", "name", "created", "updated"},
+		Sorts:   []string{"id", "username", "email", "name", "created", "updated"},
+		Fields:  []string{"id", "username", "email", "name", "created", "updated"},
+		Entity:  User{},
+	})
 
 	// decodeJSONReq returns a DecodeRequestFunc that decode request
 	// into allocated memory structure
@@ -278,6 +281,67 @@ func UserStoreServices(paths httpservice.Paths, endpoints map[string]endpoint.En
 		return
 	}
 
+	// decodeCreateMany decodes a JSON array of entities
+	var decodeCreateMany httptransport.DecodeRequestFunc = func(ctx context.Context, r *http.Request) (request interface{}, err error) {
+		el := &[]User{}
+		if err = json.NewDecoder(r.Body).Decode(el); err != nil {
+			err = store.Error(http.StatusBadRequest, "invalid request body").
+				TellServer("%s", err)
+			return
+		}
+		request = el
+		return
+	}
+
+	// decodeUpdateMany decodes filters of the entities to update
+	// from the query string, and the fields to set from the JSON body
+	var decodeUpdateMany httptransport.DecodeRequestFunc = func(ctx context.Context, r *http.Request) (request interface{}, err error) {
+		raw, err := decodeListReq(ctx, r)
+		if err != nil {
+			return
+		}
+		sReq := raw.(*httpservice.Request)
+
+		fields := make(map[string]interface{})
+		if err = json.NewDecoder(r.Body).Decode(&fields); err != nil {
+			err = store.Error(http.StatusBadRequest, "invalid request body").
+				TellServer("%s", err)
+			return
+		}
+		for name := range fields {
+			switch name {
+			case "username", "email", "name":
+			default:
+				err = store.Error(http.StatusBadRequest,
+					"cannot update field %#v", name)
+				return
+			}
+		}
+		fields["updated"] = time.Now()
+		sReq.Payload = fields
+
+		request = sReq
+		return
+	}
+
+	// decodeDeleteMany decodes the ids of entities to delete
+	// from JSON body like {"ids": ["id1", "id2"]}
+	var decodeDeleteMany httptransport.DecodeRequestFunc = func(ctx context.Context, r *http.Request) (request interface{}, err error) {
+		var body struct {
+			IDs []interface{} `json:"ids"`
+		}
+		if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+			err = store.Error(http.StatusBadRequest, "invalid request body").
+				TellServer("%s", err)
+			return
+		}
+		request = &httpservice.Request{
+			Request: r,
+			Payload: body.IDs,
+		}
+		return
+	}
+
 	//
 	// ==== httpservce.Services
 	//
@@ -331,6 +395,32 @@ func UserStoreServices(paths httpservice.Paths, endpoints map[string]endpoint.En
 	handlers["delete"].Middlewares.Add(httpservice.MWInner,
 		checkPermBefore("delete "+noun.Singular()))
 
+	handlers["createMany"] = httpservice.NewJSONService(
+		paths.Plural()+"/bulk", endpoints["createMany"])
+	handlers["createMany"].Methods = []string{"POST"}
+	handlers["createMany"].DecodeFunc = decodeCreateMany
+	handlers["createMany"].Middlewares.Add(httpservice.MWProtocol, prepareProtocol)
+	handlers["createMany"].Middlewares.Add(httpservice.MWPrepare, prepareCreateMany)
+	handlers["createMany"].Middlewares.Add(httpservice.MWInner,
+		checkPermBefore("create "+noun.Singular()))
+
+	handlers["updateMany"] = httpservice.NewJSONService(
+		paths.Plural()+"/bulk", endpoints["updateMany"])
+	handlers["updateMany"].Methods = []string{"PATCH"}
+	handlers["updateMany"].DecodeFunc = decodeUpdateMany
+	handlers["updateMany"].Middlewares.Add(httpservice.MWProtocol, prepareProtocol)
+	handlers["updateMany"].Middlewares.Add(httpservice.MWPrepare, prepareUpdateMany)
+	handlers["updateMany"].Middlewares.Add(httpservice.MWInner,
+		checkPermBefore("update "+noun.Singular()))
+
+	handlers["deleteMany"] = httpservice.NewJSONService(
+		paths.Plural()+"/bulk", endpoints["deleteMany"])
+	handlers["deleteMany"].Methods = []string{"DELETE"}
+	handlers["deleteMany"].DecodeFunc = decodeDeleteMany
+	handlers["deleteMany"].Middlewares.Add(httpservice.MWProtocol, prepareProtocol)
+	handlers["deleteMany"].Middlewares.Add(httpservice.MWInner,
+		checkPermBefore("delete "+noun.Singular()))
+
 	return
 }
 
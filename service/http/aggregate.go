@@ -0,0 +1,59 @@
+package httpservice
+
+import (
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
+)
+
+// AggregateEndpoint returns an endpoint that aggregates the entities
+// of the store of the given key by the Query of *Request (see
+// store.Result.Aggregate). The response would be in the form of
+// {"rows": [...]}.
+//
+// The context should have a store.Factory (e.g. with
+// store.Middleware) to get the store from
+func AggregateEndpoint(key interface{}) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		sReq, ok := request.(*Request)
+		if !ok || sReq.Query == nil {
+			err = store.Error(http.StatusInternalServerError,
+				http.StatusText(http.StatusInternalServerError)).
+				TellServer("expected *httpservice.Request with Query, got %#v", request)
+			return
+		}
+
+		s, err := store.Get(ctx, key)
+		if err != nil {
+			return
+		}
+
+		res := s.Search(sReq.Query)
+		defer res.Close()
+		rows, err := res.Aggregate()
+		if err != nil {
+			return
+		}
+		response = map[string]interface{}{
+			"rows": rows,
+		}
+		return
+	}
+}
+
+// NewAggregateService creates a JSON service descriptor that
+// aggregates entities of the store of the given key. Aggregation
+// is parsed from the URL query with the options (see
+// store.ParseQuery), e.g.
+//
+//	GET /users/stats?group=status&aggregate=count,max(created)
+//
+// A store.Factory should be provided to the endpoint context with
+// middleware (e.g. store.Middleware)
+func NewAggregateService(path string, key interface{}, opts *store.QueryOptions) *Service {
+	s := NewJSONService(path, AggregateEndpoint(key))
+	s.DecodeFunc = DecodeListFunc(opts)
+	return s
+}
@@ -0,0 +1,79 @@
+package httpservice_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	httpservice "github.com/gourd/kit/service/http"
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+	"golang.org/x/net/context"
+)
+
+type aggTestEntity struct {
+	ID     string `db:"id"`
+	Status string `db:"status"`
+	Amount int    `db:"amount"`
+}
+
+func TestNewAggregateService(t *testing.T) {
+
+	type tempKey int
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	factory := store.NewFactory()
+	factory.SetSource(srcKey, memstore.NewSource())
+	factory.Set(key, srcKey, memstore.Provider("agg", aggTestEntity{}))
+
+	// prepare data
+	ctx := store.WithFactory(context.Background(), factory)
+	s, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	for _, e := range []aggTestEntity{
+		{Status: "open", Amount: 1},
+		{Status: "closed", Amount: 2},
+		{Status: "open", Amount: 3},
+	} {
+		if err := s.Create(nil, &e); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+	}
+	store.CloseAllIn(ctx)
+
+	svc := httpservice.NewAggregateService("/stats", key, &store.QueryOptions{
+		Aggregates: []string{"status", "amount"},
+	})
+	svc.Middlewares.Add(httpservice.MWOuter, store.Middleware(factory))
+
+	r, _ := http.NewRequest("GET", "/stats?group=status&aggregate=count,sum(amount)&sort=status",
+		strings.NewReader(""))
+	w := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(w, r)
+
+	var resp struct {
+		Rows []map[string]interface{} `json:"rows"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %#v", err.Error())
+	}
+	if want, have := 2, len(resp.Rows); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "open", resp.Rows[1]["status"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := float64(2), resp.Rows[1]["count"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := float64(4), resp.Rows[1]["sum_amount"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
@@ -8,7 +8,8 @@ import (
 )
 
 // EnforceCreate enforce some field on creation,
-// as defined in struct field tags
+// as defined in struct field tags. Soft delete field
+// (tagged `gourddelete:"soft"`) is cleared
 func EnforceCreate(payload interface{}) (err error) {
 
 	// find payload pointer
@@ -34,6 +35,13 @@ func EnforceCreate(payload interface{}) (err error) {
 
 	for i := 0; i < typ.NumField(); i++ {
 		field := typ.Field(i)
+
+		// soft delete field is not to be set on creation
+		if field.Tag.Get("gourddelete") == "soft" {
+			val.Field(i).Set(reflect.Zero(field.Type))
+			continue
+		}
+
 		createTag := field.Tag.Get("gourdcreate")
 		if createTag == "" {
 			continue // skip all fields without gourdcreate tag
@@ -58,7 +66,8 @@ func EnforceCreate(payload interface{}) (err error) {
 }
 
 // EnforceUpdate enforce some fields value on update,
-// as defined in struct field tags
+// as defined in struct field tags. Soft delete field
+// (tagged `gourddelete:"soft"`) is preserved
 func EnforceUpdate(original, update interface{}) (err error) {
 
 	// find update pointer, value and type
@@ -102,6 +111,13 @@ func EnforceUpdate(original, update interface{}) (err error) {
 
 	for i := 0; i < typ.NumField(); i++ {
 		field := typ.Field(i)
+
+		// soft delete field is only changed by delete and restore
+		if field.Tag.Get("gourddelete") == "soft" {
+			val.Field(i).Set(oval.Field(i))
+			continue
+		}
+
 		updateTag := field.Tag.Get("gourdupdate")
 		if updateTag == "" {
 			continue // skip all fields without gourdcreate tag
@@ -111,6 +127,12 @@ func EnforceUpdate(original, update interface{}) (err error) {
 		if tagContents[0] == "preserve" {
 			currentValue := oval.Field(i)
 			val.Field(i).Set(currentValue)
+		} else if tagContents[0] == "version" {
+			// update based on the original version, if not specified
+			fieldVal := val.Field(i)
+			if fieldVal.Interface() == reflect.Zero(field.Type).Interface() {
+				fieldVal.Set(oval.Field(i))
+			}
 		} else if tagContents[0] == "now" {
 			fieldVal := val.Field(i)
 			if field.Type.PkgPath() == "time" && field.Type.Name() == "Time" {
@@ -108,3 +108,53 @@ func TestEnforceUpdate(t *testing.T) {
 		t.Errorf("Published: expected %s, got %s", want, have)
 	}
 }
+
+func TestEnforce_softDelete(t *testing.T) {
+	type myType struct {
+		ID        string     `json:"id,omitempty"`
+		DeletedAt *time.Time `json:"deleted_at" gourddelete:"soft"`
+	}
+
+	deleted := time.Unix(0, 0)
+
+	payload := &myType{DeletedAt: &deleted}
+	if err := httpservice.EnforceCreate(payload); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if payload.DeletedAt != nil {
+		t.Errorf("DeletedAt: expected nil, got %#v", payload.DeletedAt)
+	}
+
+	original, update := &myType{DeletedAt: &deleted}, &myType{}
+	if err := httpservice.EnforceUpdate(original, update); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if want, have := original.DeletedAt, update.DeletedAt; want != have {
+		t.Errorf("DeletedAt: expected %#v, got %#v", want, have)
+	}
+}
+
+func TestEnforceUpdate_version(t *testing.T) {
+	type myType struct {
+		ID      string `json:"id,omitempty"`
+		Version int64  `json:"-" gourdupdate:"version"`
+	}
+
+	// version based on the original if not specified
+	update := &myType{}
+	if err := httpservice.EnforceUpdate(&myType{Version: 3}, update); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if want, have := int64(3), update.Version; want != have {
+		t.Errorf("Version: expected %#v, got %#v", want, have)
+	}
+
+	// specified version is kept
+	update = &myType{Version: 2}
+	if err := httpservice.EnforceUpdate(&myType{Version: 3}, update); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if want, have := int64(2), update.Version; want != have {
+		t.Errorf("Version: expected %#v, got %#v", want, have)
+	}
+}
@@ -0,0 +1,87 @@
+package httpservice
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
+)
+
+type headerKeys int
+
+const (
+	responseHeaderKey headerKeys = iota
+)
+
+// WithResponseHeader adds an empty http.Header to the context for
+// endpoints and middlewares to set response headers. The headers are
+// written by the encoders of NewJSONService
+func WithResponseHeader(parent context.Context, r *http.Request) context.Context {
+	return context.WithValue(parent, responseHeaderKey, http.Header{})
+}
+
+// ResponseHeader returns the response header in the context, or
+// nil if the context has none (see WithResponseHeader)
+func ResponseHeader(ctx context.Context) http.Header {
+	header, _ := ctx.Value(responseHeaderKey).(http.Header)
+	return header
+}
+
+// writeResponseHeader copies the response header in the context
+// to the ResponseWriter
+func writeResponseHeader(ctx context.Context, w http.ResponseWriter) {
+	for key, values := range ResponseHeader(ctx) {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+}
+
+// ETag returns the entity tag of the entity version (see
+// store.VersionProp). Returns false if the entity has none
+func ETag(entity interface{}) (etag string, ok bool) {
+	version, ok := store.Version(entity)
+	if !ok {
+		return
+	}
+	return strconv.Quote(strconv.FormatInt(version, 10)), true
+}
+
+// SetETag sets the entity tag of the entity version to the response
+// header in the context, if the entity has version field
+func SetETag(ctx context.Context, entity interface{}) {
+	header := ResponseHeader(ctx)
+	if etag, ok := ETag(entity); ok && header != nil {
+		header.Set("ETag", etag)
+	}
+}
+
+// EnforceVersion sets the version of the update payload by the
+// If-Match header of the request, so the store would update only
+// if the stored version matches (or fails with 409 StoreError).
+// If-Match of "*" or no If-Match leaves the payload as is.
+//
+// Returns 400 StoreError if the If-Match header is not a
+// version entity tag (see ETag)
+func EnforceVersion(r *http.Request, payload interface{}) (err error) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return
+	}
+	if _, ok := store.Version(payload); !ok {
+		return
+	}
+
+	str, err := strconv.Unquote(strings.TrimPrefix(ifMatch, "W/"))
+	if err != nil {
+		return store.Error(http.StatusBadRequest, "invalid If-Match header %#v", ifMatch)
+	}
+	version, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return store.Error(http.StatusBadRequest, "invalid If-Match header %#v", ifMatch)
+	}
+	store.SetVersion(payload, version)
+	return
+}
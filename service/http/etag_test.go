@@ -0,0 +1,81 @@
+package httpservice_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	httpservice "github.com/gourd/kit/service/http"
+	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
+)
+
+type versionEntity struct {
+	ID      string `json:"id"`
+	Version int64  `json:"-" gourdupdate:"version"`
+}
+
+func TestETag(t *testing.T) {
+	etag, ok := httpservice.ETag(&versionEntity{Version: 3})
+	if !ok {
+		t.Fatalf("expected entity tag")
+	}
+	if want, have := `"3"`, etag; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if _, ok := httpservice.ETag(&struct{ ID string }{}); ok {
+		t.Errorf("expected no entity tag")
+	}
+}
+
+func TestEnforceVersion(t *testing.T) {
+	tests := []struct {
+		ifMatch string
+		version int64
+		status  int
+	}{
+		{"", 1, 0},
+		{"*", 1, 0},
+		{`"5"`, 5, 0},
+		{`W/"6"`, 6, 0},
+		{`"abc"`, 1, http.StatusBadRequest},
+		{`7`, 1, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("PUT", "/entity/1", nil)
+		if test.ifMatch != "" {
+			r.Header.Set("If-Match", test.ifMatch)
+		}
+		payload := &versionEntity{Version: 1}
+		err := httpservice.EnforceVersion(r, payload)
+		if test.status == 0 && err != nil {
+			t.Errorf("If-Match %#v: unexpected error: %#v", test.ifMatch, err.Error())
+		} else if test.status != 0 {
+			if err == nil {
+				t.Errorf("If-Match %#v: expected error, got nil", test.ifMatch)
+			} else if want, have := test.status, store.ExpandError(err).Status; want != have {
+				t.Errorf("If-Match %#v: expected %#v, got %#v", test.ifMatch, want, have)
+			}
+		}
+		if want, have := test.version, payload.Version; want != have {
+			t.Errorf("If-Match %#v: expected %#v, got %#v", test.ifMatch, want, have)
+		}
+	}
+}
+
+func TestService_ETag(t *testing.T) {
+	s := httpservice.NewJSONService("/entity", func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		e := &versionEntity{ID: "1", Version: 2}
+		httpservice.SetETag(ctx, e)
+		response = map[string]interface{}{"entities": []*versionEntity{e}}
+		return
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/entity", strings.NewReader(""))
+	s.Handler().ServeHTTP(w, r)
+	if want, have := `"2"`, w.Header().Get("ETag"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
@@ -0,0 +1,68 @@
+package httpservice
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// RequestFields returns the fields selected by the "fields"
+// parameter (e.g. fields=id,name) of the request, if any
+func RequestFields(r *http.Request) (fields []string) {
+	if r == nil || r.URL == nil {
+		return
+	}
+	if str := r.URL.Query().Get("fields"); str != "" {
+		fields = strings.Split(str, ",")
+	}
+	return
+}
+
+// ProjectFields returns the JSON representation of the response
+// with only the given fields in entities. Entities are the JSON
+// objects in arrays that are either the response itself or
+// members of the response object (e.g. "users" in
+// {"users": [...], "paging": {...}}). Other values are
+// left as is
+func ProjectFields(response interface{}, fields []string) (projected interface{}, err error) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &projected); err != nil {
+		return
+	}
+
+	keep := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		keep[field] = true
+	}
+
+	switch v := projected.(type) {
+	case []interface{}:
+		projectList(v, keep)
+	case map[string]interface{}:
+		for _, member := range v {
+			if list, ok := member.([]interface{}); ok {
+				projectList(list, keep)
+			}
+		}
+	}
+	return
+}
+
+// projectList removes all keys not to keep from
+// the objects in the list
+func projectList(list []interface{}, keep map[string]bool) {
+	for _, item := range list {
+		entity, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key := range entity {
+			if !keep[key] {
+				delete(entity, key)
+			}
+		}
+	}
+}
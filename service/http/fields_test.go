@@ -0,0 +1,76 @@
+package httpservice_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	httpservice "github.com/gourd/kit/service/http"
+	"golang.org/x/net/context"
+)
+
+type fieldsEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestRequestFields(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/foo?fields=id,name", nil)
+	fields := httpservice.RequestFields(r)
+	if want, have := 2, len(fields); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "name", fields[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	r, _ = http.NewRequest("GET", "/foo", nil)
+	if fields := httpservice.RequestFields(r); fields != nil {
+		t.Errorf("expected nil, got %#v", fields)
+	}
+	if fields := httpservice.RequestFields(nil); fields != nil {
+		t.Errorf("expected nil, got %#v", fields)
+	}
+}
+
+func TestService_fields(t *testing.T) {
+	s := httpservice.NewJSONService("/foo", func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		response = map[string]interface{}{
+			"foos": []fieldsEntity{
+				{ID: "1", Name: "hello", Age: 10},
+				{ID: "2", Name: "world", Age: 20},
+			},
+			"paging": map[string]int{"total": 2},
+		}
+		return
+	})
+
+	r, _ := http.NewRequest("GET", "/foo?fields=id,name", strings.NewReader(""))
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+
+	var resp struct {
+		Foos   []map[string]interface{} `json:"foos"`
+		Paging map[string]interface{}   `json:"paging"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("error decoding response: %#v", err.Error())
+	}
+	if want, have := 2, len(resp.Foos); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	for _, foo := range resp.Foos {
+		if want, have := 2, len(foo); want != have {
+			t.Errorf("expected %#v, got %#v (%#v)", want, have, foo)
+		}
+		if _, ok := foo["age"]; ok {
+			t.Errorf("age should not be encoded")
+		}
+	}
+	if want, have := float64(2), resp.Paging["total"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
@@ -0,0 +1,126 @@
+package httpservice
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gourd/kit/context"
+	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
+)
+
+// ProblemContentType is the media type of problem details (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// Problem is the problem details of an error (RFC 7807)
+type Problem struct {
+
+	// Type is the URI identifying the problem type.
+	// Defaults to "about:blank"
+	Type string
+
+	// Title is the short summary of the problem type
+	Title string
+
+	// Status is the http status code
+	Status int
+
+	// Detail is the explanation specific to this
+	// occurrence of the problem. Omit if empty
+	Detail string
+
+	// Instance is the URI of this occurrence of
+	// the problem (e.g. the request path). Omit if empty
+	Instance string
+
+	// Code is service specific status code. Omit if empty
+	Code int
+
+	// DeveloperMsg is the message of help to developer.
+	// Omit if empty
+	DeveloperMsg string
+
+	// Fields are the errors of fields in the request
+	// payload, if any. Omit if empty
+	Fields []store.FieldError
+
+	// Extensions are the additional members of the problem.
+	// They would not override the members above
+	Extensions map[string]interface{}
+}
+
+// NewProblem returns the problem details of the StoreError
+// which occurs to the instance URI
+func NewProblem(serr *store.StoreError, instance string) *Problem {
+	p := &Problem{
+		Type:         serr.Type,
+		Title:        http.StatusText(serr.Status),
+		Status:       serr.Status,
+		Detail:       serr.ClientMsg,
+		Instance:     instance,
+		Code:         serr.Code,
+		DeveloperMsg: serr.DeveloperMsg,
+		Fields:       serr.Fields,
+		Extensions:   serr.Meta,
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = serr.ClientMsg
+	}
+	return p
+}
+
+// MarshalJSON implements json.Marshaler. Extensions are
+// rendered as top-level members of the problem
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+8)
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	if p.Code != 0 {
+		members["code"] = p.Code
+	}
+	if p.DeveloperMsg != "" {
+		members["developer_message"] = p.DeveloperMsg
+	}
+	if len(p.Fields) > 0 {
+		members["fields"] = p.Fields
+	}
+	return json.Marshal(members)
+}
+
+// ProblemErrorEncoder expands given error to StoreError then encode
+// to problem details (RFC 7807) of the request path. Could be used as
+// ErrorEncoder of Service
+func ProblemErrorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
+
+	// quick fix for gokit bad request wrapping problem
+	switch err.(type) {
+	case httptransport.Error:
+		err = err.(httptransport.Error).Err
+	}
+
+	var instance string
+	if r := gourdctx.HTTPRequest(ctx); r != nil && r.URL != nil {
+		instance = r.URL.Path
+	}
+
+	serr := store.ExpandError(err)
+	log.Printf("error: %#v", serr.ServerMsg)
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(serr.Status)
+	json.NewEncoder(w).Encode(NewProblem(serr, instance))
+}
@@ -0,0 +1,96 @@
+package httpservice_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gourd/kit/context"
+	httpservice "github.com/gourd/kit/service/http"
+	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
+)
+
+func TestProblemErrorEncoder(t *testing.T) {
+	serr := store.Error(42201, "Unprocessable Entity").
+		TellDeveloper("payload failed validation").
+		WithType(store.TypeInvalid).
+		WithFields(store.FieldError{Field: "email", Reason: "email", Message: "must be an email address"}).
+		WithMeta("retry", false)
+
+	r, _ := http.NewRequest("POST", "http://foo.com/api/users?x=1", nil)
+	ctx := gourdctx.WithHTTPRequest(context.Background(), r)
+	w := httptest.NewRecorder()
+	httpservice.ProblemErrorEncoder(ctx, serr, w)
+
+	if want, have := 422, w.Code; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := httpservice.ProblemContentType, w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	var problem map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	expected := map[string]interface{}{
+		"type":              store.TypeInvalid,
+		"title":             "Unprocessable Entity",
+		"status":            float64(422),
+		"detail":            "Unprocessable Entity",
+		"instance":          "/api/users",
+		"code":              float64(42201),
+		"developer_message": "payload failed validation",
+		"retry":             false,
+	}
+	for key, want := range expected {
+		if have := problem[key]; want != have {
+			t.Errorf("%s: expected %#v, got %#v", key, want, have)
+		}
+	}
+	fields, ok := problem["fields"].([]interface{})
+	if !ok || len(fields) != 1 {
+		t.Fatalf("expected 1 field error, got %#v", problem["fields"])
+	}
+	if want, have := "email", fields[0].(map[string]interface{})["field"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestProblemErrorEncoder_plain(t *testing.T) {
+	w := httptest.NewRecorder()
+	httpservice.ProblemErrorEncoder(context.Background(), errors.New("boom"), w)
+
+	if want, have := http.StatusInternalServerError, w.Code; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	var problem map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "about:blank", problem["type"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "Internal Server Error", problem["title"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if _, ok := problem["instance"]; ok {
+		t.Errorf("expected no instance, got %#v", problem["instance"])
+	}
+}
+
+func TestProblem_MarshalJSON(t *testing.T) {
+	p := httpservice.NewProblem(store.ErrorNotFound.WithMeta("status", "shadowed"), "/foo")
+	raw, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	want := `{"code":404,"detail":"Not Found","instance":"/foo",` +
+		`"status":404,"title":"Not Found","type":"` + store.TypeNotFound + `"}`
+	if have := string(raw); want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+}
@@ -0,0 +1,26 @@
+package httpservice
+
+import (
+	"net/http"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
+)
+
+// DecodeListFunc returns a DecodeRequestFunc for list endpoints.
+// It parses the URL query of the request with store.ParseQuery
+// and returns a *Request with the parsed Query
+func DecodeListFunc(opts *store.QueryOptions) httptransport.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (request interface{}, err error) {
+		q, err := store.ParseQuery(r.URL.Query(), opts)
+		if err != nil {
+			return
+		}
+		request = &Request{
+			Request: r,
+			Query:   q,
+		}
+		return
+	}
+}
@@ -0,0 +1,43 @@
+package httpservice_test
+
+import (
+	"net/http"
+	"testing"
+
+	httpservice "github.com/gourd/kit/service/http"
+	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
+)
+
+func TestDecodeListFunc(t *testing.T) {
+	dec := httpservice.DecodeListFunc(&store.QueryOptions{
+		Filters: []string{"name"},
+		Sorts:   []string{"name"},
+	})
+
+	r, _ := http.NewRequest("GET", "/foo?filter[name]=bar&sort=-name&limit=5", nil)
+	request, err := dec(context.Background(), r)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	sReq, ok := request.(*httpservice.Request)
+	if !ok {
+		t.Fatalf("expected *httpservice.Request, got %#v", request)
+	}
+	if want, have := r, sReq.Request; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Cond{Prop: "name", Value: "bar"}), sReq.Query.GetConds().GetAll()[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := uint64(5), sReq.Query.GetLimit(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	r, _ = http.NewRequest("GET", "/foo?filter[id]=bar", nil)
+	if _, err := dec(context.Background(), r); err == nil {
+		t.Errorf("expected error, got nil")
+	} else if want, have := http.StatusBadRequest, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
@@ -14,8 +14,17 @@ import (
 	"golang.org/x/net/context"
 )
 
-// jsonEncodeFunc encodes given response into JSON
+// jsonEncodeFunc encodes given response into JSON. If the request
+// selects fields, only the fields of entities would be encoded
+// (see ProjectFields). Headers set to ResponseHeader of the
+// context are written
 func jsonEncodeFunc(ctx context.Context, w http.ResponseWriter, response interface{}) (err error) {
+	if fields := RequestFields(gourdctx.HTTPRequest(ctx)); len(fields) > 0 {
+		if response, err = ProjectFields(response, fields); err != nil {
+			return
+		}
+	}
+	writeResponseHeader(ctx, w)
 	w.Header().Add("Content-Type", "application/json")
 	enc := json.NewEncoder(w)
 	err = enc.Encode(response)
@@ -61,6 +70,7 @@ func NewJSONService(path string, ep endpoint.Endpoint) *Service {
 		Before: []httptransport.RequestFunc{
 			gourdctx.WithHTTPRequest,
 			ProvideJSONDecoder,
+			WithResponseHeader,
 		},
 		After:        []httptransport.ServerResponseFunc{},
 		ErrorEncoder: jsonErrorEncoder,
@@ -140,6 +140,54 @@ func TestService_Error(t *testing.T) {
 	t.Logf("err: %#v", serr)
 }
 
+func TestService_errorEncoder(t *testing.T) {
+
+	// create handler with service
+	s := httpservice.NewJSONService("/foo/bar", func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		err = store.ErrorNotFound.TellServer("secret server message").
+			TellDeveloper("hello developer")
+		return
+	})
+	s.DecodeFunc = func(ctx context.Context, r *http.Request) (request interface{}, err error) {
+		request = "hello world"
+		return
+	}
+	h := s.Handler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, nil)
+
+	// status is in the body, not the status line
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "application/json", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := float64(http.StatusNotFound), body["status"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := store.ErrorNotFound.ClientMsg, body["message"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "hello developer", body["developer_message"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := store.TypeNotFound, body["type"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	for key, value := range body {
+		if value == "secret server message" {
+			t.Errorf("server message leaked as %#v", key)
+		}
+	}
+}
+
 func TestNewJSONService(t *testing.T) {
 
 	str := `{"hello": "world"}`
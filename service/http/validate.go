@@ -0,0 +1,423 @@
+package httpservice
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gourd/kit/store"
+)
+
+// StatusUnprocessableEntity is the HTTP status of payload
+// failed validation (see Validate)
+const StatusUnprocessableEntity = 422
+
+// Validate validates the payload, a pointer to struct or to slice
+// of structs, by the "validate" tags of the struct fields. Rules in
+// a tag are separated by comma:
+//
+//   - required: value must not be zero (e.g. empty string or nil)
+//   - min=n: length of string (in characters), slice or map must
+//     be at least n
+//   - max=n: length of string, slice or map must be at most n
+//   - email: string must be an email address
+//   - enum=a|b|c: value must be one of the listed
+//   - range=lo:hi: number must be within lo and hi inclusive.
+//     Either bound could be omitted (e.g. "range=0:")
+//   - pattern=regexp: string must match the regular expression.
+//     The rest of the tag is taken as the pattern, so it must be
+//     the last rule
+//
+// Rules other than required are skipped for zero value. Returns
+// 422 StoreError of store.TypeInvalid with errors of all failing
+// fields (see store.FieldError), named after the json tags. Fields of slice
+// element are prefixed with the index (e.g. "0.email")
+func Validate(payload interface{}) (err error) {
+	ptr := reflect.ValueOf(payload)
+	if !ptr.IsValid() || ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return store.Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("payload is not pointer but %#v", payload)
+	}
+
+	var fields []store.FieldError
+	switch val := ptr.Elem(); val.Kind() {
+	case reflect.Struct:
+		fields, err = validateStruct(val, "")
+	case reflect.Slice:
+		for i := 0; i < val.Len() && err == nil; i++ {
+			var ferrs []store.FieldError
+			elem := reflect.Indirect(val.Index(i))
+			if elem.Kind() != reflect.Struct {
+				break
+			}
+			ferrs, err = validateStruct(elem, fmt.Sprintf("%d.", i))
+			fields = append(fields, ferrs...)
+		}
+	}
+	if err != nil {
+		return store.Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("%s", err)
+	}
+	if len(fields) > 0 {
+		return invalid(fields)
+	}
+	return nil
+}
+
+// ValidateFields validates the fields (property name to value) to
+// set to entities of the type of entity (e.g. for store.UpdateMany),
+// by the "validate" tags of the struct fields of the same json name,
+// or db name. Properties not found in the struct are skipped. Value
+// not of the field type fails with reason "type". Numbers are converted
+// to the number type of the field only if not losing the value (e.g.
+// 1.5 to int fails), pointer fields take the value they point to, and
+// time.Time fields take strings of RFC 3339 (as encoded in JSON).
+//
+// Returns 422 StoreError as Validate, with errors of the failing
+// properties (see store.FieldError) named after their keys
+func ValidateFields(entity interface{}, fields map[string]interface{}) (err error) {
+	typ := reflect.TypeOf(entity)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return store.Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("entity is not struct but %#v", entity)
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ferrs []store.FieldError
+	for _, name := range names {
+		field, ok := propField(typ, name)
+		if !ok || field.Tag.Get("validate") == "" {
+			continue
+		}
+		val := reflect.New(field.Type).Elem()
+		if !setValue(val, fields[name]) {
+			ferrs = append(ferrs, store.FieldError{Field: name, Reason: "type",
+				Message: fmt.Sprintf("must be of type %s", field.Type)})
+			continue
+		}
+		var ferr *store.FieldError
+		if ferr, err = validateField(val, field.Tag.Get("validate")); err != nil {
+			return store.Error(http.StatusInternalServerError,
+				http.StatusText(http.StatusInternalServerError)).
+				TellServer("field %s: %s", field.Name, err)
+		}
+		if ferr != nil {
+			ferr.Field = name
+			ferrs = append(ferrs, *ferr)
+		}
+	}
+	if len(ferrs) > 0 {
+		return invalid(ferrs)
+	}
+	return nil
+}
+
+// invalid returns the 422 StoreError of the failing fields
+func invalid(fields []store.FieldError) error {
+	return store.Error(StatusUnprocessableEntity, "Unprocessable Entity").
+		TellDeveloper("payload failed validation").
+		WithType(store.TypeInvalid).
+		WithFields(fields...)
+}
+
+// propField returns the exported struct field of the json name,
+// db name, or field name, in that order of precedence
+func propField(typ reflect.Type, name string) (field reflect.StructField, ok bool) {
+	for _, tag := range []string{"json", "db", ""} {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if (tag == "" && f.Name == name) ||
+				(tag != "" && strings.Split(f.Tag.Get(tag), ",")[0] == name) {
+				return f, true
+			}
+		}
+	}
+	return
+}
+
+// timeType is the type of time.Time
+var timeType = reflect.TypeOf(time.Time{})
+
+// setValue sets the value v to val, converting numbers to the number
+// type of val, and strings to time.Time, or to the value val points to.
+// Returns false if v is not of the type or the conversion loses value
+func setValue(val reflect.Value, v interface{}) bool {
+	if v == nil {
+		return true // leave the zero value
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(val.Type()) {
+		val.Set(rv)
+		return true
+	}
+	switch {
+	case val.Kind() == reflect.Ptr:
+		ptr := reflect.New(val.Type().Elem())
+		if !setValue(ptr.Elem(), v) {
+			return false
+		}
+		val.Set(ptr)
+		return true
+	case val.Type() == timeType && rv.Kind() == reflect.String:
+		t, err := time.Parse(time.RFC3339, rv.String())
+		if err != nil {
+			return false
+		}
+		val.Set(reflect.ValueOf(t))
+		return true
+	case isNumber(rv.Kind()) && isNumber(val.Kind()):
+		cv := rv.Convert(val.Type())
+		if !isFloat(val.Kind()) && cv.Convert(rv.Type()).Interface() != v {
+			return false // fraction dropped or overflowed
+		}
+		if isFloat(val.Kind()) && math.IsInf(cv.Float(), 0) {
+			return false
+		}
+		val.Set(cv)
+		return true
+	}
+	return false
+}
+
+// isFloat tells if the kind is of float
+func isFloat(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
+
+// isNumber tells if the kind is of integer or float
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// validateStruct validates the fields of the struct value.
+// Returns error if any of the tags is malformed
+func validateStruct(val reflect.Value, prefix string) (fields []store.FieldError, err error) {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+
+		var ferr *store.FieldError
+		if ferr, err = validateField(val.Field(i), tag); err != nil {
+			err = fmt.Errorf("field %s: %s", field.Name, err)
+			return
+		}
+		if ferr != nil {
+			ferr.Field = prefix + name
+			fields = append(fields, *ferr)
+		}
+	}
+	return
+}
+
+// validateField validates the value by the rules in the tag.
+// Returns the error of the first failing rule, if any
+func validateField(val reflect.Value, tag string) (ferr *store.FieldError, err error) {
+	zero := isZero(val)
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "pattern=") {
+			rule, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			rule, tag = tag[:i], tag[i+1:]
+		} else {
+			rule, tag = tag, ""
+		}
+
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		if name == "required" {
+			if zero {
+				return fieldError(name, "is required"), nil
+			}
+			continue
+		}
+		if zero {
+			continue
+		}
+
+		var msg string
+		if msg, err = checkRule(reflect.Indirect(val), name, arg); err != nil || msg != "" {
+			if msg != "" {
+				ferr = fieldError(name, msg)
+			}
+			return
+		}
+	}
+	return
+}
+
+// checkRule checks the value by the rule. Returns the message
+// if the value fails, or error if the rule is malformed
+func checkRule(val reflect.Value, name, arg string) (msg string, err error) {
+	switch name {
+	case "min", "max":
+		var n, limit int
+		if limit, err = strconv.Atoi(arg); err != nil {
+			err = fmt.Errorf("malformed rule %s=%s", name, arg)
+			return
+		}
+		switch val.Kind() {
+		case reflect.String:
+			n = utf8.RuneCountInString(val.String())
+		case reflect.Slice, reflect.Map, reflect.Array:
+			n = val.Len()
+		default:
+			err = fmt.Errorf("rule %s is not applicable to %s", name, val.Type())
+			return
+		}
+		if name == "min" && n < limit {
+			msg = fmt.Sprintf("must be at least %d in length", limit)
+		} else if name == "max" && n > limit {
+			msg = fmt.Sprintf("must be at most %d in length", limit)
+		}
+	case "email":
+		if val.Kind() != reflect.String {
+			err = fmt.Errorf("rule email is not applicable to %s", val.Type())
+		} else if !govalidator.IsEmail(val.String()) {
+			msg = "must be an email address"
+		}
+	case "enum":
+		str := fmt.Sprint(val.Interface())
+		for _, option := range strings.Split(arg, "|") {
+			if option == str {
+				return
+			}
+		}
+		msg = fmt.Sprintf("must be one of %s", strings.Replace(arg, "|", ", ", -1))
+	case "range":
+		msg, err = checkRange(val, arg)
+	case "pattern":
+		var re *regexp.Regexp
+		if re, err = compilePattern(arg); err != nil {
+			return
+		}
+		if val.Kind() != reflect.String {
+			err = fmt.Errorf("rule pattern is not applicable to %s", val.Type())
+		} else if !re.MatchString(val.String()) {
+			msg = fmt.Sprintf("must match the pattern %s", arg)
+		}
+	default:
+		err = fmt.Errorf("unknown rule %#v", name)
+	}
+	return
+}
+
+// checkRange checks the number value within the range "lo:hi"
+func checkRange(val reflect.Value, arg string) (msg string, err error) {
+	bounds := strings.Split(arg, ":")
+	if len(bounds) != 2 {
+		err = fmt.Errorf("malformed rule range=%s", arg)
+		return
+	}
+
+	var n float64
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = val.Float()
+	default:
+		err = fmt.Errorf("rule range is not applicable to %s", val.Type())
+		return
+	}
+
+	for i, bound := range bounds {
+		if bound == "" {
+			continue
+		}
+		var limit float64
+		if limit, err = strconv.ParseFloat(bound, 64); err != nil {
+			err = fmt.Errorf("malformed rule range=%s", arg)
+			return
+		}
+		if (i == 0 && n < limit) || (i == 1 && n > limit) {
+			switch {
+			case bounds[0] == "":
+				msg = fmt.Sprintf("must be at most %s", bounds[1])
+			case bounds[1] == "":
+				msg = fmt.Sprintf("must be at least %s", bounds[0])
+			default:
+				msg = fmt.Sprintf("must be between %s and %s", bounds[0], bounds[1])
+			}
+			return
+		}
+	}
+	return
+}
+
+// isZero tells if the value is the zero value of its type
+func isZero(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return val.IsNil() || (val.Kind() != reflect.Ptr &&
+			val.Kind() != reflect.Interface && val.Len() == 0)
+	}
+	return reflect.DeepEqual(val.Interface(), reflect.Zero(val.Type()).Interface())
+}
+
+// fieldError returns FieldError of the reason and message
+func fieldError(reason, msg string) *store.FieldError {
+	return &store.FieldError{Reason: reason, Message: msg}
+}
+
+// patterns caches compiled patterns of validate tags
+var patterns = struct {
+	sync.Mutex
+	cache map[string]*regexp.Regexp
+}{cache: make(map[string]*regexp.Regexp)}
+
+// compilePattern returns the compiled regular expression
+func compilePattern(expr string) (re *regexp.Regexp, err error) {
+	patterns.Lock()
+	defer patterns.Unlock()
+	if re = patterns.cache[expr]; re != nil {
+		return
+	}
+	if re, err = regexp.Compile(expr); err != nil {
+		err = fmt.Errorf("malformed rule pattern=%s: %s", expr, err)
+		return
+	}
+	patterns.cache[expr] = re
+	return
+}
@@ -0,0 +1,228 @@
+package httpservice_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gourd/kit/service/http"
+	"github.com/gourd/kit/store"
+)
+
+type validateType struct {
+	ID       string   `json:"id"`
+	Username string   `json:"username" validate:"required,min=3,max=8"`
+	Email    string   `json:"email" validate:"email"`
+	Role     string   `json:"role" validate:"enum=admin|user"`
+	Age      int      `json:"age" validate:"range=0:150"`
+	Score    float64  `json:"score" validate:"range=:10"`
+	Code     string   `json:"code" validate:"max=4,pattern=^[a-z]{2,}$"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Note     *string  `json:"note" validate:"required"`
+}
+
+// fieldReasons returns the reasons of field errors by field name
+func fieldReasons(t *testing.T, err error) map[string]string {
+	if err == nil {
+		return nil
+	}
+	serr, ok := err.(*store.StoreError)
+	if !ok {
+		t.Fatalf("expected *store.StoreError, got %#v", err)
+	}
+	if want, have := httpservice.StatusUnprocessableEntity, serr.Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := store.TypeInvalid, serr.Type; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	reasons := make(map[string]string)
+	for _, field := range serr.Fields {
+		reasons[field.Field] = field.Reason
+	}
+	return reasons
+}
+
+func TestValidate(t *testing.T) {
+	note := "note"
+	valid := validateType{
+		Username: "alice",
+		Email:    "alice@example.com",
+		Role:     "admin",
+		Age:      30,
+		Score:    9.5,
+		Code:     "abc",
+		Tags:     []string{"a", "b"},
+		Note:     &note,
+	}
+	if err := httpservice.Validate(&valid); err != nil {
+		t.Errorf("unexpected error: %#v", err)
+	}
+
+	// optional fields of zero value are not validated
+	if err := httpservice.Validate(&validateType{Username: "bob", Note: &note}); err != nil {
+		t.Errorf("unexpected error: %#v", err)
+	}
+
+	tests := []struct {
+		modify func(*validateType)
+		field  string
+		reason string
+	}{
+		{func(v *validateType) { v.Username = "" }, "username", "required"},
+		{func(v *validateType) { v.Username = "al" }, "username", "min"},
+		{func(v *validateType) { v.Username = "alice1234" }, "username", "max"},
+		{func(v *validateType) { v.Email = "alice" }, "email", "email"},
+		{func(v *validateType) { v.Role = "root" }, "role", "enum"},
+		{func(v *validateType) { v.Age = 200 }, "age", "range"},
+		{func(v *validateType) { v.Age = -1 }, "age", "range"},
+		{func(v *validateType) { v.Score = 10.5 }, "score", "range"},
+		{func(v *validateType) { v.Code = "a1" }, "code", "pattern"},
+		{func(v *validateType) { v.Code = "abcde" }, "code", "max"},
+		{func(v *validateType) { v.Tags = []string{"a", "b", "c"} }, "tags", "max"},
+		{func(v *validateType) { v.Note = nil }, "note", "required"},
+	}
+	for i, test := range tests {
+		payload := valid
+		test.modify(&payload)
+		reasons := fieldReasons(t, httpservice.Validate(&payload))
+		if want, have := 1, len(reasons); want != have {
+			t.Errorf("test %d: expected %#v, got %#v", i, want, have)
+		}
+		if want, have := test.reason, reasons[test.field]; want != have {
+			t.Errorf("test %d: expected %#v, got %#v", i, want, have)
+		}
+	}
+}
+
+func TestValidate_allFields(t *testing.T) {
+	reasons := fieldReasons(t, httpservice.Validate(&validateType{
+		Email: "not email",
+		Age:   -1,
+	}))
+	want := map[string]string{
+		"username": "required",
+		"email":    "email",
+		"age":      "range",
+		"note":     "required",
+	}
+	if len(want) != len(reasons) {
+		t.Errorf("expected %#v, got %#v", want, reasons)
+	}
+	for field, reason := range want {
+		if have := reasons[field]; reason != have {
+			t.Errorf("%s: expected %#v, got %#v", field, reason, have)
+		}
+	}
+}
+
+func TestValidate_list(t *testing.T) {
+	note := "note"
+	list := []validateType{
+		{Username: "alice", Note: &note},
+		{Username: "", Note: &note},
+	}
+	reasons := fieldReasons(t, httpservice.Validate(&list))
+	if want, have := "required", reasons["1.username"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 1, len(reasons); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestValidate_malformed(t *testing.T) {
+	type malformed struct {
+		Name string `json:"name" validate:"min=a"`
+	}
+	err := httpservice.Validate(&malformed{Name: "foo"})
+	serr, ok := err.(*store.StoreError)
+	if !ok {
+		t.Fatalf("expected *store.StoreError, got %#v", err)
+	}
+	if want, have := 500, serr.Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	// valid fields, and fields without rules or not found
+	if err := httpservice.ValidateFields(&validateType{}, map[string]interface{}{
+		"username": "alice",
+		"age":      float64(20),
+		"id":       "",
+		"unknown":  1,
+	}); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	}
+
+	reasons := fieldReasons(t, httpservice.ValidateFields(&validateType{}, map[string]interface{}{
+		"username": "",
+		"email":    "not email",
+		"age":      float64(200),
+		"role":     1,
+		"code":     "abc",
+	}))
+	want := map[string]string{
+		"username": "required",
+		"email":    "email",
+		"age":      "range",
+		"role":     "type",
+	}
+	if len(want) != len(reasons) {
+		t.Errorf("expected %#v, got %#v", want, reasons)
+	}
+	for field, reason := range want {
+		if have := reasons[field]; reason != have {
+			t.Errorf("%s: expected %#v, got %#v", field, reason, have)
+		}
+	}
+}
+
+type validateFieldsType struct {
+	Limit *int      `json:"limit" validate:"range=1:10"`
+	Count int8      `json:"count" validate:"range=0:"`
+	Ratio float32   `json:"ratio" validate:"range=0:1"`
+	Since time.Time `json:"since" validate:"required"`
+}
+
+func TestValidateFields_convert(t *testing.T) {
+	// pointer, time string and numbers converted without loss
+	if err := httpservice.ValidateFields(&validateFieldsType{}, map[string]interface{}{
+		"limit": float64(5),
+		"count": float64(100),
+		"ratio": float64(0.1),
+		"since": "2016-01-02T15:04:05.123Z",
+	}); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	}
+
+	reasons := fieldReasons(t, httpservice.ValidateFields(&validateFieldsType{}, map[string]interface{}{
+		"limit": float64(20),
+		"count": float64(1.5),
+		"ratio": float64(1e300),
+		"since": "yesterday",
+	}))
+	want := map[string]string{
+		"limit": "range",
+		"count": "type",
+		"ratio": "type",
+		"since": "type",
+	}
+	if len(want) != len(reasons) {
+		t.Errorf("expected %#v, got %#v", want, reasons)
+	}
+	for field, reason := range want {
+		if have := reasons[field]; reason != have {
+			t.Errorf("%s: expected %#v, got %#v", field, reason, have)
+		}
+	}
+
+	// overflow of int8 on both ends
+	for _, v := range []interface{}{float64(300), int64(-129)} {
+		reasons := fieldReasons(t, httpservice.ValidateFields(&validateFieldsType{},
+			map[string]interface{}{"count": v}))
+		if want, have := "type", reasons["count"]; want != have {
+			t.Errorf("%#v: expected %#v, got %#v", v, want, have)
+		}
+	}
+}
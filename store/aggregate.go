@@ -0,0 +1,368 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// AggFunc is the function of an aggregation
+type AggFunc int
+
+const (
+	// AggCount counts the entities (or non-null values of the property)
+	AggCount AggFunc = iota
+
+	// AggSum sums up the values of the property
+	AggSum
+
+	// AggAvg averages the values of the property
+	AggAvg
+
+	// AggMin finds the minimum value of the property
+	AggMin
+
+	// AggMax finds the maximum value of the property
+	AggMax
+)
+
+// aggFuncStrings maps AggFunc to its string representation
+var aggFuncStrings = map[AggFunc]string{
+	AggCount: "count",
+	AggSum:   "sum",
+	AggAvg:   "avg",
+	AggMin:   "min",
+	AggMax:   "max",
+}
+
+// String implements fmt.Stringer
+func (fn AggFunc) String() string {
+	if str, ok := aggFuncStrings[fn]; ok {
+		return str
+	}
+	return fmt.Sprintf("AggFunc(%d)", int(fn))
+}
+
+// Aggregate describes an aggregate function on a property
+type Aggregate struct {
+	Func AggFunc
+	Prop string
+}
+
+// Name returns the key of the aggregate value in AggregateRow.
+// e.g. "sum_amount" for sum of amount, "count" for count of
+// entities (with empty Prop)
+func (agg Aggregate) Name() string {
+	if agg.Prop == "" {
+		return agg.Func.String()
+	}
+	return agg.Func.String() + "_" + agg.Prop
+}
+
+// AggregateRow is a row of aggregation result. It maps the group-by
+// properties to their values and the aggregate names (see Aggregate.Name)
+// to the aggregate values
+type AggregateRow map[string]interface{}
+
+// AggregateEntities aggregates the given list of entities in process
+// by the group-by properties and aggregates of the query. The el is a
+// pointer to slice of structs (or pointers to struct), as returned by
+// Store.AllocEntityList.
+//
+// Conditions of the query are not applied. Rows are sorted by the query
+// sorts (of the group-by properties or aggregate names), or by the group-by
+// values if the query has no sort. Limit and offset apply to the rows
+func AggregateEntities(q Query, el interface{}) (rows []AggregateRow, err error) {
+	list := reflect.ValueOf(el)
+	for list.Kind() == reflect.Ptr && !list.IsNil() {
+		list = list.Elem()
+	}
+	if list.Kind() != reflect.Slice {
+		err = aggError("expected pointer to slice, got %#v", el)
+		return
+	}
+
+	groupBy, aggs := q.GetGroupBy(), q.GetAggregates()
+	groups := make(map[string]*aggGroup)
+	var order []*aggGroup
+
+	for i := 0; i < list.Len(); i++ {
+		item := list.Index(i)
+		for item.Kind() == reflect.Ptr && !item.IsNil() {
+			item = item.Elem()
+		}
+		if item.Kind() != reflect.Struct {
+			err = aggError("expected struct entity, got %s", item.Type())
+			return
+		}
+
+		// find the group of the entity
+		keys := make([]interface{}, len(groupBy))
+		for j, prop := range groupBy {
+			if keys[j], err = aggPropValue(item, prop); err != nil {
+				return
+			}
+		}
+		id := aggGroupID(keys)
+		g, ok := groups[id]
+		if !ok {
+			g = &aggGroup{keys: keys, values: make([][]interface{}, len(aggs))}
+			groups[id] = g
+			order = append(order, g)
+		}
+
+		// collect values to aggregate
+		for j, agg := range aggs {
+			if agg.Prop == "" {
+				g.values[j] = append(g.values[j], true)
+				continue
+			}
+			var v interface{}
+			if v, err = aggPropValue(item, agg.Prop); err != nil {
+				return
+			}
+			if v != nil {
+				g.values[j] = append(g.values[j], v)
+			}
+		}
+	}
+
+	// compute rows
+	rows = make([]AggregateRow, 0, len(order))
+	for _, g := range order {
+		row := make(AggregateRow, len(groupBy)+len(aggs))
+		for j, prop := range groupBy {
+			row[prop] = g.keys[j]
+		}
+		for j, agg := range aggs {
+			if row[agg.Name()], err = aggCompute(agg, g.values[j]); err != nil {
+				return nil, err
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	// sort rows
+	var sorts []*Sort
+	if q.GetSorts() != nil {
+		sorts = q.GetSorts().GetAll()
+	}
+	for _, s := range sorts {
+		if !inStrings(groupBy, s.Name) && !aggNamed(aggs, s.Name) {
+			err = Error(http.StatusBadRequest,
+				"cannot sort aggregation by %#v", s.Name)
+			return nil, err
+		}
+	}
+	if len(sorts) == 0 {
+		for _, prop := range groupBy {
+			sorts = append(sorts, &Sort{Name: prop})
+		}
+	}
+	rs := &rowSorter{rows: rows, sorts: sorts}
+	sort.Stable(rs)
+	if rs.err != nil {
+		return nil, rs.err
+	}
+
+	// page rows
+	offset, limit := q.GetOffset(), q.GetLimit()
+	if offset >= uint64(len(rows)) {
+		return []AggregateRow{}, nil
+	}
+	rows = rows[offset:]
+	if limit != 0 && limit < uint64(len(rows)) {
+		rows = rows[:limit]
+	}
+	return
+}
+
+// aggGroup collects the values to aggregate of a group
+type aggGroup struct {
+	keys   []interface{}
+	values [][]interface{}
+}
+
+// aggNamed tells if any of the aggregates has the name
+func aggNamed(aggs []Aggregate, name string) bool {
+	for _, agg := range aggs {
+		if agg.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// aggGroupID returns the identity string of the group-by values
+func aggGroupID(keys []interface{}) string {
+	ids := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if t, ok := key.(time.Time); ok {
+			ids[i] = t.UnixNano()
+		} else {
+			ids[i] = key
+		}
+	}
+	return fmt.Sprintf("%#v", ids)
+}
+
+// aggPropValue returns the value of the property of the struct
+// value. Pointer is dereferenced and nil pointer returns nil
+func aggPropValue(item reflect.Value, prop string) (v interface{}, err error) {
+	field, ok := propField(item, prop)
+	if !ok {
+		err = aggError("property %#v not found in %s", prop, item.Type())
+		return
+	}
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil, nil
+		}
+		field = field.Elem()
+	}
+	return field.Interface(), nil
+}
+
+// aggCompute computes the aggregate value of the values
+func aggCompute(agg Aggregate, values []interface{}) (v interface{}, err error) {
+	switch agg.Func {
+	case AggCount:
+		return int64(len(values)), nil
+	case AggSum, AggAvg:
+		var sum float64
+		for _, value := range values {
+			f, ok := aggFloat(value)
+			if !ok {
+				err = aggError("unable to %s non-number %#v of %#v", agg.Func, value, agg.Prop)
+				return
+			}
+			sum += f
+		}
+		if agg.Func == AggSum {
+			return sum, nil
+		} else if len(values) == 0 {
+			return nil, nil
+		}
+		return sum / float64(len(values)), nil
+	case AggMin, AggMax:
+		for _, value := range values {
+			if v == nil {
+				v = value
+				continue
+			}
+			var c int
+			if c, err = aggCompare(value, v); err != nil {
+				return
+			}
+			if (agg.Func == AggMin && c < 0) || (agg.Func == AggMax && c > 0) {
+				v = value
+			}
+		}
+		return
+	}
+	err = aggError("unsupported aggregate function %s", agg.Func)
+	return
+}
+
+// aggFloat returns the float64 value of a number
+func aggFloat(v interface{}) (f float64, ok bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return
+}
+
+// aggCompare returns -1, 0 or 1 if a is less than, equals to or
+// greater than b. Nil is less than any value. Numbers, strings and
+// time values are comparable
+func aggCompare(a, b interface{}) (c int, err error) {
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		return -1, nil
+	case b == nil:
+		return 1, nil
+	}
+
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			switch {
+			case ta.Before(tb):
+				c = -1
+			case ta.After(tb):
+				c = 1
+			}
+			return
+		}
+	}
+	if fa, ok := aggFloat(a); ok {
+		if fb, ok := aggFloat(b); ok {
+			switch {
+			case fa < fb:
+				c = -1
+			case fa > fb:
+				c = 1
+			}
+			return
+		}
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() == reflect.String && vb.Kind() == reflect.String {
+		switch {
+		case va.String() < vb.String():
+			c = -1
+		case va.String() > vb.String():
+			c = 1
+		}
+		return
+	}
+	err = aggError("unable to compare %#v with %#v", a, b)
+	return
+}
+
+// rowSorter sorts AggregateRow by the sorts
+type rowSorter struct {
+	rows  []AggregateRow
+	sorts []*Sort
+	err   error
+}
+
+func (rs *rowSorter) Len() int {
+	return len(rs.rows)
+}
+
+func (rs *rowSorter) Less(i, j int) bool {
+	for _, s := range rs.sorts {
+		c, err := aggCompare(rs.rows[i][s.Name], rs.rows[j][s.Name])
+		if err != nil {
+			if rs.err == nil {
+				rs.err = err
+			}
+			return false
+		}
+		if c != 0 {
+			return (c < 0) != (s.Order == Desc)
+		}
+	}
+	return false
+}
+
+func (rs *rowSorter) Swap(i, j int) {
+	rs.rows[i], rs.rows[j] = rs.rows[j], rs.rows[i]
+}
+
+// aggError returns an internal server error with the server message
+func aggError(msg string, v ...interface{}) error {
+	return Error(http.StatusInternalServerError,
+		http.StatusText(http.StatusInternalServerError)).
+		TellServer("error aggregating: "+msg, v...)
+}
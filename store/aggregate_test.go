@@ -0,0 +1,134 @@
+package store_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gourd/kit/store"
+)
+
+type aggEntity struct {
+	Group  string   `db:"group"`
+	Amount int      `db:"amount"`
+	Score  *float64 `db:"score"`
+}
+
+func testAggEntities() *[]aggEntity {
+	score := 1.5
+	return &[]aggEntity{
+		{Group: "b", Amount: 10, Score: &score},
+		{Group: "a", Amount: 5},
+		{Group: "b", Amount: 30},
+		{Group: "a", Amount: 1, Score: &score},
+		{Group: "c", Amount: 7},
+	}
+}
+
+func TestAggFunc_String(t *testing.T) {
+	if want, have := "sum", store.AggSum.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "AggFunc(100)", store.AggFunc(100).String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestAggregate_Name(t *testing.T) {
+	if want, have := "count", (store.Aggregate{Func: store.AggCount}).Name(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "max_amount", (store.Aggregate{Func: store.AggMax, Prop: "amount"}).Name(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestAggregateEntities(t *testing.T) {
+	q := store.NewQuery().GroupBy("group").
+		Aggregate(store.AggCount, "").
+		Aggregate(store.AggCount, "score").
+		Aggregate(store.AggSum, "amount").
+		Aggregate(store.AggAvg, "amount").
+		Aggregate(store.AggMin, "amount").
+		Aggregate(store.AggMax, "amount")
+
+	rows, err := store.AggregateEntities(q, testAggEntities())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 3, len(rows); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+
+	// sorted by group-by values
+	b := rows[1]
+	if want, have := "b", b["group"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := int64(2), b["count"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := int64(1), b["count_score"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := float64(40), b["sum_amount"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := float64(20), b["avg_amount"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 10, b["min_amount"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 30, b["max_amount"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestAggregateEntities_sortPage(t *testing.T) {
+	q := store.NewQuery().GroupBy("group").
+		Aggregate(store.AggSum, "amount").
+		Sort("-sum_amount").SetLimit(2).SetOffset(1)
+
+	rows, err := store.AggregateEntities(q, testAggEntities())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(rows); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "c", rows[0]["group"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "a", rows[1]["group"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// without group-by, all entities are in 1 group
+	q = store.NewQuery().Aggregate(store.AggCount, "")
+	if rows, err = store.AggregateEntities(q, testAggEntities()); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := int64(5), rows[0]["count"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestAggregateEntities_error(t *testing.T) {
+	q := store.NewQuery().GroupBy("group").Sort("amount")
+	_, err := store.AggregateEntities(q, testAggEntities())
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if want, have := http.StatusBadRequest, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	q = store.NewQuery().Aggregate(store.AggSum, "group")
+	if _, err := store.AggregateEntities(q, testAggEntities()); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	q = store.NewQuery().GroupBy("nothing")
+	if _, err := store.AggregateEntities(q, testAggEntities()); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
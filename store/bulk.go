@@ -0,0 +1,183 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// BulkStore is a Store with native bulk operations
+type BulkStore interface {
+	Store
+
+	// CreateMany creates all entities in the list. The el is a
+	// pointer to slice of entities, as returned by AllocEntityList.
+	// Returns BulkError if any of the entities failed
+	CreateMany(c Conds, el EntityListPtr) error
+
+	// UpdateMany sets the fields (property name to value) of all
+	// entities matching the conditions. Other fields are left
+	// untouched
+	UpdateMany(c Conds, fields map[string]interface{}) error
+}
+
+// BulkError reports errors of items in a bulk operation.
+// Errors are in the order of items. Succeeded items
+// have nil error
+type BulkError []*StoreError
+
+// Failed returns the number of failed items
+func (errs BulkError) Failed() (n int) {
+	for _, err := range errs {
+		if err != nil {
+			n++
+		}
+	}
+	return
+}
+
+// Error implements the standard error type
+func (errs BulkError) Error() string {
+	return fmt.Sprintf("%d of %d items failed", errs.Failed(), len(errs))
+}
+
+// bulkErrors returns BulkError of the errors, or
+// nil if none of the errors is non-nil. Errors other
+// than StoreError are reported as internal server error
+func bulkErrors(errs []error) error {
+	berr := make(BulkError, len(errs))
+	failed := false
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		serr, ok := err.(*StoreError)
+		if !ok {
+			serr = Error(http.StatusInternalServerError,
+				http.StatusText(http.StatusInternalServerError)).
+				TellServer("%s", err)
+		}
+		berr[i], failed = serr, true
+	}
+	if !failed {
+		return nil
+	}
+	return berr
+}
+
+// CreateMany creates all entities in the list with the Store. The el
+// is a pointer to slice of entities, as returned by AllocEntityList.
+// Uses the native operation of BulkStore, if implemented. Otherwise
+// creates the entities one by one.
+//
+// Returns BulkError if any of the entities failed
+func CreateMany(s Store, c Conds, el EntityListPtr) error {
+	if bs, ok := s.(BulkStore); ok {
+		return bs.CreateMany(c, el)
+	}
+
+	list, err := bulkList(el)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, list.Len())
+	for i := range errs {
+		errs[i] = s.Create(c, list.Index(i).Addr().Interface())
+	}
+	return bulkErrors(errs)
+}
+
+// UpdateMany sets the fields (property name to value) of all entities
+// matching the conditions. Uses the native operation of BulkStore, if
+// implemented. Otherwise searches the entities, sets the fields and
+// updates the entities one by one by their "id" property.
+//
+// Returns 400 StoreError if any of the fields is not found, or
+// BulkError if any of the entities failed to update
+func UpdateMany(s Store, c Conds, fields map[string]interface{}) error {
+	if bs, ok := s.(BulkStore); ok {
+		return bs.UpdateMany(c, fields)
+	}
+
+	el := s.AllocEntityList()
+	res := s.Search(NewQuery().SetConds(c).SetIncludeDeleted(true))
+	defer res.Close()
+	if err := res.All(el); err != nil {
+		return err
+	}
+
+	list, err := bulkList(el)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, list.Len())
+	for i := range errs {
+		item := list.Index(i)
+		for name, v := range fields {
+			if err := setProp(item, name, v); err != nil {
+				return err
+			}
+		}
+		id, ok := propField(item, "id")
+		if !ok {
+			return Error(http.StatusInternalServerError,
+				http.StatusText(http.StatusInternalServerError)).
+				TellServer("property \"id\" not found in %s", item.Type())
+		}
+		errs[i] = s.Update(NewConds().Add("id", id.Interface()),
+			item.Addr().Interface())
+	}
+	return bulkErrors(errs)
+}
+
+// DeleteMany deletes all entities of the given ids
+func DeleteMany(s Store, ids []interface{}) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.Delete(NewConds().AddOp("id", In, ids))
+}
+
+// bulkList returns the slice value of the entity list pointer
+func bulkList(el EntityListPtr) (list reflect.Value, err error) {
+	list = reflect.ValueOf(el)
+	if list.Kind() != reflect.Ptr || list.IsNil() || list.Elem().Kind() != reflect.Slice ||
+		list.Elem().Type().Elem().Kind() != reflect.Struct {
+		err = Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("expected pointer to slice of struct, got %#v", el)
+		return
+	}
+	list = list.Elem()
+	return
+}
+
+// setProp sets the value to the property of the struct value
+func setProp(item reflect.Value, name string, v interface{}) error {
+	field, ok := propField(item, name)
+	if !ok {
+		return Error(http.StatusBadRequest, "unknown field %#v", name)
+	}
+	if v == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	// value of pointer field is set to a new pointer
+	val, typ := reflect.ValueOf(v), field.Type()
+	if typ.Kind() == reflect.Ptr && val.Type() != typ {
+		typ = typ.Elem()
+	}
+	if !val.Type().ConvertibleTo(typ) ||
+		(typ.Kind() == reflect.String && val.Kind() != reflect.String) {
+		return Error(http.StatusBadRequest, "invalid value %#v of field %#v", v, name)
+	}
+	if typ != field.Type() {
+		ptr := reflect.New(typ)
+		ptr.Elem().Set(val.Convert(typ))
+		field.Set(ptr)
+		return nil
+	}
+	field.Set(val.Convert(typ))
+	return nil
+}
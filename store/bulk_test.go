@@ -0,0 +1,181 @@
+package store_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+)
+
+type bulkEntity struct {
+	ID   string `db:"id,omitempty"`
+	Name string `db:"name"`
+	Age  int    `db:"age"`
+}
+
+// plainStore hides the native bulk operations of the inner
+// store and fails to create any entity named "bad"
+type plainStore struct {
+	store.Store
+}
+
+func (s plainStore) Create(c store.Conds, ep store.EntityPtr) error {
+	if e, ok := ep.(*bulkEntity); ok && e.Name == "bad" {
+		return store.Error(http.StatusBadRequest, "bad entity")
+	}
+	return s.Store.Create(c, ep)
+}
+
+func bulkStore(t *testing.T) store.Store {
+	conn, err := memstore.NewSource().Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	s, err := memstore.Provider("entity", &bulkEntity{})(conn.Raw())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	return s
+}
+
+func TestBulkStore(t *testing.T) {
+	var s store.BulkStore = &memstore.Store{}
+	_ = s
+	if _, ok := interface{}(plainStore{}).(store.BulkStore); ok {
+		t.Errorf("plainStore should not implement store.BulkStore")
+	}
+}
+
+func TestBulkError(t *testing.T) {
+	berr := store.BulkError{nil, store.ErrorNotFound, nil, store.ErrorInternal}
+	if want, have := 2, berr.Failed(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "2 of 4 items failed", berr.Error(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestCreateMany(t *testing.T) {
+	s := plainStore{bulkStore(t)}
+
+	el := &[]bulkEntity{{Name: "foo"}, {Name: "bar"}}
+	if err := store.CreateMany(s, nil, el); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	for i, e := range *el {
+		if e.ID == "" {
+			t.Errorf("id of item %d is not generated", i)
+		}
+	}
+
+	found := &[]bulkEntity{}
+	if err := s.Search(store.NewQuery()).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(*found); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestCreateMany_partial(t *testing.T) {
+	s := plainStore{bulkStore(t)}
+
+	el := &[]bulkEntity{{Name: "foo"}, {Name: "bad"}, {Name: "bar"}}
+	err := store.CreateMany(s, nil, el)
+	berr, ok := err.(store.BulkError)
+	if !ok {
+		t.Fatalf("expected store.BulkError, got %#v", err)
+	}
+	if want, have := 3, len(berr); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if berr[0] != nil || berr[2] != nil {
+		t.Errorf("expected items 0 and 2 to succeed, got %#v", berr)
+	}
+	if berr[1] == nil {
+		t.Fatalf("expected item 1 to fail")
+	}
+	if want, have := http.StatusBadRequest, berr[1].Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	found := &[]bulkEntity{}
+	if err := s.Search(store.NewQuery()).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(*found); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestUpdateMany(t *testing.T) {
+	s := plainStore{bulkStore(t)}
+
+	el := &[]bulkEntity{{Name: "foo", Age: 1}, {Name: "bar", Age: 1}, {Name: "baz", Age: 2}}
+	if err := store.CreateMany(s, nil, el); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	err := store.UpdateMany(s, store.NewConds().Add("age", 1),
+		map[string]interface{}{"name": "updated"})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	found := &[]bulkEntity{}
+	q := store.NewQuery().SetConds(store.NewConds().Add("name", "updated"))
+	if err := s.Search(q).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(*found); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	for _, e := range *found {
+		if want, have := 1, e.Age; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+
+	// unknown field and incompatible value
+	for _, fields := range []map[string]interface{}{
+		{"unknown": "foo"},
+		{"name": 42},
+	} {
+		err := store.UpdateMany(s, nil, fields)
+		if err == nil {
+			t.Errorf("expected error for %#v", fields)
+			continue
+		}
+		if want, have := http.StatusBadRequest, store.ExpandError(err).Status; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+}
+
+func TestDeleteMany(t *testing.T) {
+	s := bulkStore(t)
+
+	el := &[]bulkEntity{{ID: "1", Name: "foo"}, {ID: "2", Name: "bar"}, {ID: "3", Name: "baz"}}
+	if err := store.CreateMany(s, nil, el); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := store.DeleteMany(s, []interface{}{"1", "3"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := store.DeleteMany(s, nil); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	found := &[]bulkEntity{}
+	if err := s.Search(store.NewQuery()).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 1, len(*found); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "2", (*found)[0].ID; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
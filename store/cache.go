@@ -0,0 +1,517 @@
+package store
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// CacheBackend is the interface of storage of cached
+// results for stores (see NewCacheStore)
+type CacheBackend interface {
+
+	// Get returns the value cached with the key, if any
+	Get(key string) (v interface{}, ok bool)
+
+	// Set caches the value with the key
+	Set(key string, v interface{})
+
+	// Purge removes all cached values
+	Purge()
+}
+
+// NewLRUCache returns a CacheBackend which keeps at most size values,
+// evicting the least recently used ones, and expires values cached
+// longer than the ttl. Zero size or ttl means no limit
+func NewLRUCache(size int, ttl time.Duration) CacheBackend {
+	return &lruCache{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// lruEntry is a cached value in lruCache
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// lruCache implements CacheBackend with LRU eviction and TTL
+type lruCache struct {
+	mux   sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[string]*list.Element
+	order *list.List
+}
+
+// Get implements CacheBackend
+func (c *lruCache) Get(key string) (v interface{}, ok bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return
+	}
+	entry := elem.Value.(*lruEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set implements CacheBackend
+func (c *lruCache) Set(key string, v interface{}) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	entry := &lruEntry{key: key, value: v, expires: time.Now().Add(c.ttl)}
+	if elem, ok := c.items[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(entry)
+	for c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Purge implements CacheBackend
+func (c *lruCache) Purge() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// cacheGen is the purge generation of a CacheBackend. Values loaded
+// before a purge are stale, so they are cached only if no purge
+// happened since the loading started (see cacheGen.set)
+type cacheGen struct {
+	mux sync.Mutex
+	n   uint64
+}
+
+// cacheGens are the generations of backends used by cache stores.
+// Backends of types not comparable share the generation of nil
+var cacheGens = struct {
+	sync.Mutex
+	m map[CacheBackend]*cacheGen
+}{m: make(map[CacheBackend]*cacheGen)}
+
+// genOf returns the purge generation of the backend
+func genOf(cache CacheBackend) *cacheGen {
+	if !reflect.TypeOf(cache).Comparable() {
+		cache = nil
+	}
+	cacheGens.Lock()
+	defer cacheGens.Unlock()
+	g, ok := cacheGens.m[cache]
+	if !ok {
+		g = &cacheGen{}
+		cacheGens.m[cache] = g
+	}
+	return g
+}
+
+// current returns the current generation
+func (g *cacheGen) current() uint64 {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return g.n
+}
+
+// set caches the value to the backend, unless it
+// has been purged since the generation given
+func (g *cacheGen) set(cache CacheBackend, gen uint64, key string, v interface{}) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if g.n == gen {
+		cache.Set(key, v)
+	}
+}
+
+// purgeCache purges the backend and starts a new generation
+func purgeCache(cache CacheBackend) {
+	g := genOf(cache)
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.n++
+	cache.Purge()
+}
+
+// CondsKey returns the canonical string encoding of the conditions.
+// Conditions differ only in order are encoded the same
+func CondsKey(c Conds) string {
+	if c == nil || len(c.GetAll()) == 0 {
+		return "()"
+	}
+	strs := make([]string, 0, len(c.GetAll()))
+	for _, cond := range c.GetAll() {
+		if sub, ok := cond.Value.(Conds); ok && cond.Prop == "" {
+			strs = append(strs, CondsKey(sub))
+			continue
+		}
+		strs = append(strs, fmt.Sprintf("%s %s %#v", cond.Prop, cond.Op, cond.Value))
+	}
+	sort.Strings(strs)
+	rel := "and"
+	if c.GetRel() == Or {
+		rel = "or"
+	}
+	return rel + "(" + strings.Join(strs, ", ") + ")"
+}
+
+// QueryKey returns the canonical string encoding of the query
+// (see CondsKey). Queries of the same result are encoded the same
+func QueryKey(q Query) string {
+	if q == nil {
+		return "query()"
+	}
+	sorts := make([]string, 0)
+	if q.GetSorts() != nil {
+		for _, s := range q.GetSorts().GetAll() {
+			sorts = append(sorts, s.String())
+		}
+	}
+	aggs := make([]string, 0, len(q.GetAggregates()))
+	for _, agg := range q.GetAggregates() {
+		aggs = append(aggs, agg.Name())
+	}
+	cursor := ""
+	if c := q.GetCursor(); c != nil {
+		cursor = fmt.Sprintf("%#v", *c)
+	}
+	return fmt.Sprintf("query(%s, sort%q, limit %d, offset %d, cursor %s, fields%q, group%q, agg%q, deleted %t)",
+		CondsKey(q.GetConds()), sorts, q.GetLimit(), q.GetOffset(), cursor,
+		q.GetFields(), q.GetGroupBy(), aggs, q.GetIncludeDeleted())
+}
+
+// NewCacheStore returns the Store wrapped to cache results of One and
+// of All and Count of Search in the backend, keyed on the canonical
+// encoding of the conditions or query (see CondsKey and QueryKey).
+// Entities are deep copied into and out of the backend, so changes to
+// entities returned would not change the cached values.
+//
+// All values in the backend are purged on writes (including bulk
+// operations and Upsert) through the wrapped Store, and results
+// loaded while a purge happens are not cached. Writes through
+// other stores are not noticed, so the backend should not be shared
+// across store keys, and values could be stale up to the TTL of the
+// backend.
+//
+// For stores obtained through Get, hooks (see HookFactory) are
+// invoked outside of the cache, so AfterLoad hooks also run on
+// cached results
+func NewCacheStore(s Store, cache CacheBackend) Store {
+	return &cacheStore{Store: s, cache: cache}
+}
+
+// cacheStore implements Store with read-through cache.
+//
+// It implements BulkStore, UpsertStore and ContextStore with the
+// native operations of the wrapped Store, if any
+type cacheStore struct {
+	Store
+	cache CacheBackend
+
+	// ns is the namespace of the cache keys, if any
+	// (e.g. of the tenant routed to its source)
+	ns string
+
+	// stores of the context the Store is obtained from, if any.
+	// Cache is bypassed in transaction of the stores, and purged
+	// again when the transaction ends
+	sts *stores
+}
+
+// bypass returns whether the cache should be bypassed
+func (s *cacheStore) bypass() bool {
+	return s.sts != nil && s.sts.inTransaction()
+}
+
+// key returns the cache key of the operation
+// and encoded conditions or query
+func (s *cacheStore) key(op, str string) string {
+	return fmt.Sprintf("%s%T %s %s", s.ns, s.AllocEntity(), op, str)
+}
+
+// invalidate purges the cache after a write
+func (s *cacheStore) invalidate() {
+	purgeCache(s.cache)
+	if s.sts != nil {
+		s.sts.addPurge(s.cache)
+	}
+}
+
+// Create implements Store
+func (s *cacheStore) Create(c Conds, ep EntityPtr) error {
+	defer s.invalidate()
+	return s.Store.Create(c, ep)
+}
+
+// Search implements Store
+func (s *cacheStore) Search(q Query) Result {
+	if s.bypass() {
+		return s.Store.Search(q)
+	}
+	return &cacheResult{store: s, query: q, qkey: QueryKey(q)}
+}
+
+// One implements Store
+func (s *cacheStore) One(c Conds, ep EntityPtr) (err error) {
+	return s.one(c, ep, func() error {
+		return s.Store.One(c, ep)
+	})
+}
+
+// Update implements Store
+func (s *cacheStore) Update(c Conds, ep EntityPtr) error {
+	defer s.invalidate()
+	return s.Store.Update(c, ep)
+}
+
+// Delete implements Store
+func (s *cacheStore) Delete(c Conds) error {
+	defer s.invalidate()
+	return s.Store.Delete(c)
+}
+
+// CreateMany implements BulkStore
+func (s *cacheStore) CreateMany(c Conds, el EntityListPtr) error {
+	defer s.invalidate()
+	return CreateMany(s.Store, c, el)
+}
+
+// UpdateMany implements BulkStore
+func (s *cacheStore) UpdateMany(c Conds, fields map[string]interface{}) error {
+	defer s.invalidate()
+	return UpdateMany(s.Store, c, fields)
+}
+
+// Upsert implements UpsertStore
+func (s *cacheStore) Upsert(c Conds, ep EntityPtr) error {
+	defer s.invalidate()
+	return Upsert(s.Store, c, ep)
+}
+
+// CreateContext implements ContextStore
+func (s *cacheStore) CreateContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	defer s.invalidate()
+	return NewContextStore(s.Store).CreateContext(ctx, c, ep)
+}
+
+// SearchContext implements ContextStore
+func (s *cacheStore) SearchContext(ctx context.Context, q Query) Result {
+	if s.bypass() {
+		return NewContextStore(s.Store).SearchContext(ctx, q)
+	}
+	return &cacheResult{store: s, query: q, qkey: QueryKey(q), ctx: ctx}
+}
+
+// OneContext implements ContextStore
+func (s *cacheStore) OneContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	return s.one(c, ep, func() error {
+		return NewContextStore(s.Store).OneContext(ctx, c, ep)
+	})
+}
+
+// UpdateContext implements ContextStore
+func (s *cacheStore) UpdateContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	defer s.invalidate()
+	return NewContextStore(s.Store).UpdateContext(ctx, c, ep)
+}
+
+// DeleteContext implements ContextStore
+func (s *cacheStore) DeleteContext(ctx context.Context, c Conds) error {
+	defer s.invalidate()
+	return NewContextStore(s.Store).DeleteContext(ctx, c)
+}
+
+// one sets the cached entity of the conditions to ep, if any.
+// Otherwise it loads the entity by op and caches it, unless
+// the cache is purged by writes while loading
+func (s *cacheStore) one(c Conds, ep EntityPtr, op func() error) (err error) {
+	if s.bypass() {
+		return op()
+	}
+	key := s.key("one", CondsKey(c))
+	if v, ok := s.cache.Get(key); ok && reflect.TypeOf(v) == reflect.TypeOf(ep) {
+		setPtr(ep, deepCopy(v))
+		return
+	}
+	g := genOf(s.cache)
+	gen := g.current()
+	if err = op(); err != nil {
+		return
+	}
+	g.set(s.cache, gen, key, deepCopy(ep))
+	return
+}
+
+// cacheResult implements Result with All and Count cached.
+// The underlying search is done only when needed, with
+// the context if not nil
+type cacheResult struct {
+	store *cacheStore
+	query Query
+	qkey  string
+	ctx   context.Context
+	res   Result
+}
+
+// result returns the result of the underlying search
+func (res *cacheResult) result() Result {
+	if res.res != nil {
+		return res.res
+	}
+	if res.ctx != nil {
+		res.res = NewContextStore(res.store.Store).SearchContext(res.ctx, res.query)
+	} else {
+		res.res = res.store.Store.Search(res.query)
+	}
+	return res.res
+}
+
+// All implements Result
+func (res *cacheResult) All(el interface{}) (err error) {
+	key := res.store.key("all", res.qkey)
+	if v, ok := res.store.cache.Get(key); ok && reflect.TypeOf(v) == reflect.TypeOf(el) {
+		setPtr(el, deepCopy(v))
+		return
+	}
+	g := genOf(res.store.cache)
+	gen := g.current()
+	if err = res.result().All(el); err != nil {
+		return
+	}
+	g.set(res.store.cache, gen, key, deepCopy(el))
+	return
+}
+
+// Count implements Result
+func (res *cacheResult) Count() (count uint64, err error) {
+	key := res.store.key("count", res.qkey)
+	if v, ok := res.store.cache.Get(key); ok {
+		if count, ok = v.(uint64); ok {
+			return
+		}
+	}
+	g := genOf(res.store.cache)
+	gen := g.current()
+	if count, err = res.result().Count(); err != nil {
+		return
+	}
+	g.set(res.store.cache, gen, key, count)
+	return
+}
+
+// Raw implements Result
+func (res *cacheResult) Raw() (interface{}, error) {
+	return res.result().Raw()
+}
+
+// Aggregate implements Result
+func (res *cacheResult) Aggregate() ([]AggregateRow, error) {
+	return res.result().Aggregate()
+}
+
+// Next implements Result
+func (res *cacheResult) Next(ep EntityPtr) bool {
+	return res.result().Next(ep)
+}
+
+// Err implements Result
+func (res *cacheResult) Err() error {
+	if res.res == nil {
+		return nil
+	}
+	return res.res.Err()
+}
+
+// Close implements Result
+func (res *cacheResult) Close() error {
+	if res.res == nil {
+		return nil
+	}
+	return res.res.Close()
+}
+
+// deepCopy returns a deep copy of the value, which is usually
+// a pointer to entity or entity list. Values should not be cyclic
+func deepCopy(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return copyValue(reflect.ValueOf(v)).Interface()
+}
+
+// copyValue returns a deep copy of the value. Pointers, slices, maps
+// and interfaces are copied recursively, except in unexported fields
+// of struct, which are copied as is
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		if v.Kind() == reflect.Interface {
+			cp := reflect.New(v.Type()).Elem()
+			cp.Set(copyValue(v.Elem()))
+			return cp
+		}
+		cp := reflect.New(v.Elem().Type())
+		cp.Elem().Set(copyValue(v.Elem()))
+		return cp
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return cp
+	case reflect.Array:
+		cp := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return cp
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeMap(v.Type())
+		for _, key := range v.MapKeys() {
+			cp.SetMapIndex(key, copyValue(v.MapIndex(key)))
+		}
+		return cp
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				cp.Field(i).Set(copyValue(v.Field(i)))
+			}
+		}
+		return cp
+	}
+	return v
+}
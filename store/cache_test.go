@@ -0,0 +1,387 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+	"golang.org/x/net/context"
+)
+
+// countStore counts the reads reaching the inner store
+type countStore struct {
+	store.Store
+	reads int
+}
+
+func (s *countStore) One(c store.Conds, ep store.EntityPtr) error {
+	s.reads++
+	return s.Store.One(c, ep)
+}
+
+func (s *countStore) Search(q store.Query) store.Result {
+	s.reads++
+	return s.Store.Search(q)
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := store.NewLRUCache(2, 0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a")
+	cache.Set("c", 3)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("expected least recently used \"b\" to be evicted")
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if have, ok := cache.Get(key); !ok {
+			t.Errorf("expected %#v to be cached", key)
+		} else if want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+
+	cache.Purge()
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("expected cache to be purged")
+	}
+}
+
+func TestLRUCache_ttl(t *testing.T) {
+	cache := store.NewLRUCache(0, 10*time.Millisecond)
+	cache.Set("a", 1)
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("expected \"a\" to be cached")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("expected \"a\" to be expired")
+	}
+}
+
+func TestCondsKey(t *testing.T) {
+	c1 := store.NewConds().Add("name", "foo").AddOp("age", store.Gte, 18)
+	c2 := store.NewConds().AddOp("age", store.Gte, 18).Add("name", "foo")
+	if want, have := store.CondsKey(c1), store.CondsKey(c2); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := `and(age >= 18, name = "foo")`, store.CondsKey(c1); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	c3 := store.NewConds().SetRel(store.Or).Add("name", "foo").AddOp("age", store.Gte, 18)
+	if store.CondsKey(c1) == store.CondsKey(c3) {
+		t.Errorf("expected keys of different relation to differ")
+	}
+	if want, have := store.CondsKey(nil), store.CondsKey(store.NewConds()); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestQueryKey(t *testing.T) {
+	q1 := store.NewQuery().SetConds(store.NewConds().Add("a", 1).Add("b", 2))
+	q2 := store.NewQuery().SetConds(store.NewConds().Add("b", 2).Add("a", 1))
+	if want, have := store.QueryKey(q1), store.QueryKey(q2); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	for _, q := range []store.Query{
+		store.NewQuery().SetConds(store.NewConds().Add("a", 1).Add("b", 2)).SetLimit(10),
+		store.NewQuery().SetConds(store.NewConds().Add("a", 1).Add("b", 2)).Sort("-a"),
+		store.NewQuery().SetConds(store.NewConds().Add("a", 1).Add("b", 2)).SetIncludeDeleted(true),
+	} {
+		if store.QueryKey(q1) == store.QueryKey(q) {
+			t.Errorf("expected key of %#v to differ", store.QueryKey(q))
+		}
+	}
+}
+
+func TestCacheStore(t *testing.T) {
+	inner := &countStore{Store: bulkStore(t)}
+	s := store.NewCacheStore(inner, store.NewLRUCache(10, time.Minute))
+
+	if err := s.Create(nil, &bulkEntity{ID: "1", Name: "foo"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// cached One
+	for i := 0; i < 2; i++ {
+		e := &bulkEntity{}
+		if err := s.One(store.NewConds().Add("id", "1"), e); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if want, have := "foo", e.Name; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+	if want, have := 1, inner.reads; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// cached Search
+	for i := 0; i < 2; i++ {
+		el := &[]bulkEntity{}
+		if err := s.Search(store.NewQuery()).All(el); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if want, have := 1, len(*el); want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+		(*el)[0].Name = "modified" // should not affect the cache
+	}
+	if want, have := 2, inner.reads; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// invalidated on update
+	if err := s.Update(store.NewConds().Add("id", "1"), &bulkEntity{ID: "1", Name: "bar"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	e := &bulkEntity{}
+	if err := s.One(store.NewConds().Add("id", "1"), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "bar", e.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	el := &[]bulkEntity{}
+	if err := s.Search(store.NewQuery()).All(el); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "bar", (*el)[0].Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 4, inner.reads; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// invalidated on delete
+	if err := s.Delete(store.NewConds().Add("id", "1")); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.One(store.NewConds().Add("id", "1"), &bulkEntity{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+// staleStore writes through the writer after loading,
+// as writes done concurrently with the load
+type staleStore struct {
+	store.Store
+	writer store.Store
+	t      *testing.T
+}
+
+func (s *staleStore) write() {
+	if err := s.writer.Update(store.NewConds().Add("id", "1"),
+		&bulkEntity{ID: "1", Name: "bar"}); err != nil {
+		s.t.Fatalf("unexpected error: %#v", err.Error())
+	}
+}
+
+func (s *staleStore) One(c store.Conds, ep store.EntityPtr) error {
+	defer s.write()
+	return s.Store.One(c, ep)
+}
+
+func (s *staleStore) Search(q store.Query) store.Result {
+	return &staleResult{s.Store.Search(q), s}
+}
+
+type staleResult struct {
+	store.Result
+	s *staleStore
+}
+
+func (res *staleResult) All(el interface{}) error {
+	defer res.s.write()
+	return res.Result.All(el)
+}
+
+func TestCacheStore_staleFill(t *testing.T) {
+	inner := bulkStore(t)
+	if err := inner.Create(nil, &bulkEntity{ID: "1", Name: "foo"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	cache := store.NewLRUCache(10, time.Minute)
+	s := store.NewCacheStore(&staleStore{
+		Store:  inner,
+		writer: store.NewCacheStore(inner, cache),
+		t:      t,
+	}, cache)
+
+	// values loaded before the write are not cached
+	if err := s.One(store.NewConds().Add("id", "1"), &bulkEntity{}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	e := &bulkEntity{}
+	if err := store.NewCacheStore(inner, cache).One(store.NewConds().Add("id", "1"), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "bar", e.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if err := inner.Update(store.NewConds().Add("id", "1"), &bulkEntity{ID: "1", Name: "foo"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Search(store.NewQuery()).All(&[]bulkEntity{}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	el := &[]bulkEntity{}
+	if err := store.NewCacheStore(inner, cache).Search(store.NewQuery()).All(el); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "bar", (*el)[0].Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestGet_cache(t *testing.T) {
+	cache := store.NewLRUCache(10, time.Minute)
+	factory := store.NewFactory()
+	factory.(store.CacheFactory).SetCache(hookKey(0), cache)
+	ctx := hookContext(factory)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, hookKey(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Create(nil, &bulkEntity{ID: "1", Name: "foo"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if n, err := s.Search(store.NewQuery()).Count(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	} else if want, have := uint64(1), n; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// results are cached across contexts of the factory
+	ctx2 := store.WithFactory(context.Background(), factory)
+	defer store.CloseAllIn(ctx2)
+	s2, err := store.Get(ctx2, hookKey(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if n, err := s2.Search(store.NewQuery()).Count(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	} else if want, have := uint64(1), n; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestCacheStore_bulk(t *testing.T) {
+	inner := &countStore{Store: bulkStore(t)}
+	s := store.NewCacheStore(inner, store.NewLRUCache(10, time.Minute))
+	if _, ok := s.(store.BulkStore); !ok {
+		t.Errorf("expected store.BulkStore, got %#v", s)
+	}
+
+	if err := store.CreateMany(s, nil, &[]bulkEntity{{ID: "1", Name: "foo"}}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	e := &bulkEntity{}
+	if err := s.One(store.NewConds().Add("id", "1"), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// invalidated on bulk update
+	if err := store.UpdateMany(s, store.NewConds().Add("id", "1"),
+		map[string]interface{}{"name": "bar"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.One(store.NewConds().Add("id", "1"), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "bar", e.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestGet_cacheHooks(t *testing.T) {
+	factory := store.NewFactory()
+	factory.(store.CacheFactory).SetCache(hookKey(0), store.NewLRUCache(10, time.Minute))
+	loads := 0
+	factory.(store.HookFactory).AddHook(hookKey(0), store.AfterLoad,
+		func(ctx context.Context, c store.Conds, ep store.EntityPtr) error {
+			loads++
+			ep.(*bulkEntity).Name += " loaded"
+			return nil
+		})
+	ctx := hookContext(factory)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, hookKey(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Create(nil, &bulkEntity{ID: "1", Name: "foo"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// hooks run on cached results, which are not changed by the hooks
+	for i := 0; i < 2; i++ {
+		e := &bulkEntity{}
+		if err := s.One(store.NewConds().Add("id", "1"), e); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if want, have := "foo loaded", e.Name; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+	if want, have := 2, loads; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+// tagEntity is an entity with reference field
+type tagEntity struct {
+	ID   string   `db:"id"`
+	Tags []string `db:"tags"`
+}
+
+func TestCacheStore_deepCopy(t *testing.T) {
+	conn, err := memstore.NewSource().Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	inner, err := memstore.Provider("tag", &tagEntity{})(conn.Raw())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	s := store.NewCacheStore(inner, store.NewLRUCache(10, time.Minute))
+	if err := s.Create(nil, &tagEntity{ID: "1", Tags: []string{"foo"}}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// cache the results
+	if err := s.One(store.NewConds().Add("id", "1"), &tagEntity{}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Search(store.NewQuery()).All(&[]tagEntity{}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// changes to cached results do not change the cache
+	for i := 0; i < 2; i++ {
+		e := &tagEntity{}
+		if err := s.One(store.NewConds().Add("id", "1"), e); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if want, have := "foo", e.Tags[0]; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+		e.Tags[0] = "modified"
+
+		el := &[]tagEntity{}
+		if err := s.Search(store.NewQuery()).All(el); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if want, have := "foo", (*el)[0].Tags[0]; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+		(*el)[0].Tags[0] = "modified"
+	}
+}
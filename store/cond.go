@@ -9,6 +9,58 @@ const (
 	Or
 )
 
+// Op is the comparison operator of a Cond
+type Op int
+
+// comparison operators of Cond
+//
+// In and NotIn takes a slice of values.
+// Like takes a pattern string with "%" and "_" wildcards.
+// Prefix takes a string which the property should starts with,
+// compared byte by byte ("%" and "_" are not wildcards).
+// IsNull and NotNull ignore the value.
+// Between takes a slice of exactly 2 values (inclusive)
+const (
+	Eq Op = iota
+	Ne
+	Gt
+	Gte
+	Lt
+	Lte
+	In
+	NotIn
+	Like
+	Prefix
+	IsNull
+	NotNull
+	Between
+)
+
+// opStrings maps Op to its string representation
+var opStrings = map[Op]string{
+	Eq:      "=",
+	Ne:      "!=",
+	Gt:      ">",
+	Gte:     ">=",
+	Lt:      "<",
+	Lte:     "<=",
+	In:      "IN",
+	NotIn:   "NOT IN",
+	Like:    "LIKE",
+	Prefix:  "PREFIX",
+	IsNull:  "IS NULL",
+	NotNull: "IS NOT NULL",
+	Between: "BETWEEN",
+}
+
+// String implements Stringer
+func (op Op) String() string {
+	if str, ok := opStrings[op]; ok {
+		return str
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
 // Conds is the general interface represents conditions
 // all setters return itself so the operation can
 // cascade
@@ -20,9 +72,12 @@ type Conds interface {
 	// GetRel is getter of relation flag
 	GetRel() int
 
-	// Add adds a condition
+	// Add adds an equality condition
 	Add(string, interface{}) Conds
 
+	// AddOp adds a condition with the given comparison operator
+	AddOp(string, Op, interface{}) Conds
+
 	// GetAll gets the list of conditions
 	GetAll() []Cond
 
@@ -39,6 +94,7 @@ type Conds interface {
 type Cond struct {
 	Prop  string
 	Value interface{}
+	Op    Op
 }
 
 // NewConds creates Conds with a BasicConds
@@ -65,7 +121,7 @@ func (c *BasicConds) GetRel() int {
 	return c.Rel
 }
 
-// Add adds a condition
+// Add adds an equality condition
 func (c *BasicConds) Add(prop string, value interface{}) Conds {
 	c.Conds = append(c.Conds, Cond{
 		Prop:  prop,
@@ -74,6 +130,16 @@ func (c *BasicConds) Add(prop string, value interface{}) Conds {
 	return c
 }
 
+// AddOp adds a condition with the given comparison operator
+func (c *BasicConds) AddOp(prop string, op Op, value interface{}) Conds {
+	c.Conds = append(c.Conds, Cond{
+		Prop:  prop,
+		Value: value,
+		Op:    op,
+	})
+	return c
+}
+
 // GetAll gets the list of conditions
 func (c *BasicConds) GetAll() []Cond {
 	return c.Conds
@@ -81,14 +147,31 @@ func (c *BasicConds) GetAll() []Cond {
 
 // GetMap gets the list of conditions in the
 // form of map[string]interface{}
+//
+// Conditions with operator other than Eq are mapped
+// with key of upper.io style "prop op" (e.g. "age >=").
+// IsNull, NotNull, Between and Prefix cannot be mapped. A Prefix
+// would become a LIKE pattern with "%" and "_" of the value taken
+// as wildcards, which is not what Prefix means.
 func (c *BasicConds) GetMap() (m map[string]interface{}, err error) {
 	m = make(map[string]interface{})
 	for _, cond := range c.Conds {
-		if exists, ok := m[cond.Prop]; ok {
+		key, value := cond.Prop, cond.Value
+		switch cond.Op {
+		case Eq:
+			// use the prop as is
+		case Ne, Gt, Gte, Lt, Lte, In, NotIn, Like:
+			key = cond.Prop + " " + cond.Op.String()
+		default:
+			err = fmt.Errorf("\"%s\" with operator %s cannot be mapped",
+				cond.Prop, cond.Op)
+			continue
+		}
+		if exists, ok := m[key]; ok {
 			err = fmt.Errorf("\"%s\" is mapped to both \"%#v\" and \"%#v\"",
-				cond.Prop, exists, cond.Value)
+				key, exists, value)
 		}
-		m[cond.Prop] = cond.Value
+		m[key] = value
 	}
 	return
 }
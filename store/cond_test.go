@@ -83,3 +83,70 @@ func TestBasicConds_SetGetRel(t *testing.T) {
 		t.Log("Conds Rel changed to Or")
 	}
 }
+
+func TestBasicConds_AddOp(t *testing.T) {
+	t.Parallel()
+	c := store.NewConds().Add("foo", "bar").AddOp("age", store.Gte, 18)
+	a := c.GetAll()
+
+	if want, have := store.Eq, a[0].Op; want != have {
+		t.Errorf("want: %s, got: %s", want, have)
+	}
+	if want, have := "age", a[1].Prop; want != have {
+		t.Errorf("want: %#v, got: %#v", want, have)
+	}
+	if want, have := store.Gte, a[1].Op; want != have {
+		t.Errorf("want: %s, got: %s", want, have)
+	}
+	if want, have := 18, a[1].Value; want != have {
+		t.Errorf("want: %#v, got: %#v", want, have)
+	}
+}
+
+func TestOp_String(t *testing.T) {
+	t.Parallel()
+	tests := map[store.Op]string{
+		store.Eq:      "=",
+		store.Ne:      "!=",
+		store.Gte:     ">=",
+		store.NotIn:   "NOT IN",
+		store.NotNull: "IS NOT NULL",
+		store.Op(-1):  "Op(-1)",
+	}
+	for op, want := range tests {
+		if have := op.String(); want != have {
+			t.Errorf("want: %#v, got: %#v", want, have)
+		}
+	}
+}
+
+func TestBasicConds_GetMapOp(t *testing.T) {
+	t.Parallel()
+	c := store.NewConds().
+		Add("foo", "bar").
+		AddOp("age", store.Gte, 18).
+		AddOp("status", store.In, []string{"a", "b"})
+	m, err := c.GetMap()
+	if err != nil {
+		t.Fatalf("Error in GetMap(): %s", err.Error())
+	}
+
+	if want, have := "bar", m["foo"]; want != have {
+		t.Errorf("want: %#v, got: %#v", want, have)
+	}
+	if want, have := 18, m["age >="]; want != have {
+		t.Errorf("want: %#v, got: %#v", want, have)
+	}
+	if _, ok := m["status IN"].([]string); !ok {
+		t.Errorf("want: []string, got: %#v", m["status IN"])
+	}
+
+	for _, c := range []store.Conds{
+		store.NewConds().AddOp("deleted", store.IsNull, nil),
+		store.NewConds().AddOp("name", store.Prefix, "jo_"),
+	} {
+		if _, err := c.GetMap(); err == nil {
+			t.Errorf("Failed to return error with unmappable operator")
+		}
+	}
+}
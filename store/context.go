@@ -2,6 +2,7 @@ package store
 
 import (
 	"fmt"
+	"sync"
 
 	"golang.org/x/net/context"
 )
@@ -17,12 +18,24 @@ const (
 // WithFactory attachs a factory to the context
 func WithFactory(parent context.Context, factory Factory) context.Context {
 
-	return context.WithValue(parent,
-		storesKey, &stores{factory, make(map[interface{}]Conn)})
+	return context.WithValue(parent, storesKey, newStores(factory))
 }
 
 // Get try to connect to a store with provided source
-// and provider definition. If fail, return nil and error
+// and provider definition. If fail, return nil and error.
+//
+// The Store returned would not be the type provided by the Provider,
+// but a wrapper of it, if the factory has any of these for the store:
+//
+//   - metrics (see MetricsFactory) recording the operations
+//   - cache (see CacheFactory) of results, bypassed in transaction
+//   - hooks (see HookFactory) invoked on the operations, including
+//     AfterLoad on cached results
+//   - ChangeBus (see FeedFactory) to publish ChangeEvent of
+//     successful writes. Events of writes in a transaction (see Begin)
+//     are published on Commit, or discarded on Rollback
+//   - tenancy (see NewTenantFactory) to scope the operations by the
+//     tenant in the context
 func Get(ctx context.Context,
 	key interface{}) (s Store, err error) {
 
@@ -32,12 +45,46 @@ func Get(ctx context.Context,
 		return
 	}
 
-	stores := v.(Stores)
-	s, err = stores.Get(key)
+	sts := v.(*stores)
+	if s, err = sts.get(ctx, key); err != nil {
+		return
+	}
+	if m := sts.metrics(); m != nil {
+		s = m.Store(key, s)
+	}
+	if cache := sts.cache(key); cache != nil {
+		cs := &cacheStore{Store: s, cache: cache, sts: sts}
+		if tf, ok := sts.factory.(*tenantFactory); ok {
+			cs.ns = tf.cacheKey(ctx)
+		}
+		s = cs
+	}
+	s = WithHooks(ctx, s, sts.hooks(key))
+	if bus := sts.bus(); bus != nil {
+		s = &feedStore{Store: s, key: key, bus: bus, publish: sts.publish}
+	}
+	if tf, ok := sts.factory.(*tenantFactory); ok {
+		s, err = tf.scope(ctx, key, s)
+	}
+	return
+}
+
+// GetBound is like Get, but the Store returned is bound to the
+// context. All operations of it would abort when the context is done.
+//
+// Please note that the Store returned would not be the type
+// provided by the Provider, but a wrapper of it
+func GetBound(ctx context.Context, key interface{}) (s Store, err error) {
+	if s, err = Get(ctx, key); err != nil {
+		return
+	}
+	s = Bind(ctx, NewContextStore(s))
 	return
 }
 
-// CloseAllIn close all Store connections in the context
+// CloseAllIn close all Store connections in the context, after the
+// operations in flight on them are done. Get on the context fails
+// afterwards
 func CloseAllIn(ctx context.Context) {
 
 	v := ctx.Value(storesKey)
@@ -49,11 +96,49 @@ func CloseAllIn(ctx context.Context) {
 	stores.Close()
 }
 
+// Begin starts a transaction on all Store connections in the context.
+// Connections opened by Get afterwards will also join the transaction
+// until Commit or Rollback. Stores obtained before Begin run their later
+// operations in the transaction as well.
+//
+// Transaction on different sources are separated. There is no guarantee
+// of atomicity across sources
+func Begin(ctx context.Context) (err error) {
+	v := ctx.Value(storesKey)
+	if v == nil {
+		err = fmt.Errorf("Stores not in context")
+		return
+	}
+	return v.(Stores).Begin()
+}
+
+// Commit commits the transaction of all Store connections in the context
+func Commit(ctx context.Context) (err error) {
+	v := ctx.Value(storesKey)
+	if v == nil {
+		err = fmt.Errorf("Stores not in context")
+		return
+	}
+	return v.(Stores).Commit()
+}
+
+// Rollback aborts the transaction of all Store connections in the context
+func Rollback(ctx context.Context) (err error) {
+	v := ctx.Value(storesKey)
+	if v == nil {
+		err = fmt.Errorf("Stores not in context")
+		return
+	}
+	return v.(Stores).Rollback()
+}
+
 // Stores is an interface for store
 // with connection pool management.
 //
 // Each HTTP request should have its
-// own Stores instance in the context
+// own Stores instance in the context.
+// The Stores of WithFactory is safe for
+// concurrent use by multiple goroutines
 type Stores interface {
 
 	// Connect connects a provider at a source
@@ -62,15 +147,131 @@ type Stores interface {
 
 	// Close close all Conn in the set
 	Close()
+
+	// Begin starts transaction on all Conn in the set, and
+	// on all Conn to be opened until Commit or Rollback
+	Begin() error
+
+	// Commit commits transaction on all Conn in the set
+	Commit() error
+
+	// Rollback aborts transaction on all Conn in the set
+	Rollback() error
 }
 
+// stores implements Stores. It is safe for concurrent use
 type stores struct {
 	factory Factory
-	conns   map[interface{}]Conn
+	mux     sync.Mutex
+	done    *sync.Cond // signaled when connection released
+	conns   map[interface{}]*sharedConn
+	closed  bool
+	inTx    bool
+	pending []ChangeEvent
+	purges  []CacheBackend
+
+	// sessions counts the changes of transaction, on which
+	// Raw of the connections might change (see sharedStore)
+	sessions uint64
+
+	// replicas picked and pinned for reads, by primary source key
+	replicas map[interface{}]interface{}
+	pinned   map[interface{}]bool
+	unload   []func()
+}
+
+// sharedConn is a Conn shared by all stores of the source
+// in a Stores. The Conn is opened once by the first Get of
+// the source, while other Get wait for it (see stores.get)
+type sharedConn struct {
+	conn   Conn
+	err    error
+	opened chan bool // closed when conn opened or failed
+
+	// refs is the number of operations in flight
+	// on the stores of the connection
+	refs int
+
+	// readOnly is true if the connection is opened only
+	// to read from a replica, which would not join transaction
+	readOnly bool
+}
+
+// newStores returns an empty stores of the factory
+func newStores(factory Factory) *stores {
+	sts := &stores{
+		factory:  factory,
+		conns:    make(map[interface{}]*sharedConn),
+		replicas: make(map[interface{}]interface{}),
+		pinned:   make(map[interface{}]bool),
+	}
+	sts.done = sync.NewCond(&sts.mux)
+	return sts
+}
+
+// base returns the factory, or the factory wrapped by tenancy,
+// for its optional interfaces (e.g. HookFactory)
+func (sts *stores) base() Factory {
+	if tf, ok := sts.factory.(*tenantFactory); ok {
+		return tf.Factory
+	}
+	return sts.factory
+}
+
+// hooks returns the hooks of the store key, if any
+func (sts *stores) hooks(key interface{}) Hooks {
+	if f, ok := sts.base().(HookFactory); ok {
+		return f.Hooks(key)
+	}
+	return nil
+}
+
+// bus returns the ChangeBus of the factory, if any
+func (sts *stores) bus() *ChangeBus {
+	if f, ok := sts.base().(FeedFactory); ok {
+		return f.ChangeBus()
+	}
+	return nil
+}
+
+// cache returns the CacheBackend of the store key, if any
+func (sts *stores) cache(key interface{}) CacheBackend {
+	if f, ok := sts.base().(CacheFactory); ok {
+		return f.Cache(key)
+	}
+	return nil
+}
+
+// metrics returns the metrics of the factory, if any
+func (sts *stores) metrics() *Metrics {
+	if f, ok := sts.base().(MetricsFactory); ok {
+		return f.Metrics()
+	}
+	return nil
+}
+
+// replicasOf returns the replicas of the store key, if any
+func (sts *stores) replicasOf(key interface{}) *Replicas {
+	if f, ok := sts.base().(ReplicaFactory); ok {
+		return f.Replicas(key)
+	}
+	return nil
 }
 
 // Connect connects gets a connection to the key
 func (sts *stores) Get(key interface{}) (s Store, err error) {
+	return sts.get(context.Background(), key)
+}
+
+// get gets a connection to the key. If the source implements
+// ContextSource, opening connection aborts when the context is done.
+//
+// The Store returned counts its operations in flight as references to
+// the connection, so Close would wait for them before closing. If the
+// store key has replicas (see ReplicaFactory), the Store returned
+// reads from a replica and writes to the source of the store key.
+// Stores routed to the source of tenant (see Tenancy) have no replicas
+func (sts *stores) get(ctx context.Context, key interface{}) (s Store, err error) {
 
 	// find provider
 	srcKey, provider := sts.factory.Get(key)
@@ -79,25 +280,348 @@ func (sts *stores) Get(key interface{}) (s Store, err error) {
 		return
 	}
 
-	// find existing connection
-	var conn Conn
-	var ok bool
-	if conn, ok = sts.conns[srcKey]; !ok {
-		source := sts.factory.GetSource(srcKey)
-		conn, err = source.Open()
+	// route to the source of tenant, if any
+	if tf, ok := sts.factory.(*tenantFactory); ok && tf.tenancy.Source != nil {
+		if srcKey, err = tf.source(ctx, srcKey); err != nil {
+			return
+		}
+		return sts.open(ctx, srcKey, provider, false)
 	}
+
+	if r := sts.replicasOf(key); r != nil && len(r.Sources) > 0 {
+		var ss *splitStore
+		if ss, err = newSplitStore(ctx, sts, srcKey, provider, r); err != nil {
+			return
+		}
+		return ss, nil
+	}
+	return sts.open(ctx, srcKey, provider, false)
+}
+
+// open returns the Store of the provider on the shared connection
+// of the source key. Connection opened for read only would not
+// join transaction (see Replicas)
+func (sts *stores) open(ctx context.Context, srcKey interface{}, provider Provider, readOnly bool) (s Store, err error) {
+	sc, err := sts.conn(ctx, srcKey, readOnly)
+	if err != nil {
+		return
+	}
+	ss, err := newSharedStore(sts, sc, provider)
 	if err != nil {
 		return
 	}
+	return ss, nil
+}
+
+// session returns the raw session of the shared connection
+// and the count of transaction changes of the stores
+func (sts *stores) session(sc *sharedConn) (raw interface{}, n uint64) {
+	sts.mux.Lock()
+	defer sts.mux.Unlock()
+	return sc.conn.Raw(), sts.sessions
+}
 
-	sts.conns[srcKey] = conn
-	s, err = provider(conn.Raw())
+// conn returns the shared connection of the source key,
+// opening it if not yet opened, or waiting for it if
+// another goroutine is opening it
+func (sts *stores) conn(ctx context.Context, srcKey interface{}, readOnly bool) (sc *sharedConn, err error) {
+	sts.mux.Lock()
+	if sts.closed {
+		sts.mux.Unlock()
+		err = fmt.Errorf("Stores already closed")
+		return
+	}
+	if sc = sts.conns[srcKey]; sc != nil {
+		sts.mux.Unlock()
+		select {
+		case <-sc.opened:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if sc.err != nil {
+			return nil, sc.err
+		}
+
+		// connection opened for read only now used for write
+		sts.mux.Lock()
+		defer sts.mux.Unlock()
+		if sc.readOnly && !readOnly {
+			sc.readOnly = false
+			if sts.inTx {
+				sts.sessions++
+				if err = begin(sc.conn); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return
+	}
+	sc = &sharedConn{opened: make(chan bool), readOnly: readOnly}
+	sts.conns[srcKey] = sc
+	sts.mux.Unlock()
+
+	source := sts.factory.GetSource(srcKey)
+	if m := sts.metrics(); m != nil {
+		source = m.Source(srcKey, source)
+	}
+	conn, err := openContext(ctx, source)
+
+	sts.mux.Lock()
+	defer sts.mux.Unlock()
+	defer close(sc.opened)
+
+	// join the current transaction, if any
+	if err == nil && sts.inTx && !readOnly {
+		if err = begin(conn); err != nil {
+			conn.Close()
+		}
+	}
+
+	// forget the failed connection for later Get to retry
+	if err != nil {
+		sc.err = err
+		delete(sts.conns, srcKey)
+		return nil, err
+	}
+	sc.conn = conn
 	return
 }
 
-// Close close all the Conn in the set
+// acquire adds a reference to the shared connection
+// for an operation. Returns error if the stores closed
+func (sts *stores) acquire(sc *sharedConn) error {
+	sts.mux.Lock()
+	defer sts.mux.Unlock()
+	if sts.closed {
+		return fmt.Errorf("Stores already closed")
+	}
+	sc.refs++
+	return nil
+}
+
+// release removes a reference to the shared connection
+func (sts *stores) release(sc *sharedConn) {
+	sts.mux.Lock()
+	defer sts.mux.Unlock()
+	if sc.refs--; sc.refs == 0 {
+		sts.done.Broadcast()
+	}
+}
+
+// Close close all the Conn in the set, after the operations in
+// flight on them are done. Get after Close returns error
 func (sts *stores) Close() {
-	for _, conn := range sts.conns {
-		conn.Close()
+	sts.mux.Lock()
+	sts.closed = true
+	conns := make([]*sharedConn, 0, len(sts.conns))
+	for _, sc := range sts.conns {
+		conns = append(conns, sc)
+	}
+	unload := sts.unload
+	sts.unload = nil
+	sts.mux.Unlock()
+
+	for _, done := range unload {
+		done()
+	}
+
+	for _, sc := range conns {
+		<-sc.opened
+		sts.mux.Lock()
+		for sc.refs > 0 {
+			sts.done.Wait()
+		}
+		sts.mux.Unlock()
+		if sc.conn != nil {
+			sc.conn.Close()
+		}
+	}
+}
+
+// openConns returns the connections opened, except those
+// for read only. Should be called with lock
+func (sts *stores) openConns() []Conn {
+	conns := make([]Conn, 0, len(sts.conns))
+	for _, sc := range sts.conns {
+		select {
+		case <-sc.opened:
+			if sc.conn != nil && !sc.readOnly {
+				conns = append(conns, sc.conn)
+			}
+		default:
+			// would join the transaction once opened
+		}
+	}
+	return conns
+}
+
+// Begin implements Stores
+func (sts *stores) Begin() (err error) {
+	sts.mux.Lock()
+	defer sts.mux.Unlock()
+	if sts.inTx {
+		err = fmt.Errorf("transaction already begun")
+		return
+	}
+
+	sts.sessions++
+	conns := sts.openConns()
+	begun := make([]Conn, 0, len(conns))
+	for _, conn := range conns {
+		if err = begin(conn); err != nil {
+			for _, conn := range begun {
+				conn.(TxConn).Rollback()
+			}
+			return
+		}
+		begun = append(begun, conn)
+	}
+	sts.inTx = true
+	return
+}
+
+// Commit implements Stores. If any of the Conn
+// failed to commit, the rest would be rolled back
+func (sts *stores) Commit() (err error) {
+	sts.mux.Lock()
+	if !sts.inTx {
+		sts.mux.Unlock()
+		err = fmt.Errorf("transaction not begun")
+		return
+	}
+	sts.inTx = false
+	sts.sessions++
+	pending := sts.pending
+	sts.pending = nil
+
+	for _, conn := range sts.openConns() {
+		if err != nil {
+			conn.(TxConn).Rollback()
+		} else if err = conn.(TxConn).Commit(); err != nil {
+			err = fmt.Errorf("error committing transaction: %s", err)
+		}
+	}
+	sts.purge()
+	sts.mux.Unlock()
+
+	if err == nil {
+		sts.publish(pending)
+	}
+	return
+}
+
+// Rollback implements Stores
+func (sts *stores) Rollback() (err error) {
+	sts.mux.Lock()
+	defer sts.mux.Unlock()
+	if !sts.inTx {
+		err = fmt.Errorf("transaction not begun")
+		return
+	}
+	sts.inTx = false
+	sts.sessions++
+	sts.pending = nil
+	defer sts.purge()
+
+	for _, conn := range sts.openConns() {
+		if rerr := conn.(TxConn).Rollback(); rerr != nil && err == nil {
+			err = fmt.Errorf("error rolling back transaction: %s", rerr)
+		}
+	}
+	return
+}
+
+// replica returns the source key of the replica to read from
+// for the primary source key. The replica is picked on the
+// first call, and kept until Close
+func (sts *stores) replica(srcKey interface{}, r *Replicas) interface{} {
+	sts.mux.Lock()
+	defer sts.mux.Unlock()
+	if replica, ok := sts.replicas[srcKey]; ok {
+		return replica
+	}
+	replica, done := r.pick()
+	sts.replicas[srcKey] = replica
+	sts.unload = append(sts.unload, done)
+	return replica
+}
+
+// pin pins reads of the primary source key to the primary
+func (sts *stores) pin(srcKey interface{}) {
+	sts.mux.Lock()
+	defer sts.mux.Unlock()
+	sts.pinned[srcKey] = true
+}
+
+// isPinned returns whether reads of the primary
+// source key are pinned to the primary
+func (sts *stores) isPinned(srcKey interface{}) bool {
+	sts.mux.Lock()
+	defer sts.mux.Unlock()
+	return sts.pinned[srcKey]
+}
+
+// inTransaction returns whether the stores is in transaction
+func (sts *stores) inTransaction() bool {
+	sts.mux.Lock()
+	defer sts.mux.Unlock()
+	return sts.inTx
+}
+
+// publish publishes the events to the ChangeBus of the factory,
+// or keeps them pending until Commit if in transaction
+func (sts *stores) publish(evts []ChangeEvent) {
+	sts.mux.Lock()
+	if sts.inTx {
+		sts.pending = append(sts.pending, evts...)
+		sts.mux.Unlock()
+		return
+	}
+	sts.mux.Unlock()
+
+	bus := sts.bus()
+	if bus == nil {
+		return
+	}
+	for _, evt := range evts {
+		bus.Publish(evt)
+	}
+}
+
+// addPurge adds the cache to be purged when the transaction ends
+func (sts *stores) addPurge(cache CacheBackend) {
+	sts.mux.Lock()
+	defer sts.mux.Unlock()
+	if sts.inTx {
+		sts.purges = append(sts.purges, cache)
+	}
+}
+
+// purge purges the caches written in the transaction, for values
+// cached during the transaction by other stores. Should be called
+// with lock
+func (sts *stores) purge() {
+	for _, cache := range sts.purges {
+		purgeCache(cache)
+	}
+	sts.purges = nil
+}
+
+// openContext opens a connection of the source, with
+// the context if the source implements ContextSource
+func openContext(ctx context.Context, src Source) (Conn, error) {
+	if csrc, ok := src.(ContextSource); ok {
+		return csrc.OpenContext(ctx)
+	}
+	return src.Open()
+}
+
+// begin starts transaction on the conn, if supported
+func begin(conn Conn) (err error) {
+	txConn, ok := conn.(TxConn)
+	if !ok {
+		err = fmt.Errorf("connection does not support transaction")
+		return
 	}
+	return txConn.Begin()
 }
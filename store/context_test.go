@@ -3,6 +3,8 @@ package store_test
 import (
 	"fmt"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -92,3 +94,397 @@ func TestSetGet(t *testing.T) {
 	}
 
 }
+
+// txTestConn implements store.TxConn
+type txTestConn struct {
+	log *[]string
+	tx  bool
+}
+
+// Raw implements store.Conn
+func (c *txTestConn) Raw() interface{} {
+	return c
+}
+
+// Close implements store.Conn
+func (c *txTestConn) Close() {
+	*c.log = append(*c.log, "close")
+}
+
+// Begin implements store.TxConn
+func (c *txTestConn) Begin() error {
+	if c.tx {
+		return fmt.Errorf("already begun")
+	}
+	c.tx = true
+	*c.log = append(*c.log, "begin")
+	return nil
+}
+
+// Commit implements store.TxConn
+func (c *txTestConn) Commit() error {
+	c.tx = false
+	*c.log = append(*c.log, "commit")
+	return nil
+}
+
+// Rollback implements store.TxConn
+func (c *txTestConn) Rollback() error {
+	c.tx = false
+	*c.log = append(*c.log, "rollback")
+	return nil
+}
+
+func testTxFactory(srcKey, key interface{}, log *[]string, tx bool) store.Factory {
+	factory := store.NewFactory()
+	factory.SetSource(srcKey, store.SourceFunc(func() (conn store.Conn, err error) {
+		if tx {
+			conn = &txTestConn{log: log}
+		} else {
+			conn = &testConn{}
+		}
+		return
+	}))
+	factory.Set(key, srcKey, func(sess interface{}) (s store.Store, err error) {
+		if conn, ok := sess.(*txTestConn); ok && !conn.tx {
+			err = fmt.Errorf("store is not bound to transaction")
+		}
+		return
+	})
+	return factory
+}
+
+func testLogEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBeginCommit(t *testing.T) {
+
+	type tempKey int
+
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	var log []string
+	ctx := store.WithFactory(context.Background(),
+		testTxFactory(srcKey, key, &log, true))
+
+	if err := store.Commit(ctx); err == nil {
+		t.Errorf("expected error committing before begin, got nil")
+	}
+	if err := store.Begin(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := store.Begin(ctx); err == nil {
+		t.Errorf("expected error beginning twice, got nil")
+	}
+
+	// connection opened after Begin should join the transaction
+	if _, err := store.Get(ctx, key); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	}
+	if err := store.Commit(ctx); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	}
+
+	// store obtained after Commit is not in transaction anymore
+	if _, err := store.Get(ctx, key); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	store.CloseAllIn(ctx)
+
+	if want, have := []string{"begin", "commit", "close"}, log; !testLogEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestBeginRollback(t *testing.T) {
+
+	type tempKey int
+
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	var log []string
+	ctx := store.WithFactory(context.Background(),
+		testTxFactory(srcKey, key, &log, true))
+
+	// connection opened before Begin should join the transaction
+	store.Get(ctx, key)
+	if err := store.Begin(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if _, err := store.Get(ctx, key); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	}
+	if err := store.Rollback(ctx); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	}
+	if err := store.Rollback(ctx); err == nil {
+		t.Errorf("expected error rolling back twice, got nil")
+	}
+	store.CloseAllIn(ctx)
+
+	if want, have := []string{"begin", "rollback", "close"}, log; !testLogEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestBegin_notSupported(t *testing.T) {
+
+	type tempKey int
+
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	ctx := store.WithFactory(context.Background(),
+		testTxFactory(srcKey, key, nil, false))
+	if err := store.Begin(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if _, err := store.Get(ctx, key); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if err := store.Begin(context.Background()); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestGet_concurrent(t *testing.T) {
+
+	type tempKey int
+
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	var opens int32
+	closed := make(chan int, 10)
+	factory := store.NewFactory()
+	factory.SetSource(srcKey, store.SourceFunc(func() (store.Conn, error) {
+		atomic.AddInt32(&opens, 1)
+		time.Sleep(10 * time.Millisecond)
+		return tConn{nil, closed}, nil
+	}))
+	factory.Set(key, srcKey, func(sess interface{}) (store.Store, error) {
+		return &slowStore{time.Millisecond}, nil
+	})
+	ctx := store.WithFactory(context.Background(), factory)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := store.Get(ctx, key)
+			if err != nil {
+				t.Errorf("unexpected error: %#v", err.Error())
+				return
+			}
+			if err := s.One(nil, &slowEntity{}); err != nil {
+				t.Errorf("unexpected error: %#v", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+	store.CloseAllIn(ctx)
+
+	if want, have := int32(1), atomic.LoadInt32(&opens); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Errorf("connection not closed")
+	}
+}
+
+func TestCloseAllIn_wait(t *testing.T) {
+
+	type tempKey int
+
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	closed := make(chan int, 1)
+	factory := store.NewFactory()
+	factory.SetSource(srcKey, store.SourceFunc(func() (store.Conn, error) {
+		return tConn{nil, closed}, nil
+	}))
+	factory.Set(key, srcKey, func(sess interface{}) (store.Store, error) {
+		return &slowStore{50 * time.Millisecond}, nil
+	})
+	ctx := store.WithFactory(context.Background(), factory)
+
+	s, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	done := make(chan error)
+	go func() {
+		done <- s.Create(nil, &slowEntity{})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// should wait for the operation in flight
+	store.CloseAllIn(ctx)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %#v", err.Error())
+		}
+	default:
+		t.Errorf("CloseAllIn returned before the operation in flight")
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Errorf("connection not closed")
+	}
+
+	// stores closed
+	if _, err := store.Get(ctx, key); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := s.Create(nil, &slowEntity{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestCloseAllIn_waitCancelled(t *testing.T) {
+
+	type tempKey int
+
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	closed := make(chan int, 1)
+	factory := store.NewFactory()
+	factory.SetSource(srcKey, store.SourceFunc(func() (store.Conn, error) {
+		return tConn{nil, closed}, nil
+	}))
+	factory.Set(key, srcKey, func(sess interface{}) (store.Store, error) {
+		return &slowStore{50 * time.Millisecond}, nil
+	})
+	ctx, cancel := context.WithTimeout(store.WithFactory(context.Background(), factory),
+		10*time.Millisecond)
+	defer cancel()
+
+	s, err := store.GetBound(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	begin := time.Now()
+	if err := s.One(nil, &slowEntity{}); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %#v", err)
+	}
+
+	// should wait for the abandoned operation to return
+	store.CloseAllIn(ctx)
+	if d := time.Since(begin); d < 40*time.Millisecond {
+		t.Errorf("CloseAllIn returned before the abandoned operation, in %s", d)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Errorf("connection not closed")
+	}
+}
+
+// sessConn implements store.TxConn with
+// raw session changed on transaction
+type sessConn struct {
+	tx bool
+}
+
+func (c *sessConn) Raw() interface{} {
+	if c.tx {
+		return "tx"
+	}
+	return "base"
+}
+
+func (c *sessConn) Close()          {}
+func (c *sessConn) Begin() error    { c.tx = true; return nil }
+func (c *sessConn) Commit() error   { c.tx = false; return nil }
+func (c *sessConn) Rollback() error { c.tx = false; return nil }
+
+// sessStore creates entities named after its session
+type sessStore struct {
+	slowStore
+	sess string
+}
+
+func (s *sessStore) Create(c store.Conds, ep store.EntityPtr) error {
+	ep.(*slowEntity).Name = s.sess
+	return nil
+}
+
+func TestGet_beforeBegin(t *testing.T) {
+
+	type tempKey int
+
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	factory := store.NewFactory()
+	factory.SetSource(srcKey, store.SourceFunc(func() (store.Conn, error) {
+		return &sessConn{}, nil
+	}))
+	factory.Set(key, srcKey, func(sess interface{}) (store.Store, error) {
+		return &sessStore{sess: sess.(string)}, nil
+	})
+	ctx := store.WithFactory(context.Background(), factory)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	create := func() string {
+		e := &slowEntity{}
+		if err := s.Create(nil, e); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		return e.Name
+	}
+	if want, have := "base", create(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// store obtained before Begin joins the transaction
+	if err := store.Begin(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "tx", create(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if err := store.Commit(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "base", create(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
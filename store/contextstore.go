@@ -0,0 +1,233 @@
+package store
+
+import (
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+// NewContextStore wraps a Store into ContextStore. If the Store
+// already implements ContextStore, it is returned as is.
+//
+// The underlying Store cannot be interrupted. So reads (One and the
+// Result methods) run in a goroutine and return the context error as
+// soon as the context is done, with the read left to run to its end
+// and its result discarded. The read is decoded into a copy of the
+// entity or a new list, which is copied to the caller only if the read
+// succeeds in time, so the abandoned read never touches the values of
+// the caller. Writes (Create, Update and Delete) are not
+// started if the context is done. Once started, they run to the end
+// and return their own result, so cancelling never hides an applied
+// write from the caller
+func NewContextStore(s Store) ContextStore {
+	if cs, ok := s.(ContextStore); ok {
+		return cs
+	}
+	return &contextStore{s}
+}
+
+// contextStore implements ContextStore with a Store
+type contextStore struct {
+	Store
+}
+
+// CreateContext implements ContextStore
+func (s *contextStore) CreateContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	return runWrite(ctx, func() error {
+		return s.Create(c, ep)
+	})
+}
+
+// SearchContext implements ContextStore
+func (s *contextStore) SearchContext(ctx context.Context, q Query) Result {
+	return &contextResult{ctx: ctx, Result: s.Search(q)}
+}
+
+// OneContext implements ContextStore
+func (s *contextStore) OneContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	tmp := deepCopy(ep)
+	return runContext(ctx, func() error {
+		return s.One(c, tmp)
+	}, func() {
+		setPtr(ep, tmp)
+	})
+}
+
+// UpdateContext implements ContextStore
+func (s *contextStore) UpdateContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	return runWrite(ctx, func() error {
+		return s.Update(c, ep)
+	})
+}
+
+// DeleteContext implements ContextStore
+func (s *contextStore) DeleteContext(ctx context.Context, c Conds) error {
+	return runWrite(ctx, func() error {
+		return s.Delete(c)
+	})
+}
+
+// contextResult wraps Result with context
+type contextResult struct {
+	ctx context.Context
+	err error
+	Result
+}
+
+// All implements Result
+func (res *contextResult) All(el interface{}) error {
+	tmp := newPtr(el)
+	return runContext(res.ctx, func() error {
+		return res.Result.All(tmp)
+	}, func() {
+		setPtr(el, tmp)
+	})
+}
+
+// Count implements Result
+func (res *contextResult) Count() (count uint64, err error) {
+	var c uint64
+	err = runContext(res.ctx, func() (err error) {
+		c, err = res.Result.Count()
+		return
+	}, func() {
+		count = c
+	})
+	return
+}
+
+// Aggregate implements Result
+func (res *contextResult) Aggregate() (rows []AggregateRow, err error) {
+	var r []AggregateRow
+	err = runContext(res.ctx, func() (err error) {
+		r, err = res.Result.Aggregate()
+		return
+	}, func() {
+		rows = r
+	})
+	return
+}
+
+// Next implements Result. Iteration stops once
+// the context is done
+func (res *contextResult) Next(ep EntityPtr) bool {
+	if res.err != nil {
+		return false
+	}
+	if res.err = res.ctx.Err(); res.err != nil {
+		return false
+	}
+	return res.Result.Next(ep)
+}
+
+// Err implements Result
+func (res *contextResult) Err() error {
+	if res.err != nil {
+		return res.err
+	}
+	return res.Result.Err()
+}
+
+// Bind binds a ContextStore to the context and returns
+// as Store. All operations of the Store would be run
+// with the context
+func Bind(ctx context.Context, s ContextStore) Store {
+	return &boundStore{ctx, s}
+}
+
+// boundStore implements Store with a ContextStore
+// and a context
+type boundStore struct {
+	ctx context.Context
+	ContextStore
+}
+
+// Create implements Store
+func (s *boundStore) Create(c Conds, ep EntityPtr) error {
+	return s.CreateContext(s.ctx, c, ep)
+}
+
+// Search implements Store
+func (s *boundStore) Search(q Query) Result {
+	return s.SearchContext(s.ctx, q)
+}
+
+// One implements Store
+func (s *boundStore) One(c Conds, ep EntityPtr) error {
+	return s.OneContext(s.ctx, c, ep)
+}
+
+// Update implements Store
+func (s *boundStore) Update(c Conds, ep EntityPtr) error {
+	return s.UpdateContext(s.ctx, c, ep)
+}
+
+// Delete implements Store
+func (s *boundStore) Delete(c Conds) error {
+	return s.DeleteContext(s.ctx, c)
+}
+
+// runContext runs fn in a goroutine. If fn returns before the context
+// is done, done would be called (if not nil) on success and the error
+// of fn is returned. Otherwise the context error is returned, while fn
+// keeps running to its end. Only for operations without side effects
+func runContext(ctx context.Context, fn func() error, done func()) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- fn()
+	}()
+
+	select {
+	case err = <-result:
+		if err == nil && done != nil {
+			done()
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+// runWrite runs fn unless the context is done. The write cannot be
+// aborted once started, so it is waited for and its error is returned
+func runWrite(ctx context.Context, fn func() error) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	return fn()
+}
+
+// copyPtr returns a pointer to a copy of the value
+// the given pointer points to. Non-pointer is returned as is
+func copyPtr(ptr interface{}) interface{} {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return ptr
+	}
+	cp := reflect.New(v.Elem().Type())
+	cp.Elem().Set(v.Elem())
+	return cp.Interface()
+}
+
+// newPtr returns a pointer to a new zero value of the
+// type the given pointer points to. Non-pointer is returned as is
+func newPtr(ptr interface{}) interface{} {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return ptr
+	}
+	return reflect.New(v.Elem().Type()).Interface()
+}
+
+// setPtr sets the value src points to into dst
+func setPtr(dst, src interface{}) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || dst == src {
+		return
+	}
+	v.Elem().Set(reflect.ValueOf(src).Elem())
+}
@@ -0,0 +1,299 @@
+package store_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
+)
+
+// slowStore implements store.Store with operations
+// that only finish after the given delay
+type slowStore struct {
+	delay time.Duration
+}
+
+type slowEntity struct {
+	Name string
+}
+
+func (s *slowStore) wait() {
+	time.Sleep(s.delay)
+}
+
+func (s *slowStore) Create(c store.Conds, ep store.EntityPtr) error {
+	s.wait()
+	ep.(*slowEntity).Name = "created"
+	return nil
+}
+
+func (s *slowStore) Search(q store.Query) store.Result {
+	return &slowResult{s: s}
+}
+
+func (s *slowStore) One(c store.Conds, ep store.EntityPtr) error {
+	s.wait()
+	ep.(*slowEntity).Name = "found"
+	return nil
+}
+
+func (s *slowStore) Update(c store.Conds, ep store.EntityPtr) error {
+	s.wait()
+	ep.(*slowEntity).Name = "updated"
+	return nil
+}
+
+func (s *slowStore) Delete(c store.Conds) error {
+	s.wait()
+	return fmt.Errorf("delete error")
+}
+
+func (s *slowStore) AllocEntity() store.EntityPtr         { return &slowEntity{} }
+func (s *slowStore) AllocEntityList() store.EntityListPtr { return &[]slowEntity{} }
+func (s *slowStore) Len(el store.EntityListPtr) int64     { return int64(len(*el.(*[]slowEntity))) }
+func (s *slowStore) Close() error                         { return nil }
+
+// slowResult implements store.Result
+type slowResult struct {
+	s   *slowStore
+	pos int
+}
+
+func (res *slowResult) All(el interface{}) error {
+	res.s.wait()
+	*el.(*[]slowEntity) = []slowEntity{{"a"}, {"b"}}
+	return nil
+}
+
+func (res *slowResult) Raw() (interface{}, error) { return nil, nil }
+
+func (res *slowResult) Count() (uint64, error) {
+	res.s.wait()
+	return 2, nil
+}
+
+func (res *slowResult) Aggregate() ([]store.AggregateRow, error) {
+	res.s.wait()
+	return []store.AggregateRow{{"count": int64(2)}}, nil
+}
+
+func (res *slowResult) Next(ep store.EntityPtr) bool {
+	if res.pos >= 2 {
+		return false
+	}
+	res.s.wait()
+	ep.(*slowEntity).Name = []string{"a", "b"}[res.pos]
+	res.pos++
+	return true
+}
+
+func (res *slowResult) Err() error   { return nil }
+func (res *slowResult) Close() error { return nil }
+
+func TestNewContextStore(t *testing.T) {
+	bound := store.Bind(context.Background(),
+		store.NewContextStore(&slowStore{}))
+	cs, ok := bound.(store.ContextStore)
+	if !ok {
+		t.Fatalf("expected bound store to implement ContextStore")
+	}
+	if want, have := cs, store.NewContextStore(bound); want != have {
+		t.Errorf("ContextStore should be returned as is")
+	}
+}
+
+func TestContextStore_done(t *testing.T) {
+	s := store.NewContextStore(&slowStore{time.Millisecond})
+	ctx := context.Background()
+
+	e := &slowEntity{}
+	if err := s.CreateContext(ctx, nil, e); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	} else if want, have := "created", e.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if err := s.OneContext(ctx, nil, e); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	} else if want, have := "found", e.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	var list []slowEntity
+	res := s.SearchContext(ctx, store.NewQuery())
+	if err := res.All(&list); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	} else if want, have := 2, len(list); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if count, err := res.Count(); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	} else if want, have := uint64(2), count; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	var names []string
+	for e := (&slowEntity{}); res.Next(e); {
+		names = append(names, e.Name)
+	}
+	if err := res.Err(); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	} else if want, have := "[a b]", fmt.Sprintf("%v", names); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if err := s.UpdateContext(ctx, nil, e); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	} else if want, have := "updated", e.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if err := s.DeleteContext(ctx, nil); err == nil {
+		t.Errorf("expected error, got nil")
+	} else if want, have := "delete error", err.Error(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestContextStore_cancel(t *testing.T) {
+	s := store.NewContextStore(&slowStore{time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	e := &slowEntity{}
+	if err := s.OneContext(ctx, nil, e); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %#v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("operation did not abort on deadline, took %s", d)
+	}
+	if want, have := "", e.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// context already done
+	var list []slowEntity
+	if err := s.SearchContext(ctx, store.NewQuery()).All(&list); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %#v", err)
+	}
+	if _, err := s.SearchContext(ctx, store.NewQuery()).Count(); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %#v", err)
+	}
+	res := s.SearchContext(ctx, store.NewQuery())
+	if res.Next(e) {
+		t.Errorf("expected Next to stop on context done")
+	}
+	if err := res.Err(); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %#v", err)
+	}
+	if err := s.UpdateContext(ctx, nil, e); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %#v", err)
+	}
+	if want, have := "", e.Name; want != have {
+		t.Errorf("write should not start on context done, got %#v", have)
+	}
+}
+
+func TestContextStore_cancelWrite(t *testing.T) {
+	s := store.NewContextStore(&slowStore{50 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// started write runs to its end with its own result
+	e := &slowEntity{}
+	if err := s.CreateContext(ctx, nil, e); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "created", e.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestGetBound(t *testing.T) {
+
+	type tempKey int
+
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	factory := store.NewFactory()
+	factory.SetSource(srcKey, store.SourceFunc(func() (store.Conn, error) {
+		return &testConn{}, nil
+	}))
+	factory.Set(key, srcKey, func(sess interface{}) (store.Store, error) {
+		return &slowStore{time.Second}, nil
+	})
+
+	ctx, cancel := context.WithCancel(store.WithFactory(context.Background(), factory))
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.GetBound(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := s.One(nil, &slowEntity{}); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %#v", err)
+	}
+}
+
+// reuseStore decodes All into the backing
+// array of the given list, if large enough
+type reuseStore struct {
+	slowStore
+}
+
+func (s *reuseStore) Search(q store.Query) store.Result {
+	return &reuseResult{slowResult{s: &s.slowStore}}
+}
+
+type reuseResult struct {
+	slowResult
+}
+
+func (res *reuseResult) All(el interface{}) error {
+	res.s.wait()
+	list := *el.(*[]slowEntity)
+	if cap(list) < 2 {
+		list = make([]slowEntity, 2)
+	}
+	list = list[:2]
+	list[0].Name, list[1].Name = "a", "b"
+	*el.(*[]slowEntity) = list
+	return nil
+}
+
+func TestContextStore_cancelAll(t *testing.T) {
+	s := store.NewContextStore(&reuseStore{slowStore{20 * time.Millisecond}})
+
+	// abandoned read leaves the list untouched
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	list := []slowEntity{{"x"}, {"y"}}
+	if err := s.SearchContext(ctx, store.NewQuery()).All(&list); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %#v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if want, have := "[{x} {y}]", fmt.Sprintf("%v", list); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// read in time is copied to the list
+	if err := s.SearchContext(context.Background(), store.NewQuery()).All(&list); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "[{a} {b}]", fmt.Sprintf("%v", list); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
@@ -0,0 +1,385 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Cursor marks the position of an entity in a sorted result set
+// for keyset (cursor based) pagination. It holds the values of
+// the sorting properties of the entity.
+//
+// For stable pages, the Sorts should end with a unique
+// property (e.g. id)
+type Cursor struct {
+
+	// Sorts is the string representation of the Sorts
+	// the cursor derived from
+	Sorts []string
+
+	// Values of the sorting properties, in the order of Sorts
+	Values []interface{}
+
+	// Before is true if the page is before the position.
+	// Otherwise the page is after the position
+	Before bool
+}
+
+// NewCursor creates a Cursor after the given entity, which is a struct
+// or pointer to struct, by the Sorts. Properties are found by name in
+// the field's db tag, the field name, then the field name
+// case-insensitively
+func NewCursor(ss Sorts, entity interface{}) (c *Cursor, err error) {
+	if ss == nil || len(ss.GetAll()) == 0 {
+		err = fmt.Errorf("cursor requires at least 1 sort")
+		return
+	}
+
+	val := reflect.ValueOf(entity)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		err = fmt.Errorf("expected struct or pointer to struct, got %#v", entity)
+		return
+	}
+
+	c = &Cursor{}
+	for _, s := range ss.GetAll() {
+		field, ok := propField(val, s.Name)
+		if !ok {
+			c, err = nil, fmt.Errorf("property %#v not found in %s", s.Name, val.Type())
+			return
+		}
+		for field.Kind() == reflect.Ptr && !field.IsNil() {
+			field = field.Elem()
+		}
+		var v interface{}
+		if field.Kind() != reflect.Ptr {
+			v = field.Interface()
+		}
+		c.Sorts = append(c.Sorts, s.String())
+		c.Values = append(c.Values, v)
+	}
+	return
+}
+
+// ParseCursor parses a cursor token
+func ParseCursor(token string) (c *Cursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		err = Error(http.StatusBadRequest, "invalid cursor").
+			TellServer("error decoding cursor %#v: %s", token, err)
+		return
+	}
+
+	c = &Cursor{}
+	if err = json.Unmarshal(data, c); err != nil {
+		c, err = nil, Error(http.StatusBadRequest, "invalid cursor").
+			TellServer("error parsing cursor %#v: %s", token, err)
+	}
+	return
+}
+
+// String returns the opaque token of the cursor
+func (c *Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// cursorJSON is the JSON representation of a Cursor
+type cursorJSON struct {
+	Sorts  []string      `json:"s"`
+	Values []cursorValue `json:"v"`
+	Before bool          `json:"b,omitempty"`
+}
+
+// cursorValue is the JSON representation of a cursor value
+// with its type preserved
+type cursorValue struct {
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v,omitempty"`
+}
+
+// MarshalJSON implements json Marshaler interface
+func (c Cursor) MarshalJSON() ([]byte, error) {
+	out := cursorJSON{
+		Sorts:  c.Sorts,
+		Values: make([]cursorValue, 0, len(c.Values)),
+		Before: c.Before,
+	}
+	for _, v := range c.Values {
+		var cv cursorValue
+		if v == nil {
+			cv.Type = "null"
+			out.Values = append(out.Values, cv)
+			continue
+		}
+
+		if _, ok := v.(time.Time); ok {
+			cv.Type = "time"
+		} else {
+			switch reflect.ValueOf(v).Kind() {
+			case reflect.String:
+				cv.Type = "string"
+			case reflect.Bool:
+				cv.Type = "bool"
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+				reflect.Float32, reflect.Float64:
+				cv.Type = "number"
+			default:
+				return nil, fmt.Errorf("unsupported cursor value %#v", v)
+			}
+		}
+
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		cv.Value = data
+		out.Values = append(out.Values, cv)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json Unmarshaler interface
+func (c *Cursor) UnmarshalJSON(data []byte) (err error) {
+	in := cursorJSON{}
+	if err = json.Unmarshal(data, &in); err != nil {
+		return
+	}
+	if len(in.Sorts) != len(in.Values) {
+		err = fmt.Errorf("cursor has %d sorts but %d values",
+			len(in.Sorts), len(in.Values))
+		return
+	}
+
+	values := make([]interface{}, 0, len(in.Values))
+	for _, cv := range in.Values {
+		var v interface{}
+		switch cv.Type {
+		case "null":
+			// nil value
+		case "time":
+			var t time.Time
+			err = json.Unmarshal(cv.Value, &t)
+			v = t
+		case "string":
+			var str string
+			err = json.Unmarshal(cv.Value, &str)
+			v = str
+		case "bool":
+			var b bool
+			err = json.Unmarshal(cv.Value, &b)
+			v = b
+		case "number":
+			var n json.Number
+			if err = json.Unmarshal(cv.Value, &n); err != nil {
+				break
+			}
+			if i, ierr := n.Int64(); ierr == nil {
+				v = i
+			} else {
+				v, err = n.Float64()
+			}
+		default:
+			err = fmt.Errorf("unknown cursor value type %#v", cv.Type)
+		}
+		if err != nil {
+			return
+		}
+		values = append(values, v)
+	}
+
+	c.Sorts, c.Values, c.Before = in.Sorts, values, in.Before
+	return
+}
+
+// Conds returns the keyset conditions of the cursor position
+// for the given Sorts. For sorts (a, b) and cursor values (x, y),
+// the conditions of page after the cursor would be:
+//
+//	a > x OR (a = x AND b > y)
+//
+// Operators are inverted for descending sorts and for cursor
+// before the position. Returns 400 StoreError if the sorts
+// of cursor mismatch the given Sorts.
+//
+// NULL is taken as the least value, as SQLite and MySQL sort it
+// (first in ascending order, last in descending order). So for
+// NULL cursor value x, "a > x" becomes "a IS NOT NULL" and
+// "a < x" matches nothing. For other x, "a < x" also matches
+// NULL (i.e. "a < x OR a IS NULL")
+func (c *Cursor) Conds(ss Sorts) (cs Conds, err error) {
+	var sorts []*Sort
+	if ss != nil {
+		sorts = ss.GetAll()
+	}
+	if len(sorts) != len(c.Sorts) || len(sorts) != len(c.Values) {
+		err = Error(http.StatusBadRequest, "cursor does not match the sorting").
+			TellServer("cursor sorts %#v, query sorts %#v", c.Sorts, sorts)
+		return
+	}
+	for i, s := range sorts {
+		if s.String() != c.Sorts[i] {
+			err = Error(http.StatusBadRequest, "cursor does not match the sorting").
+				TellServer("cursor sorts %#v, query sorts %#v", c.Sorts, sorts)
+			return
+		}
+	}
+
+	cs = NewConds().SetRel(Or)
+	branches := 0
+	for i, s := range sorts {
+		branch := NewConds()
+		for j := 0; j < i; j++ {
+			if c.Values[j] == nil {
+				branch.AddOp(sorts[j].Name, IsNull, nil)
+			} else {
+				branch.Add(sorts[j].Name, c.Values[j])
+			}
+		}
+
+		greater := (s.Order == Desc) == c.Before
+		switch v := c.Values[i]; {
+		case v == nil && greater:
+			branch.AddOp(s.Name, NotNull, nil)
+		case v == nil:
+			continue // nothing is less than NULL
+		case greater:
+			branch.AddOp(s.Name, Gt, v)
+		default:
+			branch.Add("", NewConds().SetRel(Or).
+				AddOp(s.Name, Lt, v).
+				AddOp(s.Name, IsNull, nil))
+		}
+		cs.Add("", branch)
+		branches++
+	}
+
+	// no entity beyond the position
+	if branches == 0 {
+		cs = NewConds().
+			AddOp(sorts[0].Name, IsNull, nil).
+			AddOp(sorts[0].Name, NotNull, nil)
+	}
+	return
+}
+
+// QueryConds returns the conditions of the query together
+// with the keyset conditions of the query cursor, if any
+func QueryConds(q Query) (cs Conds, err error) {
+	c := q.GetCursor()
+	if c == nil {
+		cs = q.GetConds()
+		return
+	}
+
+	keyset, err := c.Conds(q.GetSorts())
+	if err != nil {
+		return
+	}
+
+	cs = NewConds()
+	if conds := q.GetConds(); conds != nil && len(conds.GetAll()) > 0 {
+		cs.Add("", conds)
+	}
+	cs.Add("", keyset)
+	return
+}
+
+// QuerySorts returns the sorting of the query. If the query cursor
+// is before a position, the orders are reversed so the entities
+// nearest to the position could be retrieved first.
+//
+// Result set of reversed sorting should be reversed to
+// present in the query sorting.
+func QuerySorts(q Query) (sorts []*Sort) {
+	if q.GetSorts() == nil {
+		return
+	}
+	sorts = q.GetSorts().GetAll()
+	if c := q.GetCursor(); c == nil || !c.Before {
+		return
+	}
+
+	reversed := make([]*Sort, 0, len(sorts))
+	for _, s := range sorts {
+		r := &Sort{Name: s.Name, Order: Desc}
+		if s.Order == Desc {
+			r.Order = Asc
+		}
+		reversed = append(reversed, r)
+	}
+	return reversed
+}
+
+// Cursors returns the tokens of cursor before the first entity (prev)
+// and after the last entity (next) of the given page of the query.
+// Empty string is returned if there would be no entity before or after.
+// The el is a pointer to slice, as returned by Store.AllocEntityList
+func Cursors(q Query, el EntityListPtr) (prev, next string) {
+	list := reflect.ValueOf(el)
+	for list.Kind() == reflect.Ptr && !list.IsNil() {
+		list = list.Elem()
+	}
+	if list.Kind() != reflect.Slice || list.Len() == 0 {
+		return
+	}
+
+	c := q.GetCursor()
+	before := c != nil && c.Before
+	full := q.GetLimit() > 0 && uint64(list.Len()) >= q.GetLimit()
+
+	// entities before the first one exist if:
+	// paging backward and the page is full, or
+	// paging forward from a cursor or offset
+	if (before && full) || (!before && (c != nil || q.GetOffset() > 0)) {
+		if first, err := NewCursor(q.GetSorts(), list.Index(0).Interface()); err == nil {
+			first.Before = true
+			prev = first.String()
+		}
+	}
+
+	// entities after the last one exist if:
+	// paging backward from a cursor, or
+	// paging forward and the page is full
+	if before || full {
+		if last, err := NewCursor(q.GetSorts(), list.Index(list.Len()-1).Interface()); err == nil {
+			next = last.String()
+		}
+	}
+	return
+}
+
+// propField finds the struct field of the given property name.
+// It matches the name in db tag, the field name, then the field
+// name case-insensitively
+func propField(val reflect.Value, name string) (field reflect.Value, ok bool) {
+	typ := val.Type()
+	fold := -1
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue // skip unexported fields
+		}
+		if tag := strings.Split(f.Tag.Get("db"), ",")[0]; tag == name {
+			return val.Field(i), true
+		}
+		if f.Name == name {
+			field, ok = val.Field(i), true
+		} else if fold < 0 && strings.EqualFold(f.Name, name) {
+			fold = i
+		}
+	}
+	if !ok && fold >= 0 {
+		field, ok = val.Field(fold), true
+	}
+	return
+}
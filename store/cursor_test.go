@@ -0,0 +1,286 @@
+package store_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gourd/kit/store"
+)
+
+type cursorEntity struct {
+	ID      string    `db:"id"`
+	Name    string    `db:"name"`
+	Age     int       `db:"age"`
+	Score   float64   `db:"score"`
+	Created time.Time `db:"created"`
+	Parent  *string   `db:"parent"`
+}
+
+func TestNewCursor(t *testing.T) {
+	now := time.Now().UTC()
+	e := &cursorEntity{ID: "1", Name: "hello", Age: 20, Created: now}
+
+	c, err := store.NewCursor((&store.BasicSorts{}).Add("-created").Add("id"), e)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(c.Values); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := now, c.Values[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "1", c.Values[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if _, err := store.NewCursor((&store.BasicSorts{}), e); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if _, err := store.NewCursor((&store.BasicSorts{}).Add("nothing"), e); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	now := time.Now().UTC()
+	e := &cursorEntity{ID: "1", Name: "hello", Age: 20, Score: 1.5, Created: now}
+
+	c1, err := store.NewCursor((&store.BasicSorts{}).
+		Add("created").Add("age").Add("score").Add("parent").Add("-id"), e)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	c1.Before = true
+
+	c2, err := store.ParseCursor(c1.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := true, c2.Before; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := len(c1.Sorts), len(c2.Sorts); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	for i := range c1.Sorts {
+		if want, have := c1.Sorts[i], c2.Sorts[i]; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+	if have, ok := c2.Values[0].(time.Time); !ok || !have.Equal(now) {
+		t.Errorf("expected %#v, got %#v", now, c2.Values[0])
+	}
+	if want, have := int64(20), c2.Values[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 1.5, c2.Values[2]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if have := c2.Values[3]; have != nil {
+		t.Errorf("expected nil, got %#v", have)
+	}
+	if want, have := "1", c2.Values[4]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// invalid tokens
+	for _, token := range []string{"!!!", "bm90IGpzb24"} {
+		_, err := store.ParseCursor(token)
+		if err == nil {
+			t.Errorf("expected error for %#v, got nil", token)
+			continue
+		}
+		if want, have := http.StatusBadRequest, store.ExpandError(err).Status; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+}
+
+func TestCursor_Conds(t *testing.T) {
+	ss := (&store.BasicSorts{}).Add("-age").Add("id")
+	c := &store.Cursor{
+		Sorts:  []string{"-age", "id"},
+		Values: []interface{}{20, "abc"},
+	}
+
+	// (age < 20 OR age IS NULL) OR (age = 20 AND id > "abc")
+	cs, err := c.Conds(ss)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := store.Or, cs.GetRel(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	branches := cs.GetAll()
+	if want, have := 2, len(branches); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	first := branches[0].Value.(store.Conds).GetAll()[0].Value.(store.Conds)
+	if want, have := store.Or, first.GetRel(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Cond{Prop: "age", Value: 20, Op: store.Lt}), first.GetAll()[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Cond{Prop: "age", Op: store.IsNull}), first.GetAll()[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	second := branches[1].Value.(store.Conds).GetAll()
+	if want, have := 2, len(second); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Cond{Prop: "age", Value: 20}), second[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Cond{Prop: "id", Value: "abc", Op: store.Gt}), second[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// before the position, operators inverted
+	c.Before = true
+	if cs, err = c.Conds(ss); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := store.Gt, cs.GetAll()[0].Value.(store.Conds).GetAll()[0].Op; want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+
+	// mismatch sorts
+	_, err = c.Conds((&store.BasicSorts{}).Add("age").Add("id"))
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if want, have := http.StatusBadRequest, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestCursor_Conds_null(t *testing.T) {
+	ss := (&store.BasicSorts{}).Add("parent").Add("id")
+	c := &store.Cursor{
+		Sorts:  []string{"parent", "id"},
+		Values: []interface{}{nil, "abc"},
+	}
+
+	// parent IS NOT NULL OR (parent IS NULL AND id > "abc")
+	cs, err := c.Conds(ss)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	branches := cs.GetAll()
+	if want, have := 2, len(branches); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	first := branches[0].Value.(store.Conds).GetAll()
+	if want, have := (store.Cond{Prop: "parent", Op: store.NotNull}), first[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	second := branches[1].Value.(store.Conds).GetAll()
+	if want, have := (store.Cond{Prop: "parent", Op: store.IsNull}), second[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// nothing is before NULL: parent IS NULL AND id < "abc"
+	c.Before = true
+	if cs, err = c.Conds(ss); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 1, len(cs.GetAll()); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// nothing before the single NULL position at all
+	c = &store.Cursor{Sorts: []string{"parent"}, Values: []interface{}{nil}, Before: true}
+	if cs, err = c.Conds((&store.BasicSorts{}).Add("parent")); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := store.And, cs.GetRel(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 2, len(cs.GetAll()); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestQueryConds(t *testing.T) {
+	q := store.NewQuery().AddCond("name", "hello").Sort("id")
+	cs, err := store.QueryConds(q)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := q.GetConds(), cs; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	q.SetCursor(&store.Cursor{
+		Sorts:  []string{"id"},
+		Values: []interface{}{"abc"},
+	})
+	if cs, err = store.QueryConds(q); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := store.And, cs.GetRel(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 2, len(cs.GetAll()); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestQuerySorts(t *testing.T) {
+	q := store.NewQuery().Sort("-age").Sort("id")
+	if want, have := "-age", store.QuerySorts(q)[0].String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	q.SetCursor(&store.Cursor{Before: true})
+	sorts := store.QuerySorts(q)
+	if want, have := "age", sorts[0].String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "-id", sorts[1].String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestCursors(t *testing.T) {
+	list := []cursorEntity{{ID: "1"}, {ID: "2"}}
+	q := store.NewQuery().Sort("id").SetLimit(2)
+
+	// first page
+	prev, next := store.Cursors(q, &list)
+	if want, have := "", prev; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	c, err := store.ParseCursor(next)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "2", c.Values[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// page after cursor, not full
+	q.SetCursor(c).SetLimit(3)
+	prev, next = store.Cursors(q, &list)
+	if want, have := "", next; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if c, err = store.ParseCursor(prev); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := true, c.Before; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "1", c.Values[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// empty page
+	prev, next = store.Cursors(q, &[]cursorEntity{})
+	if prev != "" || next != "" {
+		t.Errorf("expected empty cursors, got %#v, %#v", prev, next)
+	}
+}
@@ -15,14 +15,21 @@ func Error(code int, msg string, v ...interface{}) *StoreError {
 
 	renderedMsg := fmt.Sprintf(msg, v...)
 	return &StoreError{
-		status,
-		code,
-		renderedMsg,
-		renderedMsg,
-		"",
+		Status:    status,
+		Code:      code,
+		ServerMsg: renderedMsg,
+		ClientMsg: renderedMsg,
 	}
 }
 
+// template marks the error as a template. Methods which modify
+// a template (e.g. TellServer) modify and return a copy of it
+// instead, so the template could be shared (see ErrorNotFound)
+func template(err *StoreError) *StoreError {
+	err.template = true
+	return err
+}
+
 // StoreError is for common service error message
 type StoreError struct {
 
@@ -42,26 +49,134 @@ type StoreError struct {
 	// DeveloperMsg is the client side message which
 	// should be of help to developer. Omit if empty
 	DeveloperMsg string `json:"developer_message,omitempty"`
+
+	// Fields are the errors of fields in the request
+	// payload, if any. Omit if empty
+	Fields []FieldError `json:"fields,omitempty"`
+
+	// Type is the URI identifying the type of error
+	// (e.g. TypeNotFound). Omit if empty
+	Type string `json:"type,omitempty"`
+
+	// Meta is the additional information of the
+	// error to the client. Omit if empty
+	Meta map[string]interface{} `json:"meta,omitempty"`
+
+	// Cause is the underlying error, if any
+	Cause error `json:"-"`
+
+	template bool
+}
+
+// FieldError is the error of a field in request payload
+type FieldError struct {
+
+	// Field is the name of the field in payload
+	Field string `json:"field"`
+
+	// Reason is the machine-readable reason of the error,
+	// e.g. the name of the validation rule failed
+	Reason string `json:"reason"`
+
+	// Message describes the error to the client
+	Message string `json:"message"`
+}
+
+// New returns a copy of the error, which could be modified
+// without affecting the error (e.g. of a singleton)
+func (err *StoreError) New() *StoreError {
+	cp := *err
+	cp.template = false
+	if err.Fields != nil {
+		cp.Fields = append([]FieldError(nil), err.Fields...)
+	}
+	if err.Meta != nil {
+		cp.Meta = make(map[string]interface{}, len(err.Meta))
+		for k, v := range err.Meta {
+			cp.Meta[k] = v
+		}
+	}
+	return &cp
+}
+
+// mutable returns the error to modify, which is
+// a copy of it if the error is a template
+func (err *StoreError) mutable() *StoreError {
+	if err.template {
+		return err.New()
+	}
+	return err
 }
 
 // TellServer sets server message
 func (err *StoreError) TellServer(msg string, v ...interface{}) *StoreError {
+	err = err.mutable()
 	err.ServerMsg = fmt.Sprintf(msg, v...)
 	return err
 }
 
 // TellClient sets server message
 func (err *StoreError) TellClient(msg string, v ...interface{}) *StoreError {
+	err = err.mutable()
 	err.ClientMsg = fmt.Sprintf(msg, v...)
 	return err
 }
 
 // TellDeveloper sets server message
 func (err *StoreError) TellDeveloper(msg string, v ...interface{}) *StoreError {
+	err = err.mutable()
 	err.DeveloperMsg = fmt.Sprintf(msg, v...)
 	return err
 }
 
+// WithFields appends the errors of fields
+func (err *StoreError) WithFields(fields ...FieldError) *StoreError {
+	err = err.mutable()
+	err.Fields = append(err.Fields, fields...)
+	return err
+}
+
+// WithType sets the type URI
+func (err *StoreError) WithType(uri string) *StoreError {
+	err = err.mutable()
+	err.Type = uri
+	return err
+}
+
+// WithMeta sets the metadata of the key
+func (err *StoreError) WithMeta(key string, value interface{}) *StoreError {
+	err = err.mutable()
+	if err.Meta == nil {
+		err.Meta = make(map[string]interface{})
+	}
+	err.Meta[key] = value
+	return err
+}
+
+// Wrap sets the underlying cause of the error. The server
+// message is set to the message of cause, if empty
+func (err *StoreError) Wrap(cause error) *StoreError {
+	err = err.mutable()
+	err.Cause = cause
+	if err.ServerMsg == "" && cause != nil {
+		err.ServerMsg = cause.Error()
+	}
+	return err
+}
+
+// Unwrap returns the underlying cause of the error, if any
+func (err *StoreError) Unwrap() error {
+	return err.Cause
+}
+
+// Is tells if the target is a StoreError of the same code
+// and type. So errors made from a singleton (e.g. by
+// ErrorNotFound.TellServer) are the singleton in errors.Is
+func (err *StoreError) Is(target error) bool {
+	t, ok := target.(*StoreError)
+	return ok && t != nil && t.Code == err.Code && t.Type == err.Type
+}
+
 // Error implements the standard error type
 // returns the client message
 func (err StoreError) Error() string {
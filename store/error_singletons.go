@@ -4,32 +4,62 @@ import (
 	"net/http"
 )
 
+// Type URIs of the errors (see StoreError.Type). Clients
+// could tell the kind of errors by them instead of message
+const (
+	TypeNotFound         = "urn:gourd:error:not-found"
+	TypeForbidden        = "urn:gourd:error:forbidden"
+	TypeInternal         = "urn:gourd:error:internal"
+	TypeMethodNotAllowed = "urn:gourd:error:method-not-allowed"
+	TypeInvalid          = "urn:gourd:error:invalid"
+)
+
+// The singletons below are templates. Methods which modify them
+// (e.g. ErrorInternal.TellServer) return modified copies, so the
+// singletons are never changed. Do not assign to their fields
+// directly. Use New for a copy to modify (e.g. ErrorNotFound.New())
+
 // StatusFound singleton status in case entity found
-var StatusFound = Error(http.StatusFound, "Success")
+var StatusFound = template(Error(http.StatusFound, "Success"))
 
 // ErrorNotFound singleton status in case not found
-var ErrorNotFound = Error(http.StatusNotFound, "Not Found")
+var ErrorNotFound = template(Error(http.StatusNotFound, "Not Found").
+	WithType(TypeNotFound))
 
 // ErrorForbidden singleton status in case permission denied
-var ErrorForbidden = Error(http.StatusForbidden, "Permission Denied")
+var ErrorForbidden = template(Error(http.StatusForbidden, "Permission Denied").
+	WithType(TypeForbidden))
 
 // ErrorInternal singleton status in case internal server error
-var ErrorInternal = Error(http.StatusInternalServerError, "Internal Server Error")
+var ErrorInternal = template(Error(http.StatusInternalServerError, "Internal Server Error").
+	WithType(TypeInternal))
 
 // ErrorMethodNotAllowed singleton status in case HTTP method is not allowed to use
-var ErrorMethodNotAllowed = Error(http.StatusMethodNotAllowed, "Method Not Allowed")
+var ErrorMethodNotAllowed = template(Error(http.StatusMethodNotAllowed, "Method Not Allowed").
+	WithType(TypeMethodNotAllowed))
 
-// ExpandError trys to cast the error into *StoreError
-// or generate a new *ServicError based on it
+// ExpandError trys to cast the error, or any error it wraps (see
+// StoreError.Unwrap), into *StoreError. Or generate a new *StoreError
+// wrapping it
 func ExpandError(err error) *StoreError {
 
 	if err == nil {
 		return nil
-	} else if serr, ok := err.(*StoreError); ok {
-		return serr
+	}
+	for cause := err; cause != nil; {
+		if serr, ok := cause.(*StoreError); ok {
+			return serr
+		}
+		wrapper, ok := cause.(interface {
+			Unwrap() error
+		})
+		if !ok {
+			break
+		}
+		cause = wrapper.Unwrap()
 	}
 
-	return Error(http.StatusInternalServerError, err.Error())
+	return Error(http.StatusInternalServerError, err.Error()).Wrap(err)
 }
 
 // ParseError reads and parse a given status message.
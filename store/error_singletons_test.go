@@ -24,6 +24,71 @@ func TestErrorSingletons(t *testing.T) {
 	}
 }
 
+func TestErrorSingletons_template(t *testing.T) {
+	cause := errors.New("disk full")
+	err := store.ErrorInternal.TellServer("error writing: %s", cause).Wrap(cause)
+
+	if err == store.ErrorInternal {
+		t.Errorf("expected a copy of the singleton")
+	}
+	if want, have := "error writing: disk full", err.ServerMsg; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "Internal Server Error", store.ErrorInternal.ServerMsg; want != have {
+		t.Errorf("singleton modified. expected %#v, got %#v", want, have)
+	}
+	if store.ErrorInternal.Cause != nil {
+		t.Errorf("singleton modified. expected no cause, got %#v", store.ErrorInternal.Cause)
+	}
+	if !err.Is(store.ErrorInternal) {
+		t.Errorf("expected the copy to be the singleton in Is")
+	}
+	if err.Is(store.ErrorNotFound) {
+		t.Errorf("expected the copy not to be another singleton in Is")
+	}
+
+	// copy of copy is modified in place
+	if want, have := err, err.TellClient("try again later"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "gone", store.ErrorNotFound.New().TellClient("gone").ClientMsg; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "Not Found", store.ErrorNotFound.ClientMsg; want != have {
+		t.Errorf("singleton modified. expected %#v, got %#v", want, have)
+	}
+}
+
+// wrapper wraps an error as the standard library would
+type wrapper struct {
+	msg string
+	err error
+}
+
+func (w wrapper) Error() string { return w.msg + ": " + w.err.Error() }
+
+func (w wrapper) Unwrap() error { return w.err }
+
+func TestExpandError_wrapped(t *testing.T) {
+	serr := store.ErrorNotFound.TellServer("no user of id 123")
+	err := wrapper{"finding user", wrapper{"loading profile", serr}}
+
+	if want, have := serr, store.ExpandError(err); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// other errors are wrapped as internal server error
+	cause := errors.New("connection reset")
+	other := wrapper{"finding user", cause}
+	expanded := store.ExpandError(other)
+	if want, have := http.StatusInternalServerError, expanded.Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := error(other), expanded.Unwrap(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
 func TestParseError_Singletons(t *testing.T) {
 
 	var code int
@@ -93,3 +93,77 @@ func TestErrorFormat(t *testing.T) {
 		t.Errorf("want: %#v, got %#v", want, have)
 	}
 }
+
+func TestStoreError_Wrap(t *testing.T) {
+	cause := fmt.Errorf("connection refused")
+	err := store.Error(50001, "database unavailable").
+		TellServer("").
+		Wrap(cause)
+
+	if want, have := cause, err.Unwrap(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := cause.Error(), err.ServerMsg; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "database unavailable", err.Error(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// server message is kept, if set
+	err = store.Error(50001, "database unavailable").Wrap(cause)
+	if want, have := "database unavailable", err.ServerMsg; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestStoreError_Is(t *testing.T) {
+	err := store.Error(40401, "no such user").WithType("urn:test:no-user")
+
+	if !err.Is(store.Error(40401, "other message").WithType("urn:test:no-user")) {
+		t.Errorf("expected error of same code and type to match")
+	}
+	if err.Is(store.Error(40401, "no such user")) {
+		t.Errorf("expected error of different type not to match")
+	}
+	if err.Is(store.Error(40402, "no such user").WithType("urn:test:no-user")) {
+		t.Errorf("expected error of different code not to match")
+	}
+	if err.Is(fmt.Errorf("no such user")) {
+		t.Errorf("expected non-StoreError not to match")
+	}
+}
+
+func TestStoreError_details(t *testing.T) {
+	err := store.Error(42201, "invalid payload").
+		WithFields(store.FieldError{Field: "email", Reason: "email", Message: "must be an email address"}).
+		WithFields(store.FieldError{Field: "name", Reason: "required", Message: "is required"}).
+		WithMeta("retry", false).
+		WithMeta("limit", 255)
+
+	if want, have := 2, len(err.Fields); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "name", err.Fields[1].Field; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 255, err.Meta["limit"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// copy could be modified without affecting the original
+	cp := err.New().WithMeta("limit", 1).WithFields(store.FieldError{Field: "id"})
+	cp.Fields[0].Field = "changed"
+	if want, have := 255, err.Meta["limit"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 2, len(err.Fields); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "email", err.Fields[0].Field; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 3, len(cp.Fields); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
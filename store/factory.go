@@ -20,11 +20,87 @@ type Factory interface {
 	Get(key interface{}) (srcKey interface{}, provider Provider)
 }
 
-// NewFactory returns the default Factory implementation
+// HookFactory is the optional interface of Factory
+// with hooks of stores (see Hook)
+type HookFactory interface {
+	Factory
+
+	// AddHook adds a hook on the event to the store of the key (store
+	// key). Hooks are invoked by stores obtained through Get in the
+	// order added
+	AddHook(key interface{}, evt HookEvent, hook Hook)
+
+	// Hooks returns the hooks of the store of the key (store key)
+	Hooks(key interface{}) Hooks
+}
+
+// FeedFactory is the optional interface of Factory
+// with ChangeBus of stores (see ChangeEvent)
+type FeedFactory interface {
+	Factory
+
+	// SetChangeBus sets the ChangeBus for stores obtained
+	// through Get to publish ChangeEvent to
+	SetChangeBus(bus *ChangeBus)
+
+	// ChangeBus returns the ChangeBus set, or nil if none
+	ChangeBus() *ChangeBus
+}
+
+// CacheFactory is the optional interface of Factory
+// with cache of stores (see CacheBackend)
+type CacheFactory interface {
+	Factory
+
+	// SetCache sets the CacheBackend to cache results of the store
+	// of the key (store key) obtained through Get (see NewCacheStore)
+	SetCache(key interface{}, cache CacheBackend)
+
+	// Cache returns the CacheBackend of the store of
+	// the key (store key), or nil if none
+	Cache(key interface{}) CacheBackend
+}
+
+// MetricsFactory is the optional interface of Factory
+// with metrics of stores and sources (see Metrics)
+type MetricsFactory interface {
+	Factory
+
+	// SetMetrics enables the metrics for all stores obtained
+	// through Get and their sources (see Metrics)
+	SetMetrics(m *Metrics)
+
+	// Metrics returns the metrics set, or nil if none
+	Metrics() *Metrics
+}
+
+// ReplicaFactory is the optional interface of Factory
+// with read replicas of stores (see Replicas)
+type ReplicaFactory interface {
+	Factory
+
+	// SetReplicas sets the read replica sources of the store of the
+	// key (store key). Stores obtained through Get read from the
+	// replicas, and write to the source set by Set (see Replicas)
+	SetReplicas(key interface{}, replicas *Replicas)
+
+	// Replicas returns the replicas of the store of
+	// the key (store key), or nil if none
+	Replicas(key interface{}) *Replicas
+}
+
+// NewFactory returns the default Factory implementation.
+// It also implements HookFactory, FeedFactory, CacheFactory,
+// MetricsFactory and ReplicaFactory
 func NewFactory() Factory {
 	return &factoryDef{
 		make(map[interface{}]Source),
 		make(map[interface{}]storeDef),
+		make(map[interface{}]Hooks),
+		nil,
+		make(map[interface{}]CacheBackend),
+		nil,
+		make(map[interface{}]*Replicas),
 	}
 }
 
@@ -34,10 +110,15 @@ type storeDef struct {
 	provider Provider
 }
 
-// factoryDef implements Factory
+// factoryDef implements Factory and its optional interfaces
 type factoryDef struct {
-	sources map[interface{}]Source
-	stores  map[interface{}]storeDef
+	sources  map[interface{}]Source
+	stores   map[interface{}]storeDef
+	hooks    map[interface{}]Hooks
+	bus      *ChangeBus
+	caches   map[interface{}]CacheBackend
+	metrics  *Metrics
+	replicas map[interface{}]*Replicas
 }
 
 // SetSource implements Factory.SetSource
@@ -66,6 +147,59 @@ func (d *factoryDef) Get(key interface{}) (interface{}, Provider) {
 	return nil, nil
 }
 
+// AddHook implements HookFactory.AddHook
+func (d *factoryDef) AddHook(key interface{}, evt HookEvent, hook Hook) {
+	if d.hooks[key] == nil {
+		d.hooks[key] = make(Hooks)
+	}
+	d.hooks[key][evt] = append(d.hooks[key][evt], hook)
+}
+
+// Hooks implements HookFactory.Hooks
+func (d *factoryDef) Hooks(key interface{}) Hooks {
+	return d.hooks[key]
+}
+
+// SetChangeBus implements FeedFactory.SetChangeBus
+func (d *factoryDef) SetChangeBus(bus *ChangeBus) {
+	d.bus = bus
+}
+
+// ChangeBus implements FeedFactory.ChangeBus
+func (d *factoryDef) ChangeBus() *ChangeBus {
+	return d.bus
+}
+
+// SetCache implements CacheFactory.SetCache
+func (d *factoryDef) SetCache(key interface{}, cache CacheBackend) {
+	d.caches[key] = cache
+}
+
+// Cache implements CacheFactory.Cache
+func (d *factoryDef) Cache(key interface{}) CacheBackend {
+	return d.caches[key]
+}
+
+// SetMetrics implements MetricsFactory.SetMetrics
+func (d *factoryDef) SetMetrics(m *Metrics) {
+	d.metrics = m
+}
+
+// Metrics implements MetricsFactory.Metrics
+func (d *factoryDef) Metrics() *Metrics {
+	return d.metrics
+}
+
+// SetReplicas implements ReplicaFactory.SetReplicas
+func (d *factoryDef) SetReplicas(key interface{}, replicas *Replicas) {
+	d.replicas[key] = replicas
+}
+
+// Replicas implements ReplicaFactory.Replicas
+func (d *factoryDef) Replicas(key interface{}) *Replicas {
+	return d.replicas[key]
+}
+
 // Conn is the interface to handle
 // database connections session to Source
 type Conn interface {
@@ -77,6 +211,24 @@ type Conn interface {
 	Close()
 }
 
+// TxConn is the interface of Conn which supports transaction.
+//
+// Between Begin and Commit / Rollback, Raw should return the
+// transaction session so Store provided with it would be bound
+// to the transaction
+type TxConn interface {
+	Conn
+
+	// Begin starts a transaction on the connection
+	Begin() error
+
+	// Commit commits the current transaction
+	Commit() error
+
+	// Rollback aborts the current transaction
+	Rollback() error
+}
+
 // Source provides connection and, if any, connection error
 type Source interface {
 	Open() (Conn, error)
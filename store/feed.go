@@ -0,0 +1,448 @@
+package store
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+)
+
+// ChangeOp is the write operation of a ChangeEvent
+type ChangeOp int
+
+// write operations of ChangeEvent
+const (
+	ChangeCreate ChangeOp = iota
+	ChangeUpdate
+	ChangeDelete
+)
+
+// String implements fmt.Stringer
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeCreate:
+		return "create"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// ChangeEvent describes the change of an entity by a write
+// operation of a store obtained through Get
+type ChangeEvent struct {
+
+	// Key is the store key of the store
+	Key interface{}
+
+	// Op is the write operation
+	Op ChangeOp
+
+	// Conds is the conditions of the operation
+	Conds Conds
+
+	// Old is a copy of the entity before the change.
+	// Nil for ChangeCreate
+	Old EntityPtr
+
+	// New is a copy of the entity after the change.
+	// Nil for ChangeDelete
+	New EntityPtr
+}
+
+// BackPressure is the policy of delivering ChangeEvent
+// to a Subscription with its buffer full
+type BackPressure int
+
+// back-pressure policies
+const (
+
+	// Block blocks the publisher until the subscriber
+	// receives from the buffer, or unsubscribes
+	Block BackPressure = iota
+
+	// DropNewest discards the event to deliver
+	DropNewest
+
+	// DropOldest discards the oldest event in the
+	// buffer to deliver the new one
+	DropOldest
+)
+
+// ChangeBus delivers ChangeEvent published by stores to subscribers in
+// process. Stores obtained through Get publish to the ChangeBus of the
+// Factory, if any (see FeedFactory)
+type ChangeBus struct {
+	mux  sync.RWMutex
+	subs map[*Subscription]bool
+}
+
+// NewChangeBus returns an empty ChangeBus
+func NewChangeBus() *ChangeBus {
+	return &ChangeBus{subs: make(map[*Subscription]bool)}
+}
+
+// Subscribe subscribes to events of the store keys, or all events if
+// no key is given. Events are buffered up to the size given. Events to
+// deliver with the buffer full are handled by the back-pressure policy.
+// Buffer of DropNewest or DropOldest is at least of size 1
+func (bus *ChangeBus) Subscribe(size int, policy BackPressure, keys ...interface{}) *Subscription {
+	if policy != Block && size < 1 {
+		size = 1 // events to drop need a buffer to drop from
+	}
+	ch := make(chan ChangeEvent, size)
+	sub := &Subscription{
+		C:      ch,
+		ch:     ch,
+		done:   make(chan struct{}),
+		bus:    bus,
+		policy: policy,
+	}
+	if len(keys) > 0 {
+		sub.keys = make(map[interface{}]bool, len(keys))
+		for _, key := range keys {
+			sub.keys[key] = true
+		}
+	}
+
+	bus.mux.Lock()
+	defer bus.mux.Unlock()
+	bus.subs[sub] = true
+	return sub
+}
+
+// Unsubscribe removes the subscription from the bus and
+// closes its channel. Unsubscribing twice is a no-op
+func (bus *ChangeBus) Unsubscribe(sub *Subscription) {
+	sub.once.Do(func() {
+		// wake up publishers blocked on the subscription
+		close(sub.done)
+
+		bus.mux.Lock()
+		delete(bus.subs, sub)
+		bus.mux.Unlock()
+
+		// close the channel after deliveries in flight
+		sub.mux.Lock()
+		defer sub.mux.Unlock()
+		sub.closed = true
+		close(sub.ch)
+	})
+}
+
+// Subscribed returns whether there is any subscription to the store key
+func (bus *ChangeBus) Subscribed(key interface{}) bool {
+	bus.mux.RLock()
+	defer bus.mux.RUnlock()
+	for sub := range bus.subs {
+		if sub.matches(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish delivers the event to all subscriptions to its store key.
+// Delivery is done without lock on the bus, so a subscriber blocking
+// the publisher (see Block) would not block Subscribe and Unsubscribe
+func (bus *ChangeBus) Publish(evt ChangeEvent) {
+	bus.mux.RLock()
+	subs := make([]*Subscription, 0, len(bus.subs))
+	for sub := range bus.subs {
+		if sub.matches(evt.Key) {
+			subs = append(subs, sub)
+		}
+	}
+	bus.mux.RUnlock()
+
+	for _, sub := range subs {
+		sub.deliver(evt)
+	}
+}
+
+// Subscription receives ChangeEvent from a ChangeBus
+type Subscription struct {
+
+	// C is the channel to receive events from. It is
+	// closed when the subscription is unsubscribed
+	C <-chan ChangeEvent
+
+	ch      chan ChangeEvent
+	done    chan struct{}
+	once    sync.Once
+	mux     sync.RWMutex // held by deliveries in flight
+	closed  bool
+	bus     *ChangeBus
+	keys    map[interface{}]bool
+	policy  BackPressure
+	dropped uint64
+}
+
+// Unsubscribe removes the subscription from its bus
+func (sub *Subscription) Unsubscribe() {
+	sub.bus.Unsubscribe(sub)
+}
+
+// Dropped returns the number of events discarded
+// by the back-pressure policy
+func (sub *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&sub.dropped)
+}
+
+// matches returns whether the subscription is to the store key
+func (sub *Subscription) matches(key interface{}) bool {
+	return sub.keys == nil || sub.keys[key]
+}
+
+// deliver sends the event to the channel by the back-pressure policy
+func (sub *Subscription) deliver(evt ChangeEvent) {
+	sub.mux.RLock()
+	defer sub.mux.RUnlock()
+	if sub.closed {
+		return
+	}
+	switch sub.policy {
+	case DropNewest:
+		select {
+		case sub.ch <- evt:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case sub.ch <- evt:
+				return
+			default:
+			}
+			select {
+			case <-sub.ch:
+				atomic.AddUint64(&sub.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case sub.ch <- evt:
+		case <-sub.done:
+		}
+	}
+}
+
+// feedStore implements Store that publishes ChangeEvent
+// of successful write operations.
+//
+// It implements BulkStore, UpsertStore and ContextStore with the
+// native operations of the wrapped Store, if any
+type feedStore struct {
+	Store
+	key     interface{}
+	bus     *ChangeBus
+	publish func(evts []ChangeEvent)
+}
+
+// Create implements Store
+func (s *feedStore) Create(c Conds, ep EntityPtr) error {
+	return s.create(c, ep, func() error {
+		return s.Store.Create(c, ep)
+	})
+}
+
+// Update implements Store
+func (s *feedStore) Update(c Conds, ep EntityPtr) error {
+	return s.update(nil, c, ep, func() error {
+		return s.Store.Update(c, ep)
+	})
+}
+
+// Delete implements Store
+func (s *feedStore) Delete(c Conds) error {
+	return s.delete(nil, c, func() error {
+		return s.Store.Delete(c)
+	})
+}
+
+// CreateMany implements BulkStore. Events are published
+// for the entities created, even if others failed
+func (s *feedStore) CreateMany(c Conds, el EntityListPtr) (err error) {
+	err = CreateMany(s.Store, c, el)
+	berr, ok := err.(BulkError)
+	if (err != nil && !ok) || !s.bus.Subscribed(s.key) {
+		return
+	}
+	list, lerr := bulkList(el)
+	if lerr != nil {
+		return
+	}
+	var evts []ChangeEvent
+	for i := 0; i < list.Len(); i++ {
+		if ok && berr[i] != nil {
+			continue
+		}
+		evts = append(evts, ChangeEvent{Key: s.key, Op: ChangeCreate, Conds: c,
+			New: copyPtr(list.Index(i).Addr().Interface())})
+	}
+	s.publish(evts)
+	return
+}
+
+// UpdateMany implements BulkStore. New of the events are
+// the entities matched with the fields set
+func (s *feedStore) UpdateMany(c Conds, fields map[string]interface{}) (err error) {
+	if !s.bus.Subscribed(s.key) {
+		return UpdateMany(s.Store, c, fields)
+	}
+	old, err := s.matched(nil, c)
+	if err != nil {
+		return
+	}
+	if err = UpdateMany(s.Store, c, fields); err != nil {
+		return
+	}
+	evts := make([]ChangeEvent, len(old))
+	for i := range old {
+		ep := copyPtr(old[i])
+		for name, v := range fields {
+			setProp(reflect.ValueOf(ep).Elem(), name, v)
+		}
+		evts[i] = ChangeEvent{Key: s.key, Op: ChangeUpdate, Conds: c, Old: old[i], New: ep}
+	}
+	s.publish(evts)
+	return
+}
+
+// Upsert implements UpsertStore. ChangeCreate is published
+// if no entity matched the conditions, or ChangeUpdate if any
+func (s *feedStore) Upsert(c Conds, ep EntityPtr) (err error) {
+	if !s.bus.Subscribed(s.key) {
+		return Upsert(s.Store, c, ep)
+	}
+	old, err := s.matched(nil, c)
+	if err != nil {
+		return
+	}
+	if err = Upsert(s.Store, c, ep); err != nil {
+		return
+	}
+	if len(old) == 0 {
+		s.publish([]ChangeEvent{{Key: s.key, Op: ChangeCreate, Conds: c, New: copyPtr(ep)}})
+		return
+	}
+	evts := make([]ChangeEvent, len(old))
+	for i := range old {
+		evts[i] = ChangeEvent{Key: s.key, Op: ChangeUpdate, Conds: c, Old: old[i], New: copyPtr(ep)}
+	}
+	s.publish(evts)
+	return
+}
+
+// CreateContext implements ContextStore
+func (s *feedStore) CreateContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	return s.create(c, ep, func() error {
+		return NewContextStore(s.Store).CreateContext(ctx, c, ep)
+	})
+}
+
+// SearchContext implements ContextStore
+func (s *feedStore) SearchContext(ctx context.Context, q Query) Result {
+	return NewContextStore(s.Store).SearchContext(ctx, q)
+}
+
+// OneContext implements ContextStore
+func (s *feedStore) OneContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	return NewContextStore(s.Store).OneContext(ctx, c, ep)
+}
+
+// UpdateContext implements ContextStore
+func (s *feedStore) UpdateContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	return s.update(ctx, c, ep, func() error {
+		return NewContextStore(s.Store).UpdateContext(ctx, c, ep)
+	})
+}
+
+// DeleteContext implements ContextStore
+func (s *feedStore) DeleteContext(ctx context.Context, c Conds) error {
+	return s.delete(ctx, c, func() error {
+		return NewContextStore(s.Store).DeleteContext(ctx, c)
+	})
+}
+
+// create runs the create operation and publishes its event
+func (s *feedStore) create(c Conds, ep EntityPtr, op func() error) (err error) {
+	if err = op(); err != nil || !s.bus.Subscribed(s.key) {
+		return
+	}
+	s.publish([]ChangeEvent{{Key: s.key, Op: ChangeCreate, Conds: c, New: copyPtr(ep)}})
+	return
+}
+
+// update runs the update operation and publishes
+// the events of the entities matched before it
+func (s *feedStore) update(ctx context.Context, c Conds, ep EntityPtr, op func() error) (err error) {
+	if !s.bus.Subscribed(s.key) {
+		return op()
+	}
+	old, err := s.matched(ctx, c)
+	if err != nil {
+		return
+	}
+	if err = op(); err != nil {
+		return
+	}
+	evts := make([]ChangeEvent, len(old))
+	for i := range old {
+		evts[i] = ChangeEvent{Key: s.key, Op: ChangeUpdate, Conds: c, Old: old[i], New: copyPtr(ep)}
+	}
+	s.publish(evts)
+	return
+}
+
+// delete runs the delete operation and publishes
+// the events of the entities matched before it
+func (s *feedStore) delete(ctx context.Context, c Conds, op func() error) (err error) {
+	if !s.bus.Subscribed(s.key) {
+		return op()
+	}
+	old, err := s.matched(ctx, c)
+	if err != nil {
+		return
+	}
+	if err = op(); err != nil {
+		return
+	}
+	evts := make([]ChangeEvent, len(old))
+	for i := range old {
+		evts[i] = ChangeEvent{Key: s.key, Op: ChangeDelete, Conds: c, Old: old[i]}
+	}
+	s.publish(evts)
+	return
+}
+
+// matched returns pointers to the entities matching the
+// conditions, searched with the context if not nil
+func (s *feedStore) matched(ctx context.Context, c Conds) (eps []EntityPtr, err error) {
+	el := s.AllocEntityList()
+	var res Result
+	if ctx != nil {
+		res = NewContextStore(s.Store).SearchContext(ctx, NewQuery().SetConds(c))
+	} else {
+		res = s.Store.Search(NewQuery().SetConds(c))
+	}
+	defer res.Close()
+	if err = res.All(el); err != nil {
+		return
+	}
+	list := reflect.ValueOf(el).Elem()
+	eps = make([]EntityPtr, list.Len())
+	for i := range eps {
+		if item := list.Index(i); item.Kind() == reflect.Ptr {
+			eps[i] = item.Interface()
+		} else {
+			eps[i] = item.Addr().Interface()
+		}
+	}
+	return
+}
@@ -0,0 +1,416 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+	"golang.org/x/net/context"
+)
+
+// noopTxConn implements store.TxConn with the
+// transaction operations doing nothing
+type noopTxConn struct {
+	store.Conn
+}
+
+func (conn noopTxConn) Begin() error    { return nil }
+func (conn noopTxConn) Commit() error   { return nil }
+func (conn noopTxConn) Rollback() error { return nil }
+
+func feedContext(bus *store.ChangeBus) context.Context {
+	factory := store.NewFactory()
+	factory.(store.FeedFactory).SetChangeBus(bus)
+	return hookContext(factory)
+}
+
+func receive(t *testing.T, sub *store.Subscription) (evt store.ChangeEvent) {
+	select {
+	case evt = <-sub.C:
+	case <-time.After(time.Second):
+		t.Fatalf("timeout receiving event")
+	}
+	return
+}
+
+func TestChangeBus(t *testing.T) {
+	bus := store.NewChangeBus()
+	sub := bus.Subscribe(10, store.Block, hookKey(0))
+	defer sub.Unsubscribe()
+	ctx := feedContext(bus)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, hookKey(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	e := &bulkEntity{ID: "1", Name: "foo"}
+	if err := s.Create(nil, e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	evt := receive(t, sub)
+	if want, have := store.ChangeCreate, evt.Op; want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+	if want, have := hookKey(0), evt.Key; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if evt.Old != nil {
+		t.Errorf("expected nil, got %#v", evt.Old)
+	}
+	if want, have := "foo", evt.New.(*bulkEntity).Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	e.Name = "bar"
+	if err := s.Update(store.NewConds().Add("id", "1"), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	evt = receive(t, sub)
+	if want, have := store.ChangeUpdate, evt.Op; want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+	if want, have := "foo", evt.Old.(*bulkEntity).Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "bar", evt.New.(*bulkEntity).Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if err := s.Delete(store.NewConds().Add("id", "1")); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	evt = receive(t, sub)
+	if want, have := store.ChangeDelete, evt.Op; want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+	if want, have := "bar", evt.Old.(*bulkEntity).Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if evt.New != nil {
+		t.Errorf("expected nil, got %#v", evt.New)
+	}
+
+	// failed write and write matching nothing publish nothing
+	if err := s.Create(nil, &struct{}{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := s.Delete(store.NewConds().Add("id", "1")); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	select {
+	case evt := <-sub.C:
+		t.Errorf("unexpected event: %#v", evt)
+	default:
+	}
+}
+
+// closeStore counts the results closed
+type closeStore struct {
+	store.Store
+	opened, closed *int
+}
+
+func (s closeStore) Search(q store.Query) store.Result {
+	*s.opened++
+	return closeResult{s.Store.Search(q), s.closed}
+}
+
+type closeResult struct {
+	store.Result
+	closed *int
+}
+
+func (res closeResult) Close() error {
+	*res.closed++
+	return res.Result.Close()
+}
+
+func TestChangeBus_closeResult(t *testing.T) {
+	var opened, closed int
+	provider := memstore.Provider("entity", &bulkEntity{})
+	factory := store.NewFactory()
+	factory.SetSource(store.DefaultSrc, memstore.NewSource())
+	factory.Set(hookKey(0), store.DefaultSrc, func(sess interface{}) (store.Store, error) {
+		s, err := provider(sess)
+		return closeStore{s, &opened, &closed}, err
+	})
+	factory.(store.FeedFactory).SetChangeBus(store.NewChangeBus())
+	sub := factory.(store.FeedFactory).ChangeBus().Subscribe(10, store.DropOldest)
+	defer sub.Unsubscribe()
+	ctx := store.WithFactory(context.Background(), factory)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, hookKey(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	c := store.NewConds().Add("id", "1")
+	if err := s.Update(c, &bulkEntity{ID: "1"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Delete(c); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// results searched for the events are closed
+	if want, have := 2, opened; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := opened, closed; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestChangeBus_keys(t *testing.T) {
+	bus := store.NewChangeBus()
+	other := bus.Subscribe(1, store.DropNewest, hookKey(1))
+	defer other.Unsubscribe()
+	if bus.Subscribed(hookKey(0)) {
+		t.Errorf("expected no subscription to hookKey(0)")
+	}
+
+	all := bus.Subscribe(1, store.DropNewest)
+	defer all.Unsubscribe()
+	if !bus.Subscribed(hookKey(0)) {
+		t.Errorf("expected subscription to hookKey(0)")
+	}
+
+	bus.Publish(store.ChangeEvent{Key: hookKey(0)})
+	if want, have := 1, len(all.C); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 0, len(other.C); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestChangeBus_transaction(t *testing.T) {
+	bus := store.NewChangeBus()
+	sub := bus.Subscribe(10, store.Block)
+	defer sub.Unsubscribe()
+
+	factory := store.NewFactory()
+	factory.(store.FeedFactory).SetChangeBus(bus)
+	src := memstore.NewSource()
+	factory.SetSource(store.DefaultSrc, store.SourceFunc(func() (store.Conn, error) {
+		conn, err := src.Open()
+		return noopTxConn{conn}, err
+	}))
+	factory.Set(hookKey(0), store.DefaultSrc, memstore.Provider("entity", &bulkEntity{}))
+	ctx := store.WithFactory(context.Background(), factory)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, hookKey(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// rolled back
+	if err := store.Begin(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Create(nil, &bulkEntity{Name: "foo"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := store.Rollback(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 0, len(sub.C); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// committed
+	if err := store.Begin(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Create(nil, &bulkEntity{Name: "bar"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 0, len(sub.C); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if err := store.Commit(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "bar", receive(t, sub).New.(*bulkEntity).Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestChangeBus_backPressure(t *testing.T) {
+	bus := store.NewChangeBus()
+	newest := bus.Subscribe(2, store.DropNewest)
+	defer newest.Unsubscribe()
+	oldest := bus.Subscribe(2, store.DropOldest)
+	defer oldest.Unsubscribe()
+
+	for i := 0; i < 3; i++ {
+		bus.Publish(store.ChangeEvent{Key: i})
+	}
+
+	if want, have := uint64(1), newest.Dropped(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	for _, want := range []int{0, 1} {
+		if have := (<-newest.C).Key; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+
+	if want, have := uint64(1), oldest.Dropped(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	for _, want := range []int{1, 2} {
+		if have := (<-oldest.C).Key; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+}
+
+func TestChangeBus_backPressureUnbuffered(t *testing.T) {
+	bus := store.NewChangeBus()
+	newest := bus.Subscribe(0, store.DropNewest)
+	oldest := bus.Subscribe(0, store.DropOldest)
+
+	published := make(chan bool)
+	go func() {
+		for i := 0; i < 2; i++ {
+			bus.Publish(store.ChangeEvent{Key: i})
+		}
+		published <- true
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatalf("expected publisher not blocked")
+	}
+
+	if want, have := 0, receive(t, newest).Key; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 1, receive(t, oldest).Key; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	for _, sub := range []*store.Subscription{newest, oldest} {
+		if want, have := uint64(1), sub.Dropped(); want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+		sub.Unsubscribe()
+	}
+}
+
+func TestChangeBus_block(t *testing.T) {
+	bus := store.NewChangeBus()
+	sub := bus.Subscribe(0, store.Block)
+
+	published := make(chan bool)
+	go func() {
+		bus.Publish(store.ChangeEvent{Key: 0})
+		published <- true
+	}()
+
+	select {
+	case <-published:
+		t.Fatalf("expected publisher to block")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// unsubscribing releases the publisher
+	sub.Unsubscribe()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatalf("expected publisher to be released")
+	}
+	if _, ok := <-sub.C; ok {
+		t.Errorf("expected channel to be closed")
+	}
+	sub.Unsubscribe()
+}
+
+func TestChangeBus_blockSubscribe(t *testing.T) {
+	bus := store.NewChangeBus()
+	sub := bus.Subscribe(0, store.Block)
+	defer sub.Unsubscribe()
+	go bus.Publish(store.ChangeEvent{Key: 0})
+	time.Sleep(10 * time.Millisecond)
+
+	// blocked publisher does not block subscribing
+	subscribed := make(chan bool)
+	go func() {
+		bus.Subscribe(1, store.DropNewest).Unsubscribe()
+		subscribed <- true
+	}()
+	select {
+	case <-subscribed:
+	case <-time.After(time.Second):
+		t.Fatalf("expected subscribing not blocked by publisher")
+	}
+	receive(t, sub)
+}
+
+func TestChangeBus_bulk(t *testing.T) {
+	bus := store.NewChangeBus()
+	sub := bus.Subscribe(10, store.Block, hookKey(0))
+	defer sub.Unsubscribe()
+	ctx := feedContext(bus)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, hookKey(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if _, ok := s.(store.BulkStore); !ok {
+		t.Errorf("expected store.BulkStore, got %#v", s)
+	}
+	if _, ok := s.(store.UpsertStore); !ok {
+		t.Errorf("expected store.UpsertStore, got %#v", s)
+	}
+	if _, ok := s.(store.ContextStore); !ok {
+		t.Errorf("expected store.ContextStore, got %#v", s)
+	}
+
+	el := &[]bulkEntity{{ID: "1", Name: "foo"}, {ID: "2", Name: "bar"}}
+	if err := store.CreateMany(s, nil, el); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	for _, want := range []string{"foo", "bar"} {
+		evt := receive(t, sub)
+		if have := evt.New.(*bulkEntity).Name; evt.Op != store.ChangeCreate || want != have {
+			t.Errorf("expected create of %#v, got %s of %#v", want, evt.Op, have)
+		}
+	}
+
+	if err := store.UpdateMany(s, store.NewConds().Add("id", "1"),
+		map[string]interface{}{"name": "baz"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	evt := receive(t, sub)
+	if want, have := store.ChangeUpdate, evt.Op; want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+	if want, have := "foo", evt.Old.(*bulkEntity).Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "baz", evt.New.(*bulkEntity).Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if err := store.Upsert(s, store.NewConds().Add("id", "3"), &bulkEntity{Name: "qux"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := store.ChangeCreate, receive(t, sub).Op; want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+
+	cs := s.(store.ContextStore)
+	if err := cs.DeleteContext(context.Background(), store.NewConds().Add("id", "3")); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := store.ChangeDelete, receive(t, sub).Op; want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+}
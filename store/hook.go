@@ -0,0 +1,193 @@
+package store
+
+import (
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+// HookEvent is the lifecycle event of entities to invoke Hook on
+type HookEvent int
+
+// lifecycle events of entities
+const (
+	BeforeCreate HookEvent = iota
+	AfterCreate
+	BeforeUpdate
+	AfterUpdate
+	BeforeDelete
+	AfterDelete
+	AfterLoad
+)
+
+// String implements fmt.Stringer
+func (evt HookEvent) String() string {
+	switch evt {
+	case BeforeCreate:
+		return "BeforeCreate"
+	case AfterCreate:
+		return "AfterCreate"
+	case BeforeUpdate:
+		return "BeforeUpdate"
+	case AfterUpdate:
+		return "AfterUpdate"
+	case BeforeDelete:
+		return "BeforeDelete"
+	case AfterDelete:
+		return "AfterDelete"
+	case AfterLoad:
+		return "AfterLoad"
+	}
+	return "HookEvent(unknown)"
+}
+
+// Hook is invoked on a lifecycle event of entities in a store obtained
+// by Get. The context is the one given to Get. The conditions are of
+// the operation (or the query conditions for AfterLoad). The entity
+// pointer is nil for BeforeDelete and AfterDelete.
+//
+// Hook of Before events could veto the operation by returning an
+// error (preferably a StoreError), which is returned to the caller
+// as is. Error of After events is also returned to the caller,
+// though the operation has been done
+type Hook func(ctx context.Context, c Conds, ep EntityPtr) error
+
+// Hooks contains hooks of a store, by event
+type Hooks map[HookEvent][]Hook
+
+// invoke invokes the hooks of the event in the order added.
+// Stops at the first error
+func (hooks Hooks) invoke(ctx context.Context, evt HookEvent, c Conds, ep EntityPtr) (err error) {
+	for _, hook := range hooks[evt] {
+		if err = hook(ctx, c, ep); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// WithHooks returns the Store wrapped to invoke the hooks on operations.
+// Returns the Store as is if there is no hook.
+//
+// Bulk operations and Upsert (see CreateMany, UpdateMany, DeleteMany,
+// Upsert) on the wrapped Store fall back to the basic operations, so
+// the hooks are invoked for each entity
+func WithHooks(ctx context.Context, s Store, hooks Hooks) Store {
+	if len(hooks) == 0 {
+		return s
+	}
+	return &hookStore{Store: s, ctx: ctx, hooks: hooks}
+}
+
+// hookStore implements Store with hooks invoked on operations
+type hookStore struct {
+	Store
+	ctx   context.Context
+	hooks Hooks
+}
+
+// Create implements Store
+func (s *hookStore) Create(c Conds, ep EntityPtr) (err error) {
+	if err = s.hooks.invoke(s.ctx, BeforeCreate, c, ep); err != nil {
+		return
+	}
+	if err = s.Store.Create(c, ep); err != nil {
+		return
+	}
+	return s.hooks.invoke(s.ctx, AfterCreate, c, ep)
+}
+
+// Search implements Store
+func (s *hookStore) Search(q Query) Result {
+	res := s.Store.Search(q)
+	if len(s.hooks[AfterLoad]) == 0 {
+		return res
+	}
+	var c Conds
+	if q != nil {
+		c = q.GetConds()
+	}
+	return &hookResult{Result: res, store: s, conds: c}
+}
+
+// One implements Store
+func (s *hookStore) One(c Conds, ep EntityPtr) (err error) {
+	if err = s.Store.One(c, ep); err != nil {
+		return
+	}
+	return s.hooks.invoke(s.ctx, AfterLoad, c, ep)
+}
+
+// Update implements Store
+func (s *hookStore) Update(c Conds, ep EntityPtr) (err error) {
+	if err = s.hooks.invoke(s.ctx, BeforeUpdate, c, ep); err != nil {
+		return
+	}
+	if err = s.Store.Update(c, ep); err != nil {
+		return
+	}
+	return s.hooks.invoke(s.ctx, AfterUpdate, c, ep)
+}
+
+// Delete implements Store
+func (s *hookStore) Delete(c Conds) (err error) {
+	if err = s.hooks.invoke(s.ctx, BeforeDelete, c, nil); err != nil {
+		return
+	}
+	if err = s.Store.Delete(c); err != nil {
+		return
+	}
+	return s.hooks.invoke(s.ctx, AfterDelete, c, nil)
+}
+
+// hookResult implements Result with AfterLoad
+// hooks invoked on every entity fetched
+type hookResult struct {
+	Result
+	store *hookStore
+	conds Conds
+	err   error
+}
+
+// All implements Result
+func (res *hookResult) All(el interface{}) (err error) {
+	if err = res.Result.All(el); err != nil {
+		return
+	}
+	list := reflect.ValueOf(el)
+	for list.Kind() == reflect.Ptr && !list.IsNil() {
+		list = list.Elem()
+	}
+	if list.Kind() != reflect.Slice {
+		return
+	}
+	for i := 0; i < list.Len(); i++ {
+		item := list.Index(i)
+		if item.Kind() != reflect.Ptr {
+			item = item.Addr()
+		}
+		if err = res.store.hooks.invoke(res.store.ctx, AfterLoad, res.conds, item.Interface()); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Next implements Result
+func (res *hookResult) Next(ep EntityPtr) bool {
+	if res.err != nil || !res.Result.Next(ep) {
+		return false
+	}
+	if res.err = res.store.hooks.invoke(res.store.ctx, AfterLoad, res.conds, ep); res.err != nil {
+		return false
+	}
+	return true
+}
+
+// Err implements Result
+func (res *hookResult) Err() error {
+	if res.err != nil {
+		return res.err
+	}
+	return res.Result.Err()
+}
@@ -0,0 +1,169 @@
+package store_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+	"golang.org/x/net/context"
+)
+
+type hookKey int
+
+func hookContext(factory store.Factory) context.Context {
+	factory.SetSource(store.DefaultSrc, memstore.NewSource())
+	factory.Set(hookKey(0), store.DefaultSrc, memstore.Provider("entity", &bulkEntity{}))
+	return store.WithFactory(context.Background(), factory)
+}
+
+func TestHookEvent_String(t *testing.T) {
+	if want, have := "BeforeCreate", store.BeforeCreate.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "AfterLoad", store.AfterLoad.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestWithHooks_none(t *testing.T) {
+	s := bulkStore(t)
+	if want, have := s, store.WithHooks(context.Background(), s, nil); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestGet_hooks(t *testing.T) {
+	factory := store.NewFactory()
+	events := make([]store.HookEvent, 0)
+	record := func(evt store.HookEvent) store.Hook {
+		return func(ctx context.Context, c store.Conds, ep store.EntityPtr) error {
+			events = append(events, evt)
+			return nil
+		}
+	}
+	for _, evt := range []store.HookEvent{
+		store.BeforeCreate, store.AfterCreate,
+		store.BeforeUpdate, store.AfterUpdate,
+		store.BeforeDelete, store.AfterDelete,
+		store.AfterLoad,
+	} {
+		factory.(store.HookFactory).AddHook(hookKey(0), evt, record(evt))
+	}
+	ctx := hookContext(factory)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, hookKey(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	e := &bulkEntity{ID: "1", Name: "foo"}
+	if err := s.Create(nil, e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Update(store.NewConds().Add("id", "1"), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.One(store.NewConds().Add("id", "1"), &bulkEntity{}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Search(store.NewQuery()).All(&[]bulkEntity{}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	res := s.Search(store.NewQuery())
+	for e := s.AllocEntity(); res.Next(e); {
+	}
+	if err := res.Err(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Delete(store.NewConds().Add("id", "1")); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	expected := []store.HookEvent{
+		store.BeforeCreate, store.AfterCreate,
+		store.BeforeUpdate, store.AfterUpdate,
+		store.AfterLoad, store.AfterLoad, store.AfterLoad,
+		store.BeforeDelete, store.AfterDelete,
+	}
+	if want, have := len(expected), len(events); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	for i := range expected {
+		if want, have := expected[i], events[i]; want != have {
+			t.Errorf("event %d: expected %s, got %s", i, want, have)
+		}
+	}
+}
+
+func TestGet_hooksVeto(t *testing.T) {
+	factory := store.NewFactory()
+	factory.(store.HookFactory).AddHook(hookKey(0), store.BeforeCreate,
+		func(ctx context.Context, c store.Conds, ep store.EntityPtr) error {
+			if ep.(*bulkEntity).Name == "bad" {
+				return store.Error(http.StatusForbidden, "bad entity")
+			}
+			return nil
+		})
+	ctx := hookContext(factory)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, hookKey(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	el := &[]bulkEntity{{Name: "foo"}, {Name: "bad"}}
+	berr, ok := store.CreateMany(s, nil, el).(store.BulkError)
+	if !ok {
+		t.Fatalf("expected store.BulkError")
+	}
+	if berr[0] != nil {
+		t.Errorf("unexpected error: %#v", berr[0].Error())
+	}
+	if berr[1] == nil {
+		t.Fatalf("expected item 1 to be vetoed")
+	}
+	if want, have := http.StatusForbidden, berr[1].Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	found := &[]bulkEntity{}
+	if err := s.Search(store.NewQuery()).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 1, len(*found); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestGet_hooksAfterLoad(t *testing.T) {
+	factory := store.NewFactory()
+	factory.(store.HookFactory).AddHook(hookKey(0), store.AfterLoad,
+		func(ctx context.Context, c store.Conds, ep store.EntityPtr) error {
+			ep.(*bulkEntity).Name = "loaded"
+			return nil
+		})
+	ctx := hookContext(factory)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, hookKey(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Create(nil, &bulkEntity{Name: "foo"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	found := &[]bulkEntity{}
+	if err := s.Search(store.NewQuery()).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 1, len(*found); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "loaded", (*found)[0].Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
@@ -0,0 +1,347 @@
+package memstore
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gourd/kit/store"
+)
+
+// match tells if the given struct value satisfies the conditions.
+// Nil or empty conditions matches everything
+func match(val reflect.Value, cs store.Conds) (ok bool, err error) {
+	if cs == nil || len(cs.GetAll()) == 0 {
+		return true, nil
+	}
+
+	rel := cs.GetRel()
+	if rel != store.And && rel != store.Or {
+		err = fmt.Errorf("incorrect value of Rel in %#v", cs)
+		return
+	}
+
+	for _, cond := range cs.GetAll() {
+		if ok, err = matchCond(val, cond); err != nil {
+			return
+		}
+		if rel == store.And && !ok {
+			return false, nil
+		} else if rel == store.Or && ok {
+			return true, nil
+		}
+	}
+	return rel == store.And, nil
+}
+
+// matchCond tells if the given struct value satisfies a single Cond
+func matchCond(val reflect.Value, cond store.Cond) (ok bool, err error) {
+
+	// nested conditions
+	if cond.Prop == "" {
+		if v, isConds := cond.Value.(store.Conds); isConds {
+			return match(val, v)
+		}
+		err = fmt.Errorf("unsupported condition without property: %#v", cond.Value)
+		return
+	}
+
+	// parse "prop op" into property name and operator
+	name, op, err := parseProp(cond)
+	if err != nil {
+		return
+	}
+	idx, found := fieldIndex(val.Type(), name)
+	if !found {
+		err = fmt.Errorf("property %#v not found in %s", name, val.Type())
+		return
+	}
+	field := val.FieldByIndex(idx).Interface()
+
+	switch op {
+	case store.Eq:
+		return equals(field, cond.Value), nil
+	case store.Ne:
+		return !equals(field, cond.Value), nil
+	case store.In, store.NotIn:
+		var values []interface{}
+		if values, err = valueList(cond.Value); err != nil {
+			return
+		}
+		for _, v := range values {
+			if equals(field, v) {
+				ok = true
+				break
+			}
+		}
+		return ok == (op == store.In), nil
+	case store.Like, store.Prefix:
+		str, isStr := indirect(field).(string)
+		if !isStr {
+			err = fmt.Errorf("property %#v is not string", name)
+			return
+		}
+		if op == store.Prefix {
+			return strings.HasPrefix(str, fmt.Sprintf("%s", cond.Value)), nil
+		}
+		return likeMatch(fmt.Sprintf("%s", cond.Value), str), nil
+	case store.IsNull:
+		return indirect(field) == nil, nil
+	case store.NotNull:
+		return indirect(field) != nil, nil
+	case store.Between:
+		var values []interface{}
+		if values, err = valueList(cond.Value); err != nil {
+			return
+		} else if len(values) != 2 {
+			err = fmt.Errorf("BETWEEN expects 2 values, got %#v", cond.Value)
+			return
+		}
+		var lower, upper int
+		if lower, err = compare(field, values[0]); err != nil {
+			return
+		}
+		if upper, err = compare(field, values[1]); err != nil {
+			return
+		}
+		return lower >= 0 && upper <= 0, nil
+	}
+
+	// NULL is not comparable to any value, as in SQL
+	if indirect(field) == nil {
+		return false, nil
+	}
+	c, err := compare(field, cond.Value)
+	if err != nil {
+		return
+	}
+	switch op {
+	case store.Gt:
+		ok = c > 0
+	case store.Gte:
+		ok = c >= 0
+	case store.Lt:
+		ok = c < 0
+	case store.Lte:
+		ok = c <= 0
+	default:
+		err = fmt.Errorf("unsupported operator %s in %#v", op, cond.Prop)
+	}
+	return
+}
+
+// propOps maps the operator string in "prop op" to Op
+var propOps = map[string]store.Op{
+	"=":      store.Eq,
+	"==":     store.Eq,
+	"!=":     store.Ne,
+	"<>":     store.Ne,
+	">":      store.Gt,
+	">=":     store.Gte,
+	"<":      store.Lt,
+	"<=":     store.Lte,
+	"IN":     store.In,
+	"NOT IN": store.NotIn,
+	"LIKE":   store.Like,
+}
+
+// parseProp splits a property string like "age >=" into
+// the property name and the operator. If the Cond has operator
+// other than Eq, the operator of Cond is used
+func parseProp(cond store.Cond) (name string, op store.Op, err error) {
+	parts := strings.Fields(cond.Prop)
+	if len(parts) == 0 {
+		err = fmt.Errorf("empty property")
+		return
+	}
+	name, op = parts[0], cond.Op
+	if len(parts) == 1 {
+		return
+	}
+
+	opStr := strings.ToUpper(strings.Join(parts[1:], " "))
+	propOp, ok := propOps[opStr]
+	if !ok {
+		err = fmt.Errorf("unsupported operator %#v in %#v", opStr, cond.Prop)
+	} else if op != store.Eq && propOp != op {
+		err = fmt.Errorf("operator %#v in %#v conflicts with %s", opStr, cond.Prop, op)
+	} else {
+		op = propOp
+	}
+	return
+}
+
+// valueList reads a slice or array value as []interface{}
+func valueList(v interface{}) (list []interface{}, err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		err = fmt.Errorf("expected slice of values, got %#v", v)
+		return
+	}
+	list = make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return
+}
+
+// likeMatch tells if the string matches the SQL LIKE pattern
+// where "%" matches any sequence and "_" matches any single
+// character. Matching is case-sensitive
+func likeMatch(pattern, str string) bool {
+	expr := make([]string, 0, len(pattern))
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			expr = append(expr, ".*")
+		case '_':
+			expr = append(expr, ".")
+		default:
+			expr = append(expr, regexp.QuoteMeta(string(r)))
+		}
+	}
+	re, err := regexp.Compile("^(?s:" + strings.Join(expr, "") + ")$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(str)
+}
+
+// fieldIndex finds the index of struct field by the given property
+// name. It matches the name in db tag, the field name, then the field
+// name case-insensitively
+func fieldIndex(typ reflect.Type, name string) (idx []int, ok bool) {
+	var fold []int
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue // skip unexported fields
+		}
+		if tag := strings.Split(field.Tag.Get("db"), ",")[0]; tag == name {
+			return field.Index, true
+		}
+		if field.Name == name {
+			idx, ok = field.Index, true
+		} else if fold == nil && strings.EqualFold(field.Name, name) {
+			fold = field.Index
+		}
+	}
+	if !ok && fold != nil {
+		idx, ok = fold, true
+	}
+	return
+}
+
+// indirect dereferences pointer values. Nil pointer
+// would be returned as nil interface
+func indirect(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// equals tells if the 2 values are equal
+func equals(a, b interface{}) bool {
+	a, b = indirect(a), indirect(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if c, err := compare(a, b); err == nil {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare returns -1, 0 or 1 if a is less than, equals to
+// or greater than b. Returns error if the values are not comparable
+func compare(a, b interface{}) (c int, err error) {
+	a, b = indirect(a), indirect(b)
+	if a == nil || b == nil {
+		err = fmt.Errorf("unable to compare %#v with %#v", a, b)
+		return
+	}
+
+	// time values
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		if !ok {
+			err = fmt.Errorf("unable to compare %#v with %#v", a, b)
+			return
+		}
+		switch {
+		case ta.Before(tb):
+			c = -1
+		case ta.After(tb):
+			c = 1
+		}
+		return
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case isInt(va) && isInt(vb):
+		c = compareOrdered(va.Int() < vb.Int(), va.Int() > vb.Int())
+	case isUint(va) && isUint(vb):
+		c = compareOrdered(va.Uint() < vb.Uint(), va.Uint() > vb.Uint())
+	case isNumber(va) && isNumber(vb):
+		fa, fb := toFloat(va), toFloat(vb)
+		c = compareOrdered(fa < fb, fa > fb)
+	case va.Kind() == reflect.String && vb.Kind() == reflect.String:
+		c = compareOrdered(va.String() < vb.String(), va.String() > vb.String())
+	case va.Kind() == reflect.Bool && vb.Kind() == reflect.Bool:
+		c = compareOrdered(!va.Bool() && vb.Bool(), va.Bool() && !vb.Bool())
+	default:
+		err = fmt.Errorf("unable to compare %#v with %#v", a, b)
+	}
+	return
+}
+
+func compareOrdered(less, greater bool) int {
+	if less {
+		return -1
+	} else if greater {
+		return 1
+	}
+	return 0
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isNumber(v reflect.Value) bool {
+	return isInt(v) || isUint(v) ||
+		v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case isInt(v):
+		return float64(v.Int())
+	case isUint(v):
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
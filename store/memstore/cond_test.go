@@ -0,0 +1,96 @@
+package memstore_test
+
+import (
+	"testing"
+
+	"github.com/gourd/kit/store"
+)
+
+func TestConds_operators(t *testing.T) {
+	s := testStoreData(t)
+
+	tests := []struct {
+		conds store.Conds
+		names []string
+	}{
+		{store.NewConds(), []string{"alice", "bob", "carol", "dave"}},
+		{store.NewConds().Add("age", 20), []string{"bob", "dave"}},
+		{store.NewConds().Add("age =", 20), []string{"bob", "dave"}},
+		{store.NewConds().Add("age !=", 20), []string{"alice", "carol"}},
+		{store.NewConds().Add("age >", 20), []string{"alice", "carol"}},
+		{store.NewConds().Add("age >=", 30), []string{"alice", "carol"}},
+		{store.NewConds().Add("age <", 30), []string{"bob", "dave"}},
+		{store.NewConds().Add("age <=", 30), []string{"alice", "bob", "dave"}},
+		{store.NewConds().Add("Name", "bob"), []string{"bob"}},
+		{store.NewConds().Add("age", 20).Add("name !=", "bob"), []string{"dave"}},
+		{store.NewConds().Add("age", 40).Add("name", "bob").SetRel(store.Or), []string{"bob", "carol"}},
+		{store.NewConds().AddOp("age", store.Ne, 20), []string{"alice", "carol"}},
+		{store.NewConds().AddOp("age", store.Gt, 30), []string{"carol"}},
+		{store.NewConds().AddOp("age", store.Lte, 20), []string{"bob", "dave"}},
+		{store.NewConds().AddOp("name", store.In, []string{"bob", "carol", "eve"}), []string{"bob", "carol"}},
+		{store.NewConds().AddOp("name", store.NotIn, []string{"bob", "carol"}), []string{"alice", "dave"}},
+		{store.NewConds().Add("age IN", []int{30, 40}), []string{"alice", "carol"}},
+		{store.NewConds().AddOp("name", store.Like, "%a%e"), []string{"alice", "dave"}},
+		{store.NewConds().AddOp("name", store.Like, "_ob"), []string{"bob"}},
+		{store.NewConds().AddOp("name", store.Prefix, "ca"), []string{"carol"}},
+		{store.NewConds().AddOp("age", store.Between, []int{25, 40}), []string{"alice", "carol"}},
+		{store.NewConds().AddOp("name", store.IsNull, nil), nil},
+		{store.NewConds().AddOp("name", store.NotNull, nil), []string{"alice", "bob", "carol", "dave"}},
+	}
+
+	for i, test := range tests {
+		var list []testEntity
+		if err := s.Search(store.NewQuery().SetConds(test.conds).Sort("name")).All(&list); err != nil {
+			t.Errorf("test %d: unexpected error: %#v", i, err.Error())
+			continue
+		}
+		if want, have := test.names, testNames(list); !testNamesEqual(want, have) {
+			t.Errorf("test %d: expected %#v, got %#v", i, want, have)
+		}
+	}
+}
+
+func TestConds_branching(t *testing.T) {
+	s := testStoreData(t)
+
+	cond1 := store.NewConds().
+		Add("age", 20).
+		Add("name !=", "bob")
+	cond2 := store.NewConds().
+		Add("age >", 35)
+
+	q := store.NewQuery().
+		AddCond("", cond1).
+		AddCond("", cond2).
+		Sort("name")
+	q.GetConds().SetRel(store.Or)
+
+	var list []testEntity
+	if err := s.Search(q).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := []string{"carol", "dave"}, testNames(list); !testNamesEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestConds_errors(t *testing.T) {
+	s := testStoreData(t)
+
+	tests := []store.Conds{
+		store.NewConds().Add("nothing", 1),
+		store.NewConds().Add("age ~", 1),
+		store.NewConds().Add("age >", "string"),
+		store.NewConds().Add("", "raw sql"),
+		store.NewConds().AddOp("age", store.In, 20),
+		store.NewConds().AddOp("age", store.Between, []int{1}),
+		store.NewConds().AddOp("age", store.Like, "2%"),
+		store.NewConds().AddOp("age >", store.Lt, 20),
+	}
+
+	for i, conds := range tests {
+		if err := s.Search(store.NewQuery().SetConds(conds)).All(&[]testEntity{}); err == nil {
+			t.Errorf("test %d: expected error, got nil", i)
+		}
+	}
+}
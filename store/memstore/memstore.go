@@ -0,0 +1,79 @@
+package memstore
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/gourd/kit/store"
+)
+
+// Database is an in-memory collection of entity collections.
+// It is safe for concurrent use by multiple goroutines.
+type Database struct {
+	mux   sync.RWMutex
+	colls map[string][]reflect.Value
+}
+
+// NewDatabase creates an empty Database
+func NewDatabase() *Database {
+	return &Database{
+		colls: make(map[string][]reflect.Value),
+	}
+}
+
+// Conn implements store.Conn
+type Conn struct {
+	db *Database
+}
+
+// Raw implements store.Conn.Raw()
+func (conn *Conn) Raw() interface{} {
+	return conn.db
+}
+
+// Close implements store.Conn.Close()
+func (conn *Conn) Close() {
+	// nothing to disconnect from
+}
+
+// Source is the in-memory implementation of store.Source.
+// All connections opened from the same Source share one Database
+type Source struct {
+	db *Database
+}
+
+// Open implements store.Source
+func (src *Source) Open() (s store.Conn, err error) {
+	s = &Conn{db: src.db}
+	return
+}
+
+// NewSource create store.Source with a new empty Database
+func NewSource() store.Source {
+	return &Source{
+		db: NewDatabase(),
+	}
+}
+
+// Provider returns a store.Provider which provides Store of the
+// given collection name. The entity is any value (or pointer to value)
+// of the struct type to be stored in the collection
+func Provider(coll string, entity interface{}) store.Provider {
+	typ := reflect.TypeOf(entity)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return func(sess interface{}) (s store.Store, err error) {
+		db, ok := sess.(*Database)
+		if !ok {
+			err = errorf("expected *memstore.Database in sess, got %#v", sess)
+			return
+		}
+		s = &Store{
+			db:   db,
+			coll: coll,
+			typ:  typ,
+		}
+		return
+	}
+}
@@ -0,0 +1,87 @@
+package memstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+	"golang.org/x/net/context"
+)
+
+// testEntity is the dummy entity for testing
+type testEntity struct {
+	ID      string    `db:"id,omitempty"`
+	Name    string    `db:"name"`
+	Age     int       `db:"age"`
+	Created time.Time `db:"created"`
+}
+
+func TestSource(t *testing.T) {
+	var src store.Source = memstore.NewSource()
+	conn1, err := src.Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	defer conn1.Close()
+
+	conn2, err := src.Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	defer conn2.Close()
+
+	if _, ok := conn1.Raw().(*memstore.Database); !ok {
+		t.Errorf("expected *memstore.Database, got %#v", conn1.Raw())
+	}
+	if conn1.Raw() != conn2.Raw() {
+		t.Errorf("connections of the same source should share Database")
+	}
+}
+
+func TestProvider_factory(t *testing.T) {
+
+	type tempKey int
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	factory := store.NewFactory()
+	factory.SetSource(srcKey, memstore.NewSource())
+	factory.Set(key, srcKey, memstore.Provider("entity", &testEntity{}))
+
+	// create entity in one request context
+	ctx1 := store.WithFactory(context.Background(), factory)
+	s1, err := store.Get(ctx1, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	e := &testEntity{Name: "hello"}
+	if err := s1.Create(nil, e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	store.CloseAllIn(ctx1)
+
+	// retrieve the entity in another request context
+	ctx2 := store.WithFactory(context.Background(), factory)
+	defer store.CloseAllIn(ctx2)
+	s2, err := store.Get(ctx2, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	found := &testEntity{}
+	if err := s2.One(store.NewConds().Add("id", e.ID), found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "hello", found.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestProvider_wrongSess(t *testing.T) {
+	provider := memstore.Provider("entity", testEntity{})
+	if _, err := provider("not a database"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,214 @@
+package memstore
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
+)
+
+// Result implements store.Result
+type Result struct {
+	ctx   context.Context
+	store *Store
+	query store.Query
+
+	// iteration states of Next
+	iter []reflect.Value
+	err  error
+	done bool
+}
+
+// filter returns copies of all entities that match the query
+// conditions (and cursor, if any), without sorting and paging
+func (res *Result) filter() (list []reflect.Value, err error) {
+	s := res.store
+
+	conds, err := store.SearchConds(res.query, s.AllocEntity())
+	if err != nil {
+		return
+	}
+
+	s.db.mux.RLock()
+	defer s.db.mux.RUnlock()
+	for _, item := range s.db.colls[s.coll] {
+		if err = res.ctx.Err(); err != nil {
+			return
+		}
+
+		var ok bool
+		if ok, err = match(item, conds); err != nil {
+			err = errorf("error searching %s: %s", s.coll, err)
+			return
+		} else if ok {
+			list = append(list, copyValue(item))
+		}
+	}
+	return
+}
+
+// matches returns copies of all entities that match the query
+// conditions (and cursor, if any), sorted by the query sorts,
+// without paging
+func (res *Result) matches() (list []reflect.Value, err error) {
+	if list, err = res.filter(); err != nil {
+		return
+	}
+	err = sortValues(res.store.typ, list, store.QuerySorts(res.query))
+	return
+}
+
+// page returns the matched entities within the query limit and offset
+func (res *Result) page() (list []reflect.Value, err error) {
+	if list, err = res.matches(); err != nil {
+		return
+	}
+
+	offset, limit := res.query.GetOffset(), res.query.GetLimit()
+	if offset >= uint64(len(list)) {
+		return nil, nil
+	}
+	list = list[offset:]
+	if limit != 0 && limit < uint64(len(list)) {
+		list = list[:limit]
+	}
+
+	// restore query sorting of entities before cursor
+	if c := res.query.GetCursor(); c != nil && c.Before {
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+
+	// leave only the selected fields
+	if fields := res.query.GetFields(); len(fields) > 0 {
+		for i := range list {
+			if list[i], err = project(list[i], fields); err != nil {
+				err = errorf("error searching %s: %s", res.store.coll, err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// project returns a copy of the struct value with only the
+// given fields. Other fields are left as zero value
+func project(val reflect.Value, fields []string) (cp reflect.Value, err error) {
+	cp = reflect.New(val.Type()).Elem()
+	for _, field := range fields {
+		idx, ok := fieldIndex(val.Type(), field)
+		if !ok {
+			err = fmt.Errorf("property %#v not found in %s", field, val.Type())
+			return
+		}
+		cp.FieldByIndex(idx).Set(val.FieldByIndex(idx))
+	}
+	return
+}
+
+// All fetches all results within the result set and dumps them into the
+// given pointer to slice of structs (or pointers to struct)
+func (res *Result) All(el interface{}) (err error) {
+	ptr := reflect.ValueOf(el)
+	if !ptr.IsValid() || ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice {
+		err = errorf("expected pointer to slice, got %#v", el)
+		return
+	}
+
+	slice := ptr.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if (isPtr && elemType.Elem() != res.store.typ) || (!isPtr && elemType != res.store.typ) {
+		err = errorf("expected slice of %s, got %s", res.store.typ, slice.Type())
+		return
+	}
+
+	list, err := res.page()
+	if err != nil {
+		return
+	}
+
+	out := reflect.MakeSlice(slice.Type(), 0, len(list))
+	for _, item := range list {
+		if isPtr {
+			out = reflect.Append(out, item.Addr())
+		} else {
+			out = reflect.Append(out, item)
+		}
+	}
+	slice.Set(out)
+	return
+}
+
+// Raw returns a pointer to slice of the matched entities
+func (res *Result) Raw() (interface{}, error) {
+	el := res.store.AllocEntityList()
+	if err := res.All(el); err != nil {
+		return nil, err
+	}
+	return el, nil
+}
+
+// Count returns the number of entities that match the query
+// conditions (and cursor, if any), regardless of limit and offset
+func (res *Result) Count() (count uint64, err error) {
+	list, err := res.matches()
+	count = uint64(len(list))
+	return
+}
+
+// Aggregate groups the entities matching the query conditions
+// and computes the aggregates in process (see store.AggregateEntities)
+func (res *Result) Aggregate() (rows []store.AggregateRow, err error) {
+	list, err := res.filter()
+	if err != nil {
+		return
+	}
+	el := reflect.New(reflect.SliceOf(res.store.typ))
+	el.Elem().Set(reflect.Append(el.Elem(), list...))
+	return store.AggregateEntities(res.query, el.Interface())
+}
+
+// Next fetches the next entity of the result page into the
+// given entity pointer. Returns false if there is no more
+// entity or there is error
+func (res *Result) Next(ep store.EntityPtr) bool {
+	if res.done {
+		return false
+	}
+
+	val, err := res.store.entityValue(ep)
+	if err != nil {
+		res.err, res.done = err, true
+		return false
+	}
+
+	// the page is matched on first call
+	if res.iter == nil {
+		if res.iter, res.err = res.page(); res.err != nil {
+			res.done = true
+			return false
+		}
+	}
+
+	if len(res.iter) == 0 {
+		res.done = true
+		return false
+	}
+	val.Set(res.iter[0])
+	res.iter = res.iter[1:]
+	return true
+}
+
+// Err returns the error, if any, encountered in Next
+func (res *Result) Err() error {
+	return res.err
+}
+
+// Close releases the entities pending for iteration
+func (res *Result) Close() error {
+	res.iter, res.done = nil, true
+	return nil
+}
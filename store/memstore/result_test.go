@@ -0,0 +1,312 @@
+package memstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+)
+
+func testStoreData(t *testing.T) store.Store {
+	s := testStore(t)
+	now := time.Now()
+	data := []testEntity{
+		{Name: "alice", Age: 30, Created: now.Add(-3 * time.Hour)},
+		{Name: "bob", Age: 20, Created: now.Add(-2 * time.Hour)},
+		{Name: "carol", Age: 40, Created: now.Add(-1 * time.Hour)},
+		{Name: "dave", Age: 20, Created: now},
+	}
+	for i := range data {
+		if err := s.Create(nil, &data[i]); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+	}
+	return s
+}
+
+func testNames(list []testEntity) (names []string) {
+	for _, e := range list {
+		names = append(names, e.Name)
+	}
+	return
+}
+
+func testNamesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestResult_sort(t *testing.T) {
+	s := testStoreData(t)
+
+	var list []testEntity
+	if err := s.Search(store.NewQuery().Sort("age").Sort("-name")).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := []string{"dave", "bob", "alice", "carol"}, testNames(list); !testNamesEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if err := s.Search(store.NewQuery().Sort("-created")).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := []string{"dave", "carol", "bob", "alice"}, testNames(list); !testNamesEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if err := s.Search(store.NewQuery().Sort("nothing")).All(&list); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestResult_paging(t *testing.T) {
+	s := testStoreData(t)
+
+	q := store.NewQuery().Sort("name").SetLimit(2).SetOffset(1)
+	res := s.Search(q)
+
+	var list []testEntity
+	if err := res.All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := []string{"bob", "carol"}, testNames(list); !testNamesEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// count ignores paging
+	if count, err := res.Count(); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	} else if want, have := uint64(4), count; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// offset out of range
+	if err := s.Search(q.SetOffset(10)).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 0, len(list); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestResult_AllPtr(t *testing.T) {
+	s := testStoreData(t)
+
+	var list []*testEntity
+	if err := s.Search(store.NewQuery().AddCond("name", "carol")).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 1, len(list); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 40, list[0].Age; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if err := s.Search(store.NewQuery()).All(&[]string{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestResult_Raw(t *testing.T) {
+	s := testStoreData(t)
+	raw, err := s.Search(store.NewQuery()).Raw()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if list, ok := raw.(*[]testEntity); !ok {
+		t.Errorf("expected *[]testEntity, got %#v", raw)
+	} else if want, have := 4, len(*list); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestResult_cursor(t *testing.T) {
+	s := testStoreData(t)
+
+	// sorted by age, name: bob, dave, alice, carol
+	q := store.NewQuery().Sort("age").Sort("name").SetLimit(2)
+
+	var list []testEntity
+	if err := s.Search(q).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := []string{"bob", "dave"}, testNames(list); !testNamesEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// next page, after dave
+	_, next := store.Cursors(q, &list)
+	c, err := store.ParseCursor(next)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	q.SetCursor(c)
+	if err := s.Search(q).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := []string{"alice", "carol"}, testNames(list); !testNamesEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// previous page, before alice
+	prev, _ := store.Cursors(q, &list)
+	if c, err = store.ParseCursor(prev); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	q.SetCursor(c).SetLimit(1)
+	if err := s.Search(q).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := []string{"dave"}, testNames(list); !testNamesEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// cursor of different sorting
+	q = store.NewQuery().Sort("name").SetCursor(c)
+	if err := s.Search(q).All(&list); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestResult_cursorNull(t *testing.T) {
+	type nullEntity struct {
+		ID   string  `db:"id,omitempty"`
+		Name string  `db:"name"`
+		Nick *string `db:"nick"`
+	}
+	conn, err := memstore.NewSource().Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	s, err := memstore.Provider("entity", &nullEntity{})(conn.Raw())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	nick := func(str string) *string { return &str }
+	for _, e := range []nullEntity{
+		{Name: "alice", Nick: nick("al")},
+		{Name: "bob"},
+		{Name: "carol", Nick: nick("cc")},
+		{Name: "dave"},
+	} {
+		if err := s.Create(nil, &e); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+	}
+
+	// NULL first: bob, dave, alice, carol
+	q := store.NewQuery().Sort("nick").Sort("name").SetLimit(1)
+	var names []string
+	for i := 0; i < 5; i++ {
+		var list []nullEntity
+		if err := s.Search(q).All(&list); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if len(list) == 0 {
+			break
+		}
+		names = append(names, list[0].Name)
+		_, next := store.Cursors(q, &list)
+		c, err := store.ParseCursor(next)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		q.SetCursor(c)
+	}
+	if want, have := []string{"bob", "dave", "alice", "carol"}, names; !testNamesEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestResult_Next(t *testing.T) {
+	s := testStoreData(t)
+
+	res := s.Search(store.NewQuery().Sort("name").SetLimit(3))
+	defer res.Close()
+
+	var names []string
+	for e := s.AllocEntity(); res.Next(e); {
+		names = append(names, e.(*testEntity).Name)
+	}
+	if err := res.Err(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := []string{"alice", "bob", "carol"}, names; !testNamesEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if res.Next(s.AllocEntity()) {
+		t.Errorf("expected no more entity")
+	}
+
+	// incorrect entity type
+	res = s.Search(store.NewQuery())
+	if res.Next(&struct{}{}) {
+		t.Errorf("expected false, got true")
+	}
+	if err := res.Err(); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestResult_Select(t *testing.T) {
+	s := testStoreData(t)
+
+	var list []testEntity
+	q := store.NewQuery().Sort("name").Select("id", "name")
+	if err := s.Search(q).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := []string{"alice", "bob", "carol", "dave"}, testNames(list); !testNamesEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	for _, e := range list {
+		if e.ID == "" {
+			t.Errorf("expected id to be selected")
+		}
+		if want, have := 0, e.Age; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+		if !e.Created.IsZero() {
+			t.Errorf("expected created to be zero, got %#v", e.Created)
+		}
+	}
+
+	if err := s.Search(q.Select("nothing")).All(&list); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestResult_Aggregate(t *testing.T) {
+	s := testStoreData(t)
+
+	q := store.NewQuery().AddCondOp("age", store.Lt, 40).
+		GroupBy("age").Aggregate(store.AggCount, "").Sort("-age")
+	rows, err := s.Search(q).Aggregate()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(rows); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 30, rows[0]["age"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := int64(1), rows[0]["count"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 20, rows[1]["age"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := int64(2), rows[1]["count"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
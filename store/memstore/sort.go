@@ -0,0 +1,80 @@
+package memstore
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/gourd/kit/store"
+)
+
+// sorter implements sort.Interface to sort a list of
+// struct values by the given store.Sort
+type sorter struct {
+	list  []reflect.Value
+	sorts []*store.Sort
+	idxs  [][]int
+}
+
+// Len implements sort.Interface
+func (s *sorter) Len() int {
+	return len(s.list)
+}
+
+// Less implements sort.Interface
+func (s *sorter) Less(i, j int) bool {
+	for n, srt := range s.sorts {
+		a := indirect(s.list[i].FieldByIndex(s.idxs[n]).Interface())
+		b := indirect(s.list[j].FieldByIndex(s.idxs[n]).Interface())
+
+		// nil is the least value, as NULL in SQLite and MySQL
+		var c int
+		var err error
+		switch {
+		case a == nil && b == nil:
+			continue
+		case a == nil:
+			c = -1
+		case b == nil:
+			c = 1
+		default:
+			c, err = compare(a, b)
+		}
+		if err != nil || c == 0 {
+			continue // treat incomparable values as equal
+		}
+		if srt.Order == store.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+// Swap implements sort.Interface
+func (s *sorter) Swap(i, j int) {
+	s.list[i], s.list[j] = s.list[j], s.list[i]
+}
+
+// sortValues stable sorts the list of struct values of the
+// given type by the sorts
+func sortValues(typ reflect.Type, list []reflect.Value, sorts []*store.Sort) (err error) {
+	if len(sorts) == 0 {
+		return
+	}
+
+	s := &sorter{
+		list:  list,
+		sorts: sorts,
+		idxs:  make([][]int, 0, len(sorts)),
+	}
+	for _, srt := range s.sorts {
+		idx, ok := fieldIndex(typ, srt.Name)
+		if !ok {
+			err = errorf("sort property %#v not found in %s", srt.Name, typ)
+			return
+		}
+		s.idxs = append(s.idxs, idx)
+	}
+	sort.Stable(s)
+	return
+}
@@ -0,0 +1,404 @@
+package memstore
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"reflect"
+
+	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
+)
+
+// Store implements store.Store on a collection of a Database
+type Store struct {
+	db   *Database
+	coll string
+	typ  reflect.Type
+}
+
+// Create appends a copy of the entity to the collection.
+// If the entity has an empty string id field, a random id
+// would be generated and assigned to it
+func (s *Store) Create(cond store.Conds, ep store.EntityPtr) (err error) {
+
+	val, err := s.entityValue(ep)
+	if err != nil {
+		return
+	}
+
+	// apply random id string to empty string id
+	if idx, ok := fieldIndex(s.typ, "id"); ok {
+		if id := val.FieldByIndex(idx); id.Kind() == reflect.String && id.String() == "" {
+			id.SetString(newID())
+		}
+	}
+
+	s.db.mux.Lock()
+	defer s.db.mux.Unlock()
+	s.db.colls[s.coll] = append(s.db.colls[s.coll], copyValue(val))
+	return
+}
+
+// Search entities by the given query
+func (s *Store) Search(q store.Query) store.Result {
+	return &Result{
+		ctx:   context.Background(),
+		store: s,
+		query: q,
+	}
+}
+
+// One returns the first entity matches condition(s).
+// Soft deleted entities are excluded
+func (s *Store) One(c store.Conds, ep store.EntityPtr) (err error) {
+
+	val, err := s.entityValue(ep)
+	if err != nil {
+		return
+	}
+	c = store.NotDeleted(c, ep)
+
+	s.db.mux.RLock()
+	defer s.db.mux.RUnlock()
+
+	for _, item := range s.db.colls[s.coll] {
+		var ok bool
+		if ok, err = match(item, c); err != nil {
+			err = errorf("error searching %s: %s", s.coll, err)
+			return
+		} else if ok {
+			val.Set(item)
+			return
+		}
+	}
+
+	err = store.ErrorNotFound
+	return
+}
+
+// Update replaces all entities matches condition(s)
+// with a copy of the given entity.
+//
+// If the entity has version field (see store.VersionProp), the
+// conditions should match at most one entity, or 400 StoreError
+// is returned (see store.VersionedMany). The matched entity should
+// be of the version of the given entity, or 409 StoreError is
+// returned without any update. Otherwise the version of the given
+// entity is incremented before the update
+func (s *Store) Update(c store.Conds, ep store.EntityPtr) (err error) {
+
+	val, err := s.entityValue(ep)
+	if err != nil {
+		return
+	}
+
+	s.db.mux.Lock()
+	defer s.db.mux.Unlock()
+
+	list := s.db.colls[s.coll]
+	matched := make([]int, 0, 1)
+	for i, item := range list {
+		var ok bool
+		if ok, err = match(item, c); err != nil {
+			err = errorf("error updating %s: %s", s.coll, err)
+			return
+		} else if ok {
+			matched = append(matched, i)
+		}
+	}
+
+	// check and increment version, if any
+	if _, ok := store.VersionProp(ep); ok && len(matched) > 1 {
+		return store.VersionedMany(int64(len(matched)))
+	}
+	if expected, ok := store.Version(ep); ok && len(matched) > 0 {
+		for _, i := range matched {
+			if stored, _ := store.Version(list[i].Interface()); stored != expected {
+				return store.VersionConflict(expected, stored)
+			}
+		}
+		store.SetVersion(ep, expected+1)
+	}
+
+	for _, i := range matched {
+		list[i] = copyValue(val)
+	}
+	return
+}
+
+// Delete removes all entities matches condition(s). If the
+// entity has soft delete field (see store.SoftDeleteProp),
+// the entities are soft deleted instead
+func (s *Store) Delete(c store.Conds) (err error) {
+
+	entity := s.AllocEntity()
+	if fields, ok := store.SoftDeleteFields(entity); ok {
+		return s.UpdateMany(store.NotDeleted(c, entity), fields)
+	}
+
+	s.db.mux.Lock()
+	defer s.db.mux.Unlock()
+
+	list := s.db.colls[s.coll]
+	kept := make([]reflect.Value, 0, len(list))
+	for _, item := range list {
+		var ok bool
+		if ok, err = match(item, c); err != nil {
+			err = errorf("error deleting %s: %s", s.coll, err)
+			return
+		} else if !ok {
+			kept = append(kept, item)
+		}
+	}
+	s.db.colls[s.coll] = kept
+	return
+}
+
+// CreateMany implements store.BulkStore. It appends copies of
+// all entities in the list to the collection at once
+func (s *Store) CreateMany(c store.Conds, el store.EntityListPtr) (err error) {
+	list := reflect.ValueOf(el)
+	if list.Kind() != reflect.Ptr || list.IsNil() || list.Elem().Type() != reflect.SliceOf(s.typ) {
+		err = errorf("expected *[]%s, got %#v", s.typ, el)
+		return
+	}
+	list = list.Elem()
+
+	// apply random id string to empty string id
+	if idx, ok := fieldIndex(s.typ, "id"); ok {
+		for i := 0; i < list.Len(); i++ {
+			if id := list.Index(i).FieldByIndex(idx); id.Kind() == reflect.String && id.String() == "" {
+				id.SetString(newID())
+			}
+		}
+	}
+
+	s.db.mux.Lock()
+	defer s.db.mux.Unlock()
+	for i := 0; i < list.Len(); i++ {
+		s.db.colls[s.coll] = append(s.db.colls[s.coll], copyValue(list.Index(i)))
+	}
+	return
+}
+
+// UpdateMany implements store.BulkStore. It sets the fields of all
+// entities matching the conditions, and increments their versions if
+// the entity has version field (see store.VersionProp). Returns 400
+// StoreError if any of the fields is not found or of incompatible type
+func (s *Store) UpdateMany(c store.Conds, fields map[string]interface{}) (err error) {
+
+	// resolve the fields before touching any entity
+	idxs := make(map[string][]int, len(fields))
+	for name, v := range fields {
+		idx, ok := fieldIndex(s.typ, name)
+		if !ok {
+			return store.Error(http.StatusBadRequest, "unknown field %#v", name)
+		}
+		if v != nil {
+			ftyp := s.typ.FieldByIndex(idx).Type
+			if ftyp.Kind() == reflect.Ptr && reflect.TypeOf(v) != ftyp {
+				ftyp = ftyp.Elem() // value of pointer field
+			}
+			if !reflect.TypeOf(v).ConvertibleTo(ftyp) ||
+				(ftyp.Kind() == reflect.String && reflect.TypeOf(v).Kind() != reflect.String) {
+				return store.Error(http.StatusBadRequest, "invalid value %#v of field %#v", v, name)
+			}
+		}
+		idxs[name] = idx
+	}
+
+	// increment the version, if any and not set by the fields
+	var bump bool
+	if prop, ok := store.VersionProp(s.AllocEntity()); ok {
+		vidx, _ := fieldIndex(s.typ, prop)
+		bump = true
+		for _, idx := range idxs {
+			if reflect.DeepEqual(idx, vidx) {
+				bump = false
+			}
+		}
+	}
+
+	s.db.mux.Lock()
+	defer s.db.mux.Unlock()
+
+	list := s.db.colls[s.coll]
+	for i, item := range list {
+		var ok bool
+		if ok, err = match(item, c); err != nil {
+			err = errorf("error updating %s: %s", s.coll, err)
+			return
+		} else if !ok {
+			continue
+		}
+
+		cp := copyValue(item)
+		for name, v := range fields {
+			field := cp.FieldByIndex(idxs[name])
+			switch {
+			case v == nil:
+				field.Set(reflect.Zero(field.Type()))
+			case field.Kind() == reflect.Ptr && reflect.TypeOf(v) != field.Type():
+				ptr := reflect.New(field.Type().Elem())
+				ptr.Elem().Set(reflect.ValueOf(v).Convert(field.Type().Elem()))
+				field.Set(ptr)
+			default:
+				field.Set(reflect.ValueOf(v).Convert(field.Type()))
+			}
+		}
+		if bump {
+			version, _ := store.Version(cp.Interface())
+			store.SetVersion(cp.Addr().Interface(), version+1)
+		}
+		list[i] = cp
+	}
+	return
+}
+
+// Upsert implements store.UpsertStore. It replaces the first entity
+// matching the conditions with a copy of the entity, or appends the
+// copy if none matches. Soft deleted entities are excluded. The id of
+// the entity replaced is kept, and set to the entity.
+//
+// If the entity has version field (see store.VersionProp), the entity
+// replaced should be of the version of the given entity, or 409
+// StoreError is returned. The version is incremented on replace
+func (s *Store) Upsert(c store.Conds, ep store.EntityPtr) (err error) {
+
+	val, err := s.entityValue(ep)
+	if err != nil {
+		return
+	}
+	idx, hasID := fieldIndex(s.typ, "id")
+	c = store.NotDeleted(c, ep)
+
+	s.db.mux.Lock()
+	defer s.db.mux.Unlock()
+
+	list := s.db.colls[s.coll]
+	for i, item := range list {
+		var ok bool
+		if ok, err = match(item, c); err != nil {
+			err = errorf("error upserting %s: %s", s.coll, err)
+			return
+		} else if !ok {
+			continue
+		}
+		if expected, ok := store.Version(ep); ok {
+			if stored, _ := store.Version(item.Interface()); stored != expected {
+				return store.VersionConflict(expected, stored)
+			}
+			store.SetVersion(ep, expected+1)
+		}
+		if hasID {
+			val.FieldByIndex(idx).Set(item.FieldByIndex(idx))
+		}
+		list[i] = copyValue(val)
+		return
+	}
+
+	// apply random id string to empty string id
+	if hasID {
+		if id := val.FieldByIndex(idx); id.Kind() == reflect.String && id.String() == "" {
+			id.SetString(newID())
+		}
+	}
+	s.db.colls[s.coll] = append(list, copyValue(val))
+	return
+}
+
+// CreateContext implements store.ContextStore
+func (s *Store) CreateContext(ctx context.Context, c store.Conds, ep store.EntityPtr) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	return s.Create(c, ep)
+}
+
+// SearchContext implements store.ContextStore
+func (s *Store) SearchContext(ctx context.Context, q store.Query) store.Result {
+	return &Result{
+		ctx:   ctx,
+		store: s,
+		query: q,
+	}
+}
+
+// OneContext implements store.ContextStore
+func (s *Store) OneContext(ctx context.Context, c store.Conds, ep store.EntityPtr) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	return s.One(c, ep)
+}
+
+// UpdateContext implements store.ContextStore
+func (s *Store) UpdateContext(ctx context.Context, c store.Conds, ep store.EntityPtr) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	return s.Update(c, ep)
+}
+
+// DeleteContext implements store.ContextStore
+func (s *Store) DeleteContext(ctx context.Context, c store.Conds) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	return s.Delete(c)
+}
+
+// AllocEntity allocate memory for an entity
+func (s *Store) AllocEntity() store.EntityPtr {
+	return reflect.New(s.typ).Interface()
+}
+
+// AllocEntityList allocate memory for an entity list
+func (s *Store) AllocEntityList() store.EntityListPtr {
+	return reflect.New(reflect.SliceOf(s.typ)).Interface()
+}
+
+// Len inspect the length of an entity list
+func (s *Store) Len(pl store.EntityListPtr) int64 {
+	return int64(reflect.ValueOf(pl).Elem().Len())
+}
+
+// Close would not do anything. Please use store.CloseAllIn(ctx)
+// to wrap up connections in a context
+func (s *Store) Close() error {
+	return nil
+}
+
+// entityValue returns the struct value the given entity pointer points to
+func (s *Store) entityValue(ep store.EntityPtr) (val reflect.Value, err error) {
+	ptr := reflect.ValueOf(ep)
+	if !ptr.IsValid() || ptr.Kind() != reflect.Ptr || ptr.IsNil() ||
+		ptr.Elem().Type() != s.typ {
+		err = errorf("expected *%s, got %#v", s.typ, ep)
+		return
+	}
+	val = ptr.Elem()
+	return
+}
+
+// copyValue returns a copy of the given struct value
+func copyValue(val reflect.Value) reflect.Value {
+	cp := reflect.New(val.Type()).Elem()
+	cp.Set(val)
+	return cp
+}
+
+// newID generates a random url-safe string id
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// errorf returns an internal server error with the server message
+func errorf(msg string, v ...interface{}) error {
+	return store.Error(http.StatusInternalServerError,
+		http.StatusText(http.StatusInternalServerError)).
+		TellServer(msg, v...)
+}
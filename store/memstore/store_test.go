@@ -0,0 +1,442 @@
+package memstore_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+	"github.com/gourd/kit/store/storetest"
+	"golang.org/x/net/context"
+)
+
+func testStore(t *testing.T) store.Store {
+	conn, err := memstore.NewSource().Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	s, err := memstore.Provider("entity", &testEntity{})(conn.Raw())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	var s store.Store = &memstore.Store{}
+	_ = s
+}
+
+func TestStore_Create(t *testing.T) {
+	s := testStore(t)
+
+	e1 := &testEntity{Name: "foo"}
+	if err := s.Create(nil, e1); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if e1.ID == "" {
+		t.Errorf("id is not generated")
+	}
+
+	e2 := &testEntity{ID: "custom", Name: "bar"}
+	if err := s.Create(nil, e2); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "custom", e2.ID; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// changing the entity should not change the stored copy
+	e1.Name = "changed"
+	found := &testEntity{}
+	if err := s.One(store.NewConds().Add("id", e1.ID), found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "foo", found.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// wrong entity type
+	if err := s.Create(nil, &struct{ Name string }{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestStore_One_notFound(t *testing.T) {
+	s := testStore(t)
+	err := s.One(store.NewConds().Add("id", "nothing"), &testEntity{})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if want, have := http.StatusNotFound, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestStore_UpdateDelete(t *testing.T) {
+	s := testStore(t)
+	for i := 0; i < 3; i++ {
+		if err := s.Create(nil, &testEntity{Name: fmt.Sprintf("entity %d", i), Age: i}); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+	}
+
+	// update
+	found := &testEntity{}
+	s.One(store.NewConds().Add("age", 1), found)
+	found.Name = "updated"
+	if err := s.Update(store.NewConds().Add("id", found.ID), found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	updated := &testEntity{}
+	if err := s.One(store.NewConds().Add("id", found.ID), updated); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "updated", updated.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// delete
+	if err := s.Delete(store.NewConds().Add("id", found.ID)); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.One(store.NewConds().Add("id", found.ID), &testEntity{}); err == nil {
+		t.Errorf("expected error after delete, got nil")
+	}
+	if count, err := s.Search(store.NewQuery()).Count(); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	} else if want, have := uint64(2), count; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestStore_Alloc(t *testing.T) {
+	s := testStore(t)
+	if _, ok := s.AllocEntity().(*testEntity); !ok {
+		t.Errorf("expected *testEntity, got %#v", s.AllocEntity())
+	}
+	el := s.AllocEntityList()
+	if _, ok := el.(*[]testEntity); !ok {
+		t.Errorf("expected *[]testEntity, got %#v", el)
+	}
+	if want, have := int64(0), s.Len(el); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestStore_concurrent(t *testing.T) {
+	s := testStore(t)
+	n := 50
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			s.Create(nil, &testEntity{Age: i})
+			s.Search(store.NewQuery().AddCond("age >=", 0)).All(&[]testEntity{})
+		}(i)
+	}
+	wg.Wait()
+
+	if count, err := s.Search(store.NewQuery()).Count(); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	} else if want, have := uint64(n), count; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestStore_context(t *testing.T) {
+	var cs store.ContextStore = testStore(t).(*memstore.Store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &testEntity{Name: "foo"}
+	if err := cs.CreateContext(ctx, nil, e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := cs.OneContext(ctx, store.NewConds().Add("id", e.ID), &testEntity{}); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	}
+
+	cancel()
+	if err := cs.CreateContext(ctx, nil, &testEntity{}); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %#v", err)
+	}
+	if err := cs.SearchContext(ctx, store.NewQuery()).All(&[]testEntity{}); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %#v", err)
+	}
+	if err := cs.UpdateContext(ctx, nil, e); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %#v", err)
+	}
+	if err := cs.DeleteContext(ctx, nil); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %#v", err)
+	}
+}
+
+func TestStore_CreateMany(t *testing.T) {
+	s := testStore(t).(*memstore.Store)
+
+	el := &[]testEntity{{Name: "foo"}, {ID: "custom", Name: "bar"}}
+	if err := s.CreateMany(nil, el); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if (*el)[0].ID == "" {
+		t.Errorf("id is not generated")
+	}
+	if want, have := "custom", (*el)[1].ID; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	found := &[]testEntity{}
+	if err := s.Search(store.NewQuery()).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(*found); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// wrong list type
+	if err := s.CreateMany(nil, &[]struct{}{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestStore_UpdateMany(t *testing.T) {
+	s := testStore(t).(*memstore.Store)
+
+	el := &[]testEntity{{Name: "foo", Age: 1}, {Name: "bar", Age: 1}, {Name: "baz", Age: 2}}
+	if err := s.CreateMany(nil, el); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	err := s.UpdateMany(store.NewConds().Add("age", 1),
+		map[string]interface{}{"name": "updated", "age": 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	found := &[]testEntity{}
+	q := store.NewQuery().SetConds(store.NewConds().Add("age", 3))
+	if err := s.Search(q).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(*found); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	for _, e := range *found {
+		if want, have := "updated", e.Name; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+
+	// unknown field
+	err = s.UpdateMany(nil, map[string]interface{}{"unknown": 1})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if want, have := http.StatusBadRequest, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestStore_Upsert(t *testing.T) {
+	s := testStore(t).(*memstore.Store)
+	cond := store.NewConds().Add("name", "foo")
+
+	e1 := &testEntity{Name: "foo", Age: 1}
+	if err := s.Upsert(cond, e1); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if e1.ID == "" {
+		t.Errorf("id is not generated")
+	}
+
+	e2 := &testEntity{Name: "foo", Age: 2}
+	if err := s.Upsert(cond, e2); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := e1.ID, e2.ID; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	found := &[]testEntity{}
+	if err := s.Search(store.NewQuery()).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 1, len(*found); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 2, (*found)[0].Age; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestStore_Upsert_matches(t *testing.T) {
+	type softEntity struct {
+		ID        string     `db:"id,omitempty"`
+		Name      string     `db:"name"`
+		Age       int        `db:"age"`
+		DeletedAt *time.Time `db:"deleted_at" gourddelete:"soft"`
+	}
+	conn, err := memstore.NewSource().Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	s, err := memstore.Provider("entity", &softEntity{})(conn.Raw())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if err := s.Create(nil, &softEntity{ID: id, Name: "foo"}); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+	}
+	if err := s.Delete(store.NewConds().Add("id", "1")); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// only the first entity not deleted is replaced, keeping its id
+	e := &softEntity{ID: "other", Name: "foo", Age: 2}
+	if err := s.(store.UpsertStore).Upsert(store.NewConds().Add("name", "foo"), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "2", e.ID; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	found := &[]softEntity{}
+	q := store.NewQuery().SetIncludeDeleted(true).Sort("id")
+	if err := s.Search(q).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 3, len(*found); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	for i, want := range []int{0, 2, 0} {
+		if want, have := fmt.Sprintf("%d", i+1), (*found)[i].ID; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+		if have := (*found)[i].Age; want != have {
+			t.Errorf("entity %d: expected %#v, got %#v", i, want, have)
+		}
+	}
+}
+
+// entityStore returns a Store of storetest.Entity
+func entityStore(t *testing.T) store.Store {
+	conn, err := memstore.NewSource().Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	s, err := memstore.Provider("entity", &storetest.Entity{})(conn.Raw())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	return s
+}
+
+func TestStore_CreateID(t *testing.T) {
+	storetest.CreateID(t, entityStore)
+}
+
+func TestStore_UpdateVersion(t *testing.T) {
+	storetest.UpdateVersion(t, entityStore)
+}
+
+func TestStore_Update_version(t *testing.T) {
+	type versionEntity struct {
+		ID      string `db:"id,omitempty"`
+		Name    string `db:"name"`
+		Version int64  `db:"version" gourdupdate:"version"`
+	}
+	conn, err := memstore.NewSource().Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	s, err := memstore.Provider("entity", &versionEntity{})(conn.Raw())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Create(nil, &versionEntity{ID: "1", Name: "foo"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	c := store.NewConds().Add("id", "1")
+
+	// both based on version 0, only the first succeeds
+	e1 := &versionEntity{ID: "1", Name: "bar"}
+	if err := s.Update(c, e1); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := int64(1), e1.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	err = s.Update(c, &versionEntity{ID: "1", Name: "baz"})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if want, have := http.StatusConflict, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	found := &versionEntity{}
+	if err := s.One(c, found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "bar", found.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := int64(1), found.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestStore_UpdateMany_version(t *testing.T) {
+	type versionEntity struct {
+		ID      string `db:"id,omitempty"`
+		Name    string `db:"name"`
+		Version int64  `db:"version" gourdupdate:"version"`
+	}
+	conn, err := memstore.NewSource().Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	s, err := memstore.Provider("entity", &versionEntity{})(conn.Raw())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Create(nil, &versionEntity{ID: "1", Name: "foo", Version: 3}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	c := store.NewConds().Add("id", "1")
+
+	if err := store.UpdateMany(s, c, map[string]interface{}{"name": "bar"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	found := &versionEntity{}
+	if err := s.One(c, found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := int64(4), found.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// the former version no longer passes the check
+	err = s.Update(c, &versionEntity{ID: "1", Name: "baz", Version: 3})
+	if want, have := http.StatusConflict, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// version set explicitly is kept
+	if err := store.UpdateMany(s, c, map[string]interface{}{"version": int64(10)}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.One(c, found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := int64(10), found.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
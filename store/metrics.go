@@ -0,0 +1,270 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"golang.org/x/net/context"
+)
+
+// Metrics contains go-kit metrics to instrument stores and sources
+// with. Metrics could be enabled for all stores obtained through Get
+// by MetricsFactory.SetMetrics. Nil metrics are not recorded.
+//
+// Store metrics are recorded with field "store" of the store key and
+// field "op" of the operation ("create", "one", "update", "delete",
+// "create_many", "update_many", "upsert", "all", "count" or
+// "aggregate"). Operations with context (see ContextStore) are
+// recorded as those without. Source metrics are recorded with
+// field "source" of the source key
+type Metrics struct {
+
+	// Requests counts the store operations
+	Requests metrics.Counter
+
+	// Errors counts the failed store operations, with
+	// additional field "status" of the StoreError status
+	Errors metrics.Counter
+
+	// Latency observes the duration of store
+	// operations, in microseconds
+	Latency metrics.Histogram
+
+	// Opens counts the Source.Open calls, with additional
+	// field "result" of "success" or "error"
+	Opens metrics.Counter
+
+	// Closes counts the Conn.Close calls
+	Closes metrics.Counter
+}
+
+// Store returns the Store wrapped to record metrics of the
+// operations with the store key
+func (m *Metrics) Store(key interface{}, s Store) Store {
+	return &metricsStore{Store: s, metrics: m, key: fmt.Sprintf("%v", key)}
+}
+
+// Source returns the Source wrapped to record metrics of
+// the connections opened with the source key. The Source
+// returned implements ContextSource
+func (m *Metrics) Source(srcKey interface{}, src Source) Source {
+	return &metricsSource{src: src, metrics: m, key: fmt.Sprintf("%v", srcKey)}
+}
+
+// record records the metrics of a store operation
+// of the store key since the begin time
+func (m *Metrics) record(key, op string, begin time.Time, err error) {
+	storeField := metrics.Field{Key: "store", Value: key}
+	opField := metrics.Field{Key: "op", Value: op}
+	if m.Requests != nil {
+		m.Requests.With(storeField).With(opField).Add(1)
+	}
+	if m.Latency != nil {
+		m.Latency.With(storeField).With(opField).
+			Observe(time.Since(begin).Nanoseconds() / int64(time.Microsecond))
+	}
+	if err != nil && m.Errors != nil {
+		status := strconv.Itoa(ExpandError(err).Status)
+		m.Errors.With(storeField).With(opField).
+			With(metrics.Field{Key: "status", Value: status}).Add(1)
+	}
+}
+
+// metricsSource implements ContextSource with Open counted
+type metricsSource struct {
+	src     Source
+	metrics *Metrics
+	key     string
+}
+
+// Open implements Source
+func (src *metricsSource) Open() (Conn, error) {
+	return src.OpenContext(context.Background())
+}
+
+// OpenContext implements ContextSource
+func (src *metricsSource) OpenContext(ctx context.Context) (conn Conn, err error) {
+	conn, err = openContext(ctx, src.src)
+	if m := src.metrics; m.Opens != nil {
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		m.Opens.With(metrics.Field{Key: "source", Value: src.key}).
+			With(metrics.Field{Key: "result", Value: result}).Add(1)
+	}
+	if err != nil {
+		return
+	}
+	if txConn, ok := conn.(TxConn); ok {
+		return &metricsTxConn{txConn, src.metrics, src.key}, nil
+	}
+	return &metricsConn{conn, src.metrics, src.key}, nil
+}
+
+// metricsConn implements Conn with Close counted
+type metricsConn struct {
+	Conn
+	metrics *Metrics
+	key     string
+}
+
+// Close implements Conn
+func (conn *metricsConn) Close() {
+	conn.Conn.Close()
+	if conn.metrics.Closes != nil {
+		conn.metrics.Closes.With(metrics.Field{Key: "source", Value: conn.key}).Add(1)
+	}
+}
+
+// metricsTxConn implements TxConn with Close counted
+type metricsTxConn struct {
+	TxConn
+	metrics *Metrics
+	key     string
+}
+
+// Close implements Conn
+func (conn *metricsTxConn) Close() {
+	conn.TxConn.Close()
+	if conn.metrics.Closes != nil {
+		conn.metrics.Closes.With(metrics.Field{Key: "source", Value: conn.key}).Add(1)
+	}
+}
+
+// metricsStore implements Store with metrics recorded.
+//
+// It implements BulkStore, UpsertStore and ContextStore with the
+// native operations of the wrapped Store, if any
+type metricsStore struct {
+	Store
+	metrics *Metrics
+	key     string
+}
+
+// do runs the operation with its metrics recorded
+func (s *metricsStore) do(op string, fn func() error) (err error) {
+	begin := time.Now()
+	err = fn()
+	s.metrics.record(s.key, op, begin, err)
+	return
+}
+
+// Create implements Store
+func (s *metricsStore) Create(c Conds, ep EntityPtr) error {
+	return s.do("create", func() error {
+		return s.Store.Create(c, ep)
+	})
+}
+
+// Search implements Store
+func (s *metricsStore) Search(q Query) Result {
+	return &metricsResult{Result: s.Store.Search(q), store: s}
+}
+
+// One implements Store
+func (s *metricsStore) One(c Conds, ep EntityPtr) error {
+	return s.do("one", func() error {
+		return s.Store.One(c, ep)
+	})
+}
+
+// Update implements Store
+func (s *metricsStore) Update(c Conds, ep EntityPtr) error {
+	return s.do("update", func() error {
+		return s.Store.Update(c, ep)
+	})
+}
+
+// Delete implements Store
+func (s *metricsStore) Delete(c Conds) error {
+	return s.do("delete", func() error {
+		return s.Store.Delete(c)
+	})
+}
+
+// CreateMany implements BulkStore
+func (s *metricsStore) CreateMany(c Conds, el EntityListPtr) error {
+	return s.do("create_many", func() error {
+		return CreateMany(s.Store, c, el)
+	})
+}
+
+// UpdateMany implements BulkStore
+func (s *metricsStore) UpdateMany(c Conds, fields map[string]interface{}) error {
+	return s.do("update_many", func() error {
+		return UpdateMany(s.Store, c, fields)
+	})
+}
+
+// Upsert implements UpsertStore
+func (s *metricsStore) Upsert(c Conds, ep EntityPtr) error {
+	return s.do("upsert", func() error {
+		return Upsert(s.Store, c, ep)
+	})
+}
+
+// CreateContext implements ContextStore
+func (s *metricsStore) CreateContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	return s.do("create", func() error {
+		return NewContextStore(s.Store).CreateContext(ctx, c, ep)
+	})
+}
+
+// SearchContext implements ContextStore
+func (s *metricsStore) SearchContext(ctx context.Context, q Query) Result {
+	return &metricsResult{Result: NewContextStore(s.Store).SearchContext(ctx, q), store: s}
+}
+
+// OneContext implements ContextStore
+func (s *metricsStore) OneContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	return s.do("one", func() error {
+		return NewContextStore(s.Store).OneContext(ctx, c, ep)
+	})
+}
+
+// UpdateContext implements ContextStore
+func (s *metricsStore) UpdateContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	return s.do("update", func() error {
+		return NewContextStore(s.Store).UpdateContext(ctx, c, ep)
+	})
+}
+
+// DeleteContext implements ContextStore
+func (s *metricsStore) DeleteContext(ctx context.Context, c Conds) error {
+	return s.do("delete", func() error {
+		return NewContextStore(s.Store).DeleteContext(ctx, c)
+	})
+}
+
+// metricsResult implements Result with metrics recorded
+type metricsResult struct {
+	Result
+	store *metricsStore
+}
+
+// All implements Result
+func (res *metricsResult) All(el interface{}) (err error) {
+	begin := time.Now()
+	err = res.Result.All(el)
+	res.store.metrics.record(res.store.key, "all", begin, err)
+	return
+}
+
+// Count implements Result
+func (res *metricsResult) Count() (count uint64, err error) {
+	begin := time.Now()
+	count, err = res.Result.Count()
+	res.store.metrics.record(res.store.key, "count", begin, err)
+	return
+}
+
+// Aggregate implements Result
+func (res *metricsResult) Aggregate() (rows []AggregateRow, err error) {
+	begin := time.Now()
+	rows, err = res.Result.Aggregate()
+	res.store.metrics.record(res.store.key, "aggregate", begin, err)
+	return
+}
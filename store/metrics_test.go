@@ -0,0 +1,126 @@
+package store_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
+)
+
+// tMetric implements metrics.Counter and metrics.Histogram,
+// recording the fields of each observation in a log
+type tMetric struct {
+	fields []string
+	log    *[]string
+}
+
+func newMetric() *tMetric {
+	return &tMetric{log: &[]string{}}
+}
+
+func (m *tMetric) Name() string {
+	return "test"
+}
+
+func (m *tMetric) with(f metrics.Field) *tMetric {
+	fields := append(append([]string{}, m.fields...), f.Key+"="+f.Value)
+	return &tMetric{fields: fields, log: m.log}
+}
+
+func (m *tMetric) record() {
+	*m.log = append(*m.log, strings.Join(m.fields, ","))
+}
+
+func (m *tMetric) count(fields string) (n int) {
+	for _, entry := range *m.log {
+		if entry == fields {
+			n++
+		}
+	}
+	return
+}
+
+type tCounter struct{ *tMetric }
+
+func (c tCounter) With(f metrics.Field) metrics.Counter { return tCounter{c.with(f)} }
+func (c tCounter) Add(delta uint64)                     { c.record() }
+
+type tHistogram struct{ *tMetric }
+
+func (h tHistogram) With(f metrics.Field) metrics.Histogram { return tHistogram{h.with(f)} }
+func (h tHistogram) Observe(value int64)                    { h.record() }
+
+func TestMetrics(t *testing.T) {
+	requests, errors, latency := newMetric(), newMetric(), newMetric()
+	opens, closes := newMetric(), newMetric()
+
+	factory := store.NewFactory()
+	factory.(store.MetricsFactory).SetMetrics(&store.Metrics{
+		Requests: tCounter{requests},
+		Errors:   tCounter{errors},
+		Latency:  tHistogram{latency},
+		Opens:    tCounter{opens},
+		Closes:   tCounter{closes},
+	})
+	ctx := hookContext(factory)
+
+	s, err := store.Get(ctx, hookKey(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Create(nil, &bulkEntity{ID: "1"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.One(store.NewConds().Add("id", "2"), &bulkEntity{}); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if err := s.Search(store.NewQuery()).All(&[]bulkEntity{}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := store.CreateMany(s, nil, &[]bulkEntity{{ID: "2"}}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := store.UpdateMany(s, store.NewConds().Add("id", "2"),
+		map[string]interface{}{"name": "foo"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := store.Upsert(s, store.NewConds().Add("id", "3"), &bulkEntity{}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.(store.ContextStore).DeleteContext(context.Background(),
+		store.NewConds().Add("id", "3")); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	store.CloseAllIn(ctx)
+
+	for _, fields := range []string{
+		"store=0,op=create",
+		"store=0,op=one",
+		"store=0,op=all",
+		"store=0,op=create_many",
+		"store=0,op=update_many",
+		"store=0,op=upsert",
+		"store=0,op=delete",
+	} {
+		if want, have := 1, requests.count(fields); want != have {
+			t.Errorf("requests %s: expected %#v, got %#v", fields, want, have)
+		}
+		if want, have := 1, latency.count(fields); want != have {
+			t.Errorf("latency %s: expected %#v, got %#v", fields, want, have)
+		}
+	}
+
+	sort.Strings(*errors.log)
+	if want, have := "store=0,op=one,status=404", strings.Join(*errors.log, ";"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "source=1,result=success", strings.Join(*opens.log, ";"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "source=1", strings.Join(*closes.log, ";"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
@@ -40,3 +40,50 @@ func Middleware(factory Factory) endpoint.Middleware {
 	}
 	return mware
 }
+
+// Transactional wraps an endpoint in a transaction of the
+// stores in context. It commits if the inner endpoint returns
+// nil error, or rollback otherwise. If the inner endpoint panics,
+// the transaction is rolled back before the panic goes on.
+//
+// The context should already have a factory (e.g. with
+// RequestFuncWithFactory)
+func Transactional(inner endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		if err = Begin(ctx); err != nil {
+			return
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				Rollback(ctx)
+				panic(r)
+			}
+		}()
+
+		response, err = inner(ctx, request)
+		if err != nil {
+			Rollback(ctx)
+			return
+		}
+
+		if err = Commit(ctx); err != nil {
+			response = nil
+		}
+		return
+	}
+}
+
+// TxMiddleware takes a Factory and create a middleware that
+// does WithFactory, Transactional and CloseAllIn
+func TxMiddleware(factory Factory) endpoint.Middleware {
+	mware := func(inner endpoint.Endpoint) endpoint.Endpoint {
+		inner = Transactional(inner)
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			ctx = WithFactory(ctx, factory)
+			response, err = inner(ctx, request)
+			CloseAllIn(ctx)
+			return
+		}
+	}
+	return mware
+}
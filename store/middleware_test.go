@@ -97,3 +97,77 @@ func TestMiddleware(t *testing.T) {
 	}
 
 }
+
+func TestTxMiddleware(t *testing.T) {
+
+	type tempKey int
+
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	tests := []struct {
+		err error
+		log []string
+	}{
+		{nil, []string{"begin", "commit", "close"}},
+		{fmt.Errorf("some error"), []string{"begin", "rollback", "close"}},
+	}
+
+	for _, test := range tests {
+		var log []string
+		mware := store.TxMiddleware(testTxFactory(srcKey, key, &log, true))
+		ep := mware(func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			if _, err = store.Get(ctx, key); err != nil {
+				t.Errorf("unexpected error: %#v", err.Error())
+			}
+			return request, test.err
+		})
+
+		if _, err := ep(context.Background(), "hello"); err != test.err {
+			t.Errorf("expected %#v, got %#v", test.err, err)
+		}
+		if want, have := test.log, log; !testLogEqual(want, have) {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+}
+
+func TestTransactional_panic(t *testing.T) {
+
+	type tempKey int
+
+	const (
+		srcKey tempKey = iota
+		key
+	)
+
+	var log []string
+	ctx := store.WithFactory(context.Background(),
+		testTxFactory(srcKey, key, &log, true))
+	ep := store.Transactional(func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		if _, err = store.Get(ctx, key); err != nil {
+			t.Errorf("unexpected error: %#v", err.Error())
+		}
+		panic("some panic")
+	})
+
+	func() {
+		defer func() {
+			if want, have := "some panic", recover(); want != have {
+				t.Errorf("expected %#v, got %#v", want, have)
+			}
+		}()
+		ep(ctx, "hello")
+	}()
+	if want, have := []string{"begin", "rollback"}, log; !testLogEqual(want, have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// the transaction is ended
+	if err := store.Begin(ctx); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	}
+	store.Rollback(ctx)
+}
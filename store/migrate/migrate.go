@@ -0,0 +1,135 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Func runs a migration step in the transaction
+type Func func(tx *sql.Tx) error
+
+// Migration is a versioned change of database schema
+type Migration struct {
+
+	// Version orders the migrations. Migrations are applied
+	// in ascending order of version and reverted in descending
+	// order. Each version should be unique and non-zero
+	Version uint64
+
+	// Name describes the migration
+	Name string
+
+	// Up applies the migration
+	Up Func
+
+	// Down reverts the migration. Nil Down means
+	// the migration is irreversible
+	Down Func
+}
+
+// String implements fmt.Stringer
+func (m Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+// SQL returns a Migration applied and reverted by the SQL statements.
+// Empty down means the migration is irreversible.
+//
+// Statements are executed at once, so the driver should support
+// multiple statements in Exec if there is more than one
+func SQL(version uint64, name, up, down string) Migration {
+	m := Migration{
+		Version: version,
+		Name:    name,
+		Up:      execFunc(up),
+	}
+	if strings.TrimSpace(down) != "" {
+		m.Down = execFunc(down)
+	}
+	return m
+}
+
+// execFunc returns Func which executes the SQL statements
+func execFunc(query string) Func {
+	return func(tx *sql.Tx) (err error) {
+		_, err = tx.Exec(query)
+		return
+	}
+}
+
+// Load reads SQL migrations (see SQL) from files in the directory
+// named "<version>_<name>.up.sql" and "<version>_<name>.down.sql".
+// Files of other names are ignored. The down file is optional
+func Load(dir string) (migrations []Migration, err error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		err = fmt.Errorf("error reading migrations in %#v: %s", dir, err)
+		return
+	}
+
+	ups := make(map[uint64]string)
+	downs := make(map[uint64]string)
+	names := make(map[uint64]string)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		var base string
+		var target map[uint64]string
+		switch fn := file.Name(); {
+		case strings.HasSuffix(fn, ".up.sql"):
+			base, target = strings.TrimSuffix(fn, ".up.sql"), ups
+		case strings.HasSuffix(fn, ".down.sql"):
+			base, target = strings.TrimSuffix(fn, ".down.sql"), downs
+		default:
+			continue
+		}
+
+		parts := strings.SplitN(base, "_", 2)
+		version, perr := strconv.ParseUint(parts[0], 10, 64)
+		if perr != nil {
+			continue
+		}
+		name := ""
+		if len(parts) > 1 {
+			name = parts[1]
+		}
+		if prev, ok := names[version]; ok && prev != name {
+			err = fmt.Errorf("conflicting names of migration %d: %#v and %#v",
+				version, prev, name)
+			return
+		}
+		names[version] = name
+
+		b, rerr := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if rerr != nil {
+			err = fmt.Errorf("error reading migration %#v: %s", file.Name(), rerr)
+			return
+		}
+		target[version] = string(b)
+	}
+
+	for version, name := range names {
+		up, ok := ups[version]
+		if !ok {
+			err = fmt.Errorf("missing up file of migration %d_%s", version, name)
+			return
+		}
+		migrations = append(migrations, SQL(version, name, up, downs[version]))
+	}
+	sort.Sort(byVersion(migrations))
+	return
+}
+
+// byVersion sorts migrations in ascending order of version
+type byVersion []Migration
+
+func (ms byVersion) Len() int           { return len(ms) }
+func (ms byVersion) Less(i, j int) bool { return ms[i].Version < ms[j].Version }
+func (ms byVersion) Swap(i, j int)      { ms[i], ms[j] = ms[j], ms[i] }
@@ -0,0 +1,78 @@
+package migrate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gourd/kit/store/migrate"
+)
+
+func TestSQL(t *testing.T) {
+	m := migrate.SQL(1, "create_foo", "CREATE TABLE foo (id TEXT)", "")
+	if m.Up == nil {
+		t.Errorf("expected Up")
+	}
+	if m.Down != nil {
+		t.Errorf("expected no Down")
+	}
+	if want, have := "1_create_foo", m.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	for fn, content := range map[string]string{
+		"2_create_bar.up.sql":   "CREATE TABLE bar (id TEXT)",
+		"1_create_foo.up.sql":   "CREATE TABLE foo (id TEXT)",
+		"1_create_foo.down.sql": "DROP TABLE foo",
+		"README.md":             "not a migration",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, fn), []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+	}
+
+	ms, err := migrate.Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(ms); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "1_create_foo", ms[0].String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if ms[0].Down == nil {
+		t.Errorf("expected Down of %s", ms[0])
+	}
+	if want, have := "2_create_bar", ms[1].String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if ms[1].Down != nil {
+		t.Errorf("expected no Down of %s", ms[1])
+	}
+}
+
+func TestLoad_missingUp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "1_foo.down.sql"),
+		[]byte("DROP TABLE foo"), 0644); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if _, err := migrate.Load(dir); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,410 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gourd/kit/store"
+	"upper.io/db.v1"
+)
+
+// DefaultTable is the default name of the table of applied migrations
+const DefaultTable = "schema_migrations"
+
+// ErrLocked is returned if the migrations are
+// locked by another run longer than the wait
+var ErrLocked = errors.New("migrations are locked by another run")
+
+// Migrator runs migrations against the SQL database of an upperio
+// store.Source. Applied versions are recorded in a table, and runs
+// are serialized by a lock table, so multiple processes could run
+// the same migrations at once.
+//
+// Each migration is applied or reverted in its own transaction
+// together with the record of its version. Some databases (e.g.
+// MySQL) commit schema changes implicitly, so a failed migration
+// might need cleanup by hand
+type Migrator struct {
+
+	// Table is the name of the table of applied migrations.
+	// Empty means DefaultTable. The lock table is named
+	// after it with suffix "_lock"
+	Table string
+
+	// LockWait is the duration to wait for the lock held by
+	// another run before failing with ErrLocked. Zero means
+	// failing at once
+	LockWait time.Duration
+
+	// LockExpires is the duration after which a lock is
+	// considered stale (e.g. of a crashed run) and is taken
+	// over. Zero means locks never expire
+	LockExpires time.Duration
+
+	mux        sync.Mutex
+	src        store.Source
+	migrations []Migration
+}
+
+// New returns a Migrator of the migrations against the source,
+// which should be an upperio source of a SQL database
+func New(src store.Source, migrations ...Migration) *Migrator {
+	return &Migrator{
+		src:        src,
+		migrations: migrations,
+	}
+}
+
+// Register adds migrations to the Migrator
+func (m *Migrator) Register(migrations ...Migration) *Migrator {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.migrations = append(m.migrations, migrations...)
+	return m
+}
+
+// Up applies all pending migrations in order of version.
+// Returns migrations applied, even if failed in the middle
+func (m *Migrator) Up() ([]Migration, error) {
+	return m.UpTo(^uint64(0))
+}
+
+// UpTo applies pending migrations of version up to the given
+// version, in order of version. Returns migrations applied,
+// even if failed in the middle
+func (m *Migrator) UpTo(version uint64) (done []Migration, err error) {
+	err = m.run(func(r *runner) error {
+		for _, mg := range r.migrations {
+			if mg.Version > version || r.applied[mg.Version] {
+				continue
+			}
+			if err := r.apply(mg); err != nil {
+				return err
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return
+}
+
+// Down reverts the latest applied migration, if any
+func (m *Migrator) Down() (done []Migration, err error) {
+	err = m.run(func(r *runner) error {
+		latest := r.latest()
+		if latest == 0 {
+			return nil
+		}
+		mg, err := r.find(latest)
+		if err != nil {
+			return err
+		}
+		if err = r.revert(mg); err != nil {
+			return err
+		}
+		done = append(done, mg)
+		return nil
+	})
+	return
+}
+
+// DownTo reverts applied migrations of version later than the given
+// version, in reverse order of version. DownTo(0) reverts all applied
+// migrations. Returns migrations reverted, even if failed in the middle
+func (m *Migrator) DownTo(version uint64) (done []Migration, err error) {
+	err = m.run(func(r *runner) error {
+		for latest := r.latest(); latest > version; latest = r.latest() {
+			mg, err := r.find(latest)
+			if err != nil {
+				return err
+			}
+			if err = r.revert(mg); err != nil {
+				return err
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return
+}
+
+// Version returns the latest applied version, or 0 if none
+func (m *Migrator) Version() (version uint64, err error) {
+	err = m.run(func(r *runner) error {
+		version = r.latest()
+		return nil
+	})
+	return
+}
+
+// Pending returns the migrations not yet applied, in order of version
+func (m *Migrator) Pending() (pending []Migration, err error) {
+	err = m.run(func(r *runner) error {
+		for _, mg := range r.migrations {
+			if !r.applied[mg.Version] {
+				pending = append(pending, mg)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// sorted returns the validated migrations in order of version
+func (m *Migrator) sorted() (migrations []Migration, err error) {
+	migrations = make([]Migration, len(m.migrations))
+	copy(migrations, m.migrations)
+	sort.Sort(byVersion(migrations))
+	for i, mg := range migrations {
+		switch {
+		case mg.Version == 0:
+			err = fmt.Errorf("migration %#v has no version", mg.Name)
+		case mg.Up == nil:
+			err = fmt.Errorf("migration %s has no Up", mg)
+		case i > 0 && migrations[i-1].Version == mg.Version:
+			err = fmt.Errorf("duplicated migrations of version %d: %s and %s",
+				mg.Version, migrations[i-1], mg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// run runs the operation with the lock held
+func (m *Migrator) run(op func(r *runner) error) (err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	migrations, err := m.sorted()
+	if err != nil {
+		return
+	}
+
+	conn, err := m.src.Open()
+	if err != nil {
+		return fmt.Errorf("error opening source: %s", err)
+	}
+	defer conn.Close()
+
+	sess, ok := conn.Raw().(db.Database)
+	if !ok {
+		return fmt.Errorf("unsupported connection %T, expecting upperio", conn.Raw())
+	}
+	drv, ok := sess.Driver().(*sql.DB)
+	if !ok {
+		return fmt.Errorf("unsupported driver %T, expecting *sql.DB", sess.Driver())
+	}
+
+	table := m.Table
+	if table == "" {
+		table = DefaultTable
+	}
+	r := &runner{
+		drv:        drv,
+		table:      table,
+		postgres:   isPostgres(drv),
+		migrations: migrations,
+	}
+	if err = r.init(); err != nil {
+		return
+	}
+	if err = r.lock(m.LockWait, m.LockExpires); err != nil {
+		return
+	}
+	defer func() {
+		if uerr := r.unlock(); err == nil {
+			err = uerr
+		}
+	}()
+	if err = r.load(); err != nil {
+		return
+	}
+	return op(r)
+}
+
+// isPostgres returns whether the driver is of PostgreSQL,
+// which has placeholders of different syntax
+func isPostgres(drv *sql.DB) bool {
+	name := strings.ToLower(fmt.Sprintf("%T", drv.Driver()))
+	return strings.Contains(name, "pq.") || strings.Contains(name, "postgres")
+}
+
+// runner runs migrations on a database with the lock held
+type runner struct {
+	drv        *sql.DB
+	table      string
+	postgres   bool
+	migrations []Migration
+	applied    map[uint64]bool
+}
+
+// query returns the query with placeholders "?"
+// replaced by those of the database
+func (r *runner) query(format string, v ...interface{}) string {
+	query := fmt.Sprintf(format, v...)
+	if !r.postgres {
+		return query
+	}
+	parts := strings.Split(query, "?")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = fmt.Sprintf("$%d", i) + parts[i]
+	}
+	return strings.Join(parts, "")
+}
+
+// init creates the table of applied migrations and the lock table
+func (r *runner) init() (err error) {
+	if _, err = r.drv.Exec(r.query(`CREATE TABLE IF NOT EXISTS %s (
+		version    BIGINT PRIMARY KEY,
+		name       VARCHAR(255),
+		applied_at BIGINT
+	)`, r.table)); err != nil {
+		return fmt.Errorf("error creating table %s: %s", r.table, err)
+	}
+	if _, err = r.drv.Exec(r.query(`CREATE TABLE IF NOT EXISTS %s_lock (
+		id        INTEGER PRIMARY KEY,
+		locked_at BIGINT
+	)`, r.table)); err != nil {
+		return fmt.Errorf("error creating table %s_lock: %s", r.table, err)
+	}
+	return
+}
+
+// lock inserts the only row of the lock table, waiting for
+// the row inserted by another run to be removed or expired
+func (r *runner) lock(wait, expires time.Duration) (err error) {
+	deadline := time.Now().Add(wait)
+	for {
+		now := time.Now()
+		if expires > 0 {
+			if _, err = r.drv.Exec(r.query("DELETE FROM %s_lock WHERE id = 1 AND locked_at < ?",
+				r.table), now.Add(-expires).UnixNano()); err != nil {
+				return fmt.Errorf("error removing expired lock: %s", err)
+			}
+		}
+		if _, err = r.drv.Exec(r.query("INSERT INTO %s_lock (id, locked_at) VALUES (1, ?)",
+			r.table), now.UnixNano()); err == nil {
+			return
+		}
+
+		// insert fails for reasons other than an existing lock
+		var n int
+		if cerr := r.drv.QueryRow(r.query("SELECT COUNT(*) FROM %s_lock",
+			r.table)).Scan(&n); cerr != nil || n == 0 {
+			return fmt.Errorf("error locking migrations: %s", err)
+		}
+
+		if !now.Before(deadline) {
+			return ErrLocked
+		}
+		pause := 100 * time.Millisecond
+		if left := deadline.Sub(now); left < pause {
+			pause = left
+		}
+		time.Sleep(pause)
+	}
+}
+
+// unlock removes the row of the lock table
+func (r *runner) unlock() (err error) {
+	if _, err = r.drv.Exec(r.query("DELETE FROM %s_lock WHERE id = 1", r.table)); err != nil {
+		err = fmt.Errorf("error unlocking migrations: %s", err)
+	}
+	return
+}
+
+// load reads the applied versions
+func (r *runner) load() (err error) {
+	rows, err := r.drv.Query(r.query("SELECT version FROM %s", r.table))
+	if err != nil {
+		return fmt.Errorf("error reading %s: %s", r.table, err)
+	}
+	defer rows.Close()
+
+	r.applied = make(map[uint64]bool)
+	for rows.Next() {
+		var version int64
+		if err = rows.Scan(&version); err != nil {
+			return fmt.Errorf("error reading %s: %s", r.table, err)
+		}
+		r.applied[uint64(version)] = true
+	}
+	return rows.Err()
+}
+
+// latest returns the latest applied version, or 0 if none
+func (r *runner) latest() (latest uint64) {
+	for version := range r.applied {
+		if version > latest {
+			latest = version
+		}
+	}
+	return
+}
+
+// find returns the migration of the applied version
+func (r *runner) find(version uint64) (mg Migration, err error) {
+	for _, mg = range r.migrations {
+		if mg.Version == version {
+			return
+		}
+	}
+	err = fmt.Errorf("unknown applied migration of version %d", version)
+	return
+}
+
+// apply applies the migration and records its version
+func (r *runner) apply(mg Migration) (err error) {
+	if err = r.transact(mg, "applying", func(tx *sql.Tx) (err error) {
+		if err = mg.Up(tx); err != nil {
+			return
+		}
+		_, err = tx.Exec(r.query("INSERT INTO %s (version, name, applied_at) VALUES (?, ?, ?)",
+			r.table), int64(mg.Version), mg.Name, time.Now().Unix())
+		return
+	}); err == nil {
+		r.applied[mg.Version] = true
+	}
+	return
+}
+
+// revert reverts the migration and removes the record of its version
+func (r *runner) revert(mg Migration) (err error) {
+	if mg.Down == nil {
+		return fmt.Errorf("migration %s is irreversible", mg)
+	}
+	if err = r.transact(mg, "reverting", func(tx *sql.Tx) (err error) {
+		if err = mg.Down(tx); err != nil {
+			return
+		}
+		_, err = tx.Exec(r.query("DELETE FROM %s WHERE version = ?",
+			r.table), int64(mg.Version))
+		return
+	}); err == nil {
+		delete(r.applied, mg.Version)
+	}
+	return
+}
+
+// transact runs the step of the migration in a transaction
+func (r *runner) transact(mg Migration, action string, step Func) (err error) {
+	tx, err := r.drv.Begin()
+	if err != nil {
+		return fmt.Errorf("error %s migration %s: %s", action, mg, err)
+	}
+	if err = step(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error %s migration %s: %s", action, mg, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error %s migration %s: %s", action, mg, err)
+	}
+	return
+}
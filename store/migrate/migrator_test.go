@@ -0,0 +1,205 @@
+package migrate_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/migrate"
+	"github.com/gourd/kit/store/upperio"
+	"upper.io/db.v1"
+	"upper.io/db/sqlite"
+)
+
+// testSource returns a source of sqlite database in the file
+func testSource(fn string) store.Source {
+	return upperio.NewSource(sqlite.Adapter, sqlite.ConnectionURL{
+		Database: fn,
+	})
+}
+
+// testMigrations returns migrations of 2 tables
+func testMigrations() []migrate.Migration {
+	return []migrate.Migration{
+		migrate.SQL(2, "create_bar", "CREATE TABLE bar (id TEXT)", "DROP TABLE bar"),
+		migrate.SQL(1, "create_foo", "CREATE TABLE foo (id TEXT)", "DROP TABLE foo"),
+	}
+}
+
+// tables returns the names of tables in the sqlite database
+func tables(t *testing.T, src store.Source) map[string]bool {
+	conn, err := src.Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	defer conn.Close()
+
+	drv := conn.Raw().(db.Database).Driver().(*sql.DB)
+	rows, err := drv.Query("SELECT name FROM sqlite_master WHERE type = 'table'")
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		names[name] = true
+	}
+	return names
+}
+
+func TestMigrator(t *testing.T) {
+	fn := "./test_migrator.tmp"
+	defer os.Remove(fn)
+	src := testSource(fn)
+	m := migrate.New(src, testMigrations()...)
+
+	done, err := m.Up()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(done); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := uint64(1), done[0].Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if names := tables(t, src); !names["foo"] || !names["bar"] {
+		t.Errorf("expected tables foo and bar, got %#v", names)
+	}
+
+	// nothing to apply again
+	if done, err = m.Up(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 0, len(done); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if version, err := m.Version(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	} else if want, have := uint64(2), version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// revert the latest
+	if done, err = m.Down(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "2_create_bar", done[0].String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if names := tables(t, src); !names["foo"] || names["bar"] {
+		t.Errorf("expected table foo only, got %#v", names)
+	}
+	if pending, err := m.Pending(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	} else if want, have := 1, len(pending); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// revert all
+	if done, err = m.DownTo(0); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 1, len(done); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if names := tables(t, src); names["foo"] || names["bar"] {
+		t.Errorf("expected no table foo or bar, got %#v", names)
+	}
+}
+
+func TestMigrator_UpTo(t *testing.T) {
+	fn := "./test_migrator_upto.tmp"
+	defer os.Remove(fn)
+	src := testSource(fn)
+	m := migrate.New(src).Register(testMigrations()...)
+
+	if _, err := m.UpTo(1); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if names := tables(t, src); !names["foo"] || names["bar"] {
+		t.Errorf("expected table foo only, got %#v", names)
+	}
+}
+
+func TestMigrator_failed(t *testing.T) {
+	fn := "./test_migrator_failed.tmp"
+	defer os.Remove(fn)
+	src := testSource(fn)
+	m := migrate.New(src, testMigrations()...).Register(
+		migrate.SQL(3, "bad", "CREATE TABLE foo (id TEXT)", ""))
+
+	done, err := m.Up()
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if want, have := 2, len(done); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if version, err := m.Version(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	} else if want, have := uint64(2), version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestMigrator_invalid(t *testing.T) {
+	fn := "./test_migrator_invalid.tmp"
+	defer os.Remove(fn)
+	m := migrate.New(testSource(fn), testMigrations()...).Register(
+		migrate.SQL(1, "duplicated", "CREATE TABLE baz (id TEXT)", ""))
+	if _, err := m.Up(); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestMigrator_irreversible(t *testing.T) {
+	fn := "./test_migrator_irreversible.tmp"
+	defer os.Remove(fn)
+	m := migrate.New(testSource(fn),
+		migrate.SQL(1, "create_foo", "CREATE TABLE foo (id TEXT)", ""))
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if _, err := m.Down(); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestMigrator_locked(t *testing.T) {
+	fn := "./test_migrator_locked.tmp"
+	defer os.Remove(fn)
+	src := testSource(fn)
+
+	// lock the migrations as if by another run
+	if _, err := migrate.New(src).Version(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	conn, err := src.Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	drv := conn.Raw().(db.Database).Driver().(*sql.DB)
+	if _, err := drv.Exec("INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)",
+		time.Now().UnixNano()); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	conn.Close()
+
+	m := migrate.New(src, testMigrations()...)
+	m.LockWait = 200 * time.Millisecond
+	if _, err := m.Up(); err != migrate.ErrLocked {
+		t.Errorf("expected ErrLocked, got %#v", err)
+	}
+
+	// expired lock is taken over
+	m.LockExpires = time.Millisecond
+	if _, err := m.Up(); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	}
+}
@@ -24,6 +24,14 @@ type Pager interface {
 
 	// GetLimit gets the limit and offset in the pager descriptor
 	GetLimit() (limit, offset int)
+
+	// SetCursors sets the tokens of previous and next
+	// page cursors in the pager descriptor
+	SetCursors(prev, next string) Pager
+
+	// GetCursors gets the tokens of previous and next
+	// page cursors in the pager descriptor
+	GetCursors() (prev, next string)
 }
 
 // NewPager creates a new pager descriptor
@@ -40,11 +48,13 @@ type pager struct {
 	total  int
 	offset int
 	limit  int
+	prev   string
+	next   string
 }
 
 // MarshalJSON implements json Marshaler interface
 func (p pager) MarshalJSON() ([]byte, error) {
-	vmap := make(map[string]int)
+	vmap := make(map[string]interface{})
 	if p.total > -1 {
 		vmap["total"] = p.total
 	}
@@ -54,26 +64,39 @@ func (p pager) MarshalJSON() ([]byte, error) {
 	if p.offset > -1 {
 		vmap["offset"] = p.offset
 	}
+	if p.prev != "" {
+		vmap["prev"] = p.prev
+	}
+	if p.next != "" {
+		vmap["next"] = p.next
+	}
 	return json.Marshal(vmap)
 }
 
 // UnmarshalJSON implements json Unarshaler interface
 func (p *pager) UnmarshalJSON(data []byte) (err error) {
-	vmap := make(map[string]int)
+	vmap := struct {
+		Total  *int   `json:"total"`
+		Limit  *int   `json:"limit"`
+		Offset *int   `json:"offset"`
+		Prev   string `json:"prev"`
+		Next   string `json:"next"`
+	}{}
 	err = json.Unmarshal(data, &vmap)
 	if err != nil {
 		return
 	}
 
-	if total, ok := vmap["total"]; ok {
-		p.total = total
+	if vmap.Total != nil {
+		p.total = *vmap.Total
 	}
-	if limit, ok := vmap["limit"]; ok {
-		p.limit = limit
+	if vmap.Limit != nil {
+		p.limit = *vmap.Limit
 	}
-	if offset, ok := vmap["offset"]; ok {
-		p.offset = offset
+	if vmap.Offset != nil {
+		p.offset = *vmap.Offset
 	}
+	p.prev, p.next = vmap.Prev, vmap.Next
 
 	return
 }
@@ -100,3 +123,15 @@ func (p *pager) SetTotal(total int) Pager {
 func (p pager) GetTotal() int {
 	return p.total
 }
+
+// SetCursors implements the Pager interface
+func (p *pager) SetCursors(prev, next string) Pager {
+	p.prev = prev
+	p.next = next
+	return p
+}
+
+// GetCursors implements the Pager interface
+func (p pager) GetCursors() (prev, next string) {
+	return p.prev, p.next
+}
@@ -80,3 +80,32 @@ func TestPager_Partial(t *testing.T) {
 	}
 
 }
+
+func TestPager_Cursors(t *testing.T) {
+
+	p1 := store.NewPager().SetCursors("abc", "def")
+	if err := testPagerHasOnly(p1, "prev", "next"); err != nil {
+		t.Error(err)
+	}
+
+	data, err := json.Marshal(p1)
+	if err != nil {
+		t.Fatalf("marshal error: %#v", err.Error())
+	}
+	p2 := store.NewPager()
+	if err = json.Unmarshal(data, &p2); err != nil {
+		t.Fatalf("unmarshal error: %#v", err.Error())
+	}
+	prev, next := p2.GetCursors()
+	if want, have := "abc", prev; want != have {
+		t.Errorf("prev want: %#v, got: %#v", want, have)
+	}
+	if want, have := "def", next; want != have {
+		t.Errorf("next want: %#v, got: %#v", want, have)
+	}
+
+	p3 := store.NewPager().SetCursors("", "def")
+	if err := testPagerHasOnly(p3, "next"); err != nil {
+		t.Error(err)
+	}
+}
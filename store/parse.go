@@ -0,0 +1,381 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryOptions configures how ParseQuery builds a Query
+type QueryOptions struct {
+
+	// Filters is the whitelist of properties that could be filtered.
+	// Filtering any other property would be a bad request
+	Filters []string
+
+	// Sorts is the whitelist of properties that could be sorted by.
+	// Sorting by any other property would be a bad request
+	Sorts []string
+
+	// Fields is the whitelist of properties that could be selected.
+	// Selecting any other property would be a bad request
+	Fields []string
+
+	// Aggregates is the whitelist of properties that could be
+	// grouped by or aggregated. Using any other property
+	// would be a bad request
+	Aggregates []string
+
+	// DefaultSorts are the sorts (e.g. "-created") to use if the
+	// query string has none. Not limited by the whitelist
+	DefaultSorts []string
+
+	// DefaultLimit is the limit to use if the query string has none
+	DefaultLimit uint64
+
+	// MaxLimit is the maximum limit allowed. 0 means no maximum
+	MaxLimit uint64
+
+	// Entity is an optional entity (or pointer to entity) of the
+	// store to query. If provided, filter values would be parsed
+	// into the type of their property (e.g. int, bool, time.Time
+	// in RFC3339). Otherwise values are kept as string
+	Entity interface{}
+}
+
+// filterOps maps the operators in filter syntax to Op
+var filterOps = map[string]Op{
+	"eq":      Eq,
+	"ne":      Ne,
+	"gt":      Gt,
+	"gte":     Gte,
+	"lt":      Lt,
+	"lte":     Lte,
+	"in":      In,
+	"nin":     NotIn,
+	"like":    Like,
+	"prefix":  Prefix,
+	"null":    IsNull,
+	"between": Between,
+}
+
+// filterKey matches "filter[prop]" and "filter[prop][op]"
+var filterKey = regexp.MustCompile(`^filter\[([^\[\]]+)\](?:\[([^\[\]]+)\])?$`)
+
+// ParseQuery builds a Query from the query string values:
+//
+//	filter[prop]=value          prop equals value
+//	filter[prop][op]=value      prop compares to value with op
+//	sort=-created,name          sort by created (desc), then name
+//	limit=20&offset=40          paging by limit and offset
+//	after=token / before=token  keyset paging by cursor (see Cursor)
+//	fields=id,name              select only the id and name fields
+//	group=status                group by status in aggregation
+//	aggregate=count,sum(amount) count entities and sum up amount
+//
+// Operators are eq, ne, gt, gte, lt, lte, like, prefix,
+// in and nin (comma separated values), between (2 comma
+// separated values) and null (true for IS NULL, false for
+// IS NOT NULL).
+//
+// Aggregate functions are count, sum, avg, min and max. Aggregation
+// results could also be sorted by the aggregate names
+// (e.g. sort=-sum_amount, see Aggregate.Name).
+//
+// Only properties in the whitelists of the options could be
+// filtered, sorted by, selected or aggregated. Returns 400 StoreError on invalid input
+func ParseQuery(v url.Values, opts *QueryOptions) (q Query, err error) {
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+	q = NewQuery()
+
+	// parse filters, in stable order of keys
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		if strings.HasPrefix(key, "filter") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, str := range v[key] {
+			if err = parseFilter(q, opts, key, str); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// parse aggregation
+	if str := v.Get("group"); str != "" {
+		groupBy := strings.Split(str, ",")
+		for _, prop := range groupBy {
+			if !inStrings(opts.Aggregates, prop) {
+				return nil, Error(http.StatusBadRequest,
+					"cannot group by %#v", prop)
+			}
+		}
+		q.GroupBy(groupBy...)
+	}
+	if str := v.Get("aggregate"); str != "" {
+		for _, aggStr := range strings.Split(str, ",") {
+			agg, err := parseAggregate(aggStr)
+			if err != nil {
+				return nil, err
+			}
+			if agg.Prop != "" && !inStrings(opts.Aggregates, agg.Prop) {
+				return nil, Error(http.StatusBadRequest,
+					"cannot aggregate %#v", agg.Prop)
+			}
+			q.Aggregate(agg.Func, agg.Prop)
+		}
+	}
+
+	// parse sorts
+	sorts := opts.DefaultSorts
+	if str := v.Get("sort"); str != "" {
+		sorts = strings.Split(str, ",")
+		for _, sstr := range sorts {
+			if sstr == "" || sstr == "-" {
+				return nil, Error(http.StatusBadRequest, "invalid sort").
+					TellServer("empty sort in %#v", str)
+			}
+			name := SortStr(sstr).Name
+			if !inStrings(opts.Sorts, name) && !inStrings(q.GetGroupBy(), name) &&
+				!aggNamed(q.GetAggregates(), name) {
+				return nil, Error(http.StatusBadRequest,
+					"cannot sort by %#v", name)
+			}
+		}
+	}
+	for _, sstr := range sorts {
+		q.Sort(sstr)
+	}
+
+	// parse field selection
+	if str := v.Get("fields"); str != "" {
+		fields := strings.Split(str, ",")
+		for _, field := range fields {
+			if !inStrings(opts.Fields, field) {
+				return nil, Error(http.StatusBadRequest,
+					"cannot select field %#v", field)
+			}
+		}
+		q.Select(fields...)
+	}
+
+	// parse paging
+	limit, offset := opts.DefaultLimit, uint64(0)
+	if limit, err = parseUint(v, "limit", limit); err != nil {
+		return nil, err
+	}
+	if offset, err = parseUint(v, "offset", offset); err != nil {
+		return nil, err
+	}
+	if opts.MaxLimit > 0 && (limit == 0 || limit > opts.MaxLimit) {
+		if v.Get("limit") != "" {
+			return nil, Error(http.StatusBadRequest,
+				"limit should not exceed %d", opts.MaxLimit)
+		}
+		limit = opts.MaxLimit
+	}
+	q.SetLimit(limit).SetOffset(offset)
+
+	// parse cursor
+	after, before := v.Get("after"), v.Get("before")
+	if after != "" && before != "" {
+		return nil, Error(http.StatusBadRequest,
+			"cannot page both after and before cursors")
+	}
+	if token := after + before; token != "" {
+		var c *Cursor
+		if c, err = ParseCursor(token); err != nil {
+			return nil, err
+		}
+		c.Before = before != ""
+		q.SetCursor(c)
+	}
+	return
+}
+
+// parseFilter parses a filter key and value into conditions of the query
+func parseFilter(q Query, opts *QueryOptions, key, str string) (err error) {
+	matches := filterKey.FindStringSubmatch(key)
+	if matches == nil {
+		return Error(http.StatusBadRequest, "invalid filter %#v", key)
+	}
+	name, opStr := matches[1], matches[2]
+	if !inStrings(opts.Filters, name) {
+		return Error(http.StatusBadRequest, "cannot filter by %#v", name)
+	}
+
+	op := Eq
+	if opStr != "" {
+		var ok bool
+		if op, ok = filterOps[opStr]; !ok {
+			return Error(http.StatusBadRequest,
+				"unknown operator %#v in filter %#v", opStr, key)
+		}
+	}
+
+	badValue := func(err error) error {
+		return Error(http.StatusBadRequest,
+			"invalid value %#v in filter %#v", str, key).
+			TellServer("%s", err)
+	}
+
+	switch op {
+	case IsNull:
+		isNull, err := strconv.ParseBool(str)
+		if err != nil {
+			return badValue(err)
+		}
+		if !isNull {
+			op = NotNull
+		}
+		q.AddCondOp(name, op, nil)
+	case In, NotIn, Between:
+		strs := strings.Split(str, ",")
+		if op == Between && len(strs) != 2 {
+			return badValue(fmt.Errorf("between expects 2 values, got %d", len(strs)))
+		}
+		values := make([]interface{}, 0, len(strs))
+		for _, s := range strs {
+			val, err := parseValue(opts.Entity, name, s)
+			if err != nil {
+				return badValue(err)
+			}
+			values = append(values, val)
+		}
+		q.AddCondOp(name, op, values)
+	case Like, Prefix:
+		q.AddCondOp(name, op, str)
+	default:
+		val, err := parseValue(opts.Entity, name, str)
+		if err != nil {
+			return badValue(err)
+		}
+		q.AddCondOp(name, op, val)
+	}
+	return
+}
+
+// aggregateExpr matches aggregate expression like "sum(amount)"
+var aggregateExpr = regexp.MustCompile(`^(\w+)(?:\(([^()]+)\))?$`)
+
+// parseAggregate parses aggregate expression like "count"
+// or "sum(amount)" into Aggregate
+func parseAggregate(str string) (agg Aggregate, err error) {
+	matches := aggregateExpr.FindStringSubmatch(str)
+	if matches == nil {
+		err = Error(http.StatusBadRequest, "invalid aggregate %#v", str)
+		return
+	}
+	for fn, fnStr := range aggFuncStrings {
+		if fnStr == matches[1] {
+			agg.Func, agg.Prop = fn, matches[2]
+			if agg.Prop == "" && fn != AggCount {
+				err = Error(http.StatusBadRequest,
+					"aggregate %#v requires a property", str)
+			}
+			return
+		}
+	}
+	err = Error(http.StatusBadRequest,
+		"unknown aggregate function %#v", matches[1])
+	return
+}
+
+// parseValue parses the string into the type of the
+// property of the entity, if entity is not nil
+func parseValue(entity interface{}, name, str string) (v interface{}, err error) {
+	if entity == nil {
+		return str, nil
+	}
+
+	typ := reflect.TypeOf(entity)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return str, nil
+	}
+	field, ok := propField(reflect.New(typ).Elem(), name)
+	if !ok {
+		return str, nil
+	}
+	ftyp := field.Type()
+	for ftyp.Kind() == reflect.Ptr {
+		ftyp = ftyp.Elem()
+	}
+
+	val := reflect.New(ftyp).Elem()
+	if ftyp == reflect.TypeOf(time.Time{}) {
+		var t time.Time
+		if t, err = time.Parse(time.RFC3339, str); err != nil {
+			return
+		}
+		return t, nil
+	}
+
+	switch ftyp.Kind() {
+	case reflect.String:
+		val.SetString(str)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(str); err != nil {
+			return
+		}
+		val.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(str, 10, ftyp.Bits()); err != nil {
+			return
+		}
+		val.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(str, 10, ftyp.Bits()); err != nil {
+			return
+		}
+		val.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(str, ftyp.Bits()); err != nil {
+			return
+		}
+		val.SetFloat(f)
+	default:
+		return str, nil
+	}
+	return val.Interface(), nil
+}
+
+// parseUint parses the unsigned integer value of the key,
+// or returns the default value if the key has no value
+func parseUint(v url.Values, key string, def uint64) (n uint64, err error) {
+	str := v.Get(key)
+	if str == "" {
+		return def, nil
+	}
+	if n, err = strconv.ParseUint(str, 10, 64); err != nil {
+		err = Error(http.StatusBadRequest, "invalid %s %#v", key, str)
+	}
+	return
+}
+
+// inStrings tells if the string is in the list
+func inStrings(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package store_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gourd/kit/store"
+)
+
+type parseEntity struct {
+	ID      string    `db:"id"`
+	Name    string    `db:"name"`
+	Age     int       `db:"age"`
+	Active  bool      `db:"active"`
+	Created time.Time `db:"created"`
+}
+
+func testParseOpts() *store.QueryOptions {
+	return &store.QueryOptions{
+		Filters: []string{"name", "age", "active", "created"},
+		Sorts:   []string{"name", "created"},
+		Fields:  []string{"id", "name"},
+		Entity:  parseEntity{},
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	v, err := url.ParseQuery("filter[age][gte]=18&filter[name]=hello" +
+		"&filter[active]=true&sort=-created,name&limit=20&offset=40&fields=id,name")
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	q, err := store.ParseQuery(v, testParseOpts())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	conds := q.GetConds().GetAll()
+	if want, have := 3, len(conds); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Cond{Prop: "active", Value: true}), conds[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Cond{Prop: "age", Value: 18, Op: store.Gte}), conds[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Cond{Prop: "name", Value: "hello"}), conds[2]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	sorts := q.GetSorts().GetAll()
+	if want, have := 2, len(sorts); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "-created", sorts[0].String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "name", sorts[1].String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if want, have := uint64(20), q.GetLimit(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := uint64(40), q.GetOffset(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 2, len(q.GetFields()); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestParseQuery_ops(t *testing.T) {
+	v := url.Values{}
+	v.Set("filter[age][in]", "10,20")
+	v.Set("filter[created][between]", "2016-01-01T00:00:00Z,2016-12-31T00:00:00Z")
+	v.Set("filter[name][null]", "false")
+
+	q, err := store.ParseQuery(v, testParseOpts())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	conds := q.GetConds().GetAll()
+	if want, have := 3, len(conds); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+
+	if want, have := store.In, conds[0].Op; want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+	if values := conds[0].Value.([]interface{}); len(values) != 2 || values[1] != 20 {
+		t.Errorf("unexpected values %#v", values)
+	}
+
+	if want, have := store.Between, conds[1].Op; want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+	values := conds[1].Value.([]interface{})
+	if want, have := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), values[0].(time.Time); !want.Equal(have) {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if want, have := store.NotNull, conds[2].Op; want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+}
+
+func TestParseQuery_defaults(t *testing.T) {
+	opts := testParseOpts()
+	opts.DefaultSorts = []string{"-created"}
+	opts.DefaultLimit = 10
+	opts.MaxLimit = 100
+
+	q, err := store.ParseQuery(url.Values{}, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "-created", q.GetSorts().GetAll()[0].String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := uint64(10), q.GetLimit(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// without options, values are kept as string
+	v := url.Values{}
+	v.Set("filter[age]", "18")
+	if q, err = store.ParseQuery(v, &store.QueryOptions{Filters: []string{"age"}}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "18", q.GetConds().GetAll()[0].Value; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestParseQuery_cursor(t *testing.T) {
+	c := &store.Cursor{Sorts: []string{"name"}, Values: []interface{}{"abc"}}
+
+	v := url.Values{}
+	v.Set("before", c.String())
+	q, err := store.ParseQuery(v, testParseOpts())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if have := q.GetCursor(); have == nil {
+		t.Fatalf("expected cursor, got nil")
+	} else if want, have := true, have.Before; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestParseQuery_aggregate(t *testing.T) {
+	opts := testParseOpts()
+	opts.Aggregates = []string{"name", "age"}
+
+	v, err := url.ParseQuery("group=name&aggregate=count,sum(age)&sort=-sum_age")
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	q, err := store.ParseQuery(v, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "name", q.GetGroupBy()[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	aggs := q.GetAggregates()
+	if want, have := 2, len(aggs); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Aggregate{Func: store.AggCount}), aggs[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Aggregate{Func: store.AggSum, Prop: "age"}), aggs[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "-sum_age", q.GetSorts().GetAll()[0].String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	for _, str := range []string{
+		"group=id",
+		"aggregate=sum(id)",
+		"aggregate=sum",
+		"aggregate=foo(age)",
+		"aggregate=sum(age",
+		"aggregate=sum(age)&sort=-max_age",
+	} {
+		v, _ := url.ParseQuery(str)
+		if _, err := store.ParseQuery(v, opts); err == nil {
+			t.Errorf("expected error for %#v, got nil", str)
+		} else if want, have := http.StatusBadRequest, store.ExpandError(err).Status; want != have {
+			t.Errorf("%#v: expected %#v, got %#v", str, want, have)
+		}
+	}
+}
+
+func TestParseQuery_badRequest(t *testing.T) {
+	opts := testParseOpts()
+	opts.MaxLimit = 100
+
+	tests := []string{
+		"filter[id]=1",
+		"filter[age][foo]=1",
+		"filter[age]=abc",
+		"filter[age][between]=1",
+		"filter[name][null]=abc",
+		"filter[age=1",
+		"sort=id",
+		"fields=id,age",
+		"sort=name,",
+		"limit=abc",
+		"limit=1000",
+		"offset=-1",
+		"after=abc&before=abc",
+		"after=!!!",
+	}
+	for _, str := range tests {
+		v, err := url.ParseQuery(str)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		_, err = store.ParseQuery(v, opts)
+		if err == nil {
+			t.Errorf("expected error for %#v, got nil", str)
+			continue
+		}
+		if want, have := http.StatusBadRequest, store.ExpandError(err).Status; want != have {
+			t.Errorf("%#v: expected %#v, got %#v", str, want, have)
+		}
+	}
+}
@@ -1,99 +1,546 @@
 package store
 
-import "time"
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ContextSource is the interface of Source which supports
+// aborting Open when the context is done
+type ContextSource interface {
+	Source
+
+	// OpenContext is like Open, but returns the context
+	// error if the context is done before the Conn opens
+	OpenContext(ctx context.Context) (Conn, error)
+}
 
 // PoolConn is a wrapper of Conn
-// that also implments Conn
+// that also implments Conn.
+//
+// Each Open of SourcePool returns a new PoolConn, which is
+// no longer usable after Close, even if the connection it
+// wraps is reused by later Open
 type PoolConn struct {
-	pool    *SourcePool
-	expires time.Time
-	err     error
-	dbConn  Conn
+	pool   *SourcePool
+	conn   *pooledConn
+	closed bool // guarded by the mutex of pool
+	timer  *time.Timer
 }
 
-// Err returns the connection error of the wrapped source
-func (conn *PoolConn) Err() error {
-	return conn.err
+// pooledConn is a connection of the source kept by SourcePool
+type pooledConn struct {
+	dbConn    Conn
+	expires   time.Time
+	idleSince time.Time
+	inTx      bool // transaction begun by the PoolConn in use
+	released  bool // no longer counts to MaxOpen
 }
 
 // Expired return wether the connection has expired or not
 func (conn *PoolConn) Expired() bool {
-	return time.Now().After(conn.expires)
+	return conn.conn != nil && !conn.conn.expires.IsZero() &&
+		time.Now().After(conn.conn.expires)
 }
 
-// Raw implements store.Conn.Raw()
+// Raw implements store.Conn.Raw(). Returns nil after Close
 func (conn *PoolConn) Raw() interface{} {
-	return conn.dbConn.Raw()
+	c, err := conn.inUse()
+	if err != nil {
+		return nil
+	}
+	return c.dbConn.Raw()
 }
 
-// Close implements store.Conn.Close()
+// Close implements store.Conn.Close(). The connection is
+// returned to the pool, unless the pool has enough idle
+// connections, is closed, or the connection is expired.
+// Transaction not committed is rolled back
 func (conn *PoolConn) Close() {
-	go func() {
-		// return itself to the pool if it is not expired
-		if !conn.Expired() {
-			pool := conn.pool
-			conn.pool = nil // remove reference to original conn.pool
-			pool.conns <- conn
-		}
-	}()
-	conn.dbConn.Close()
+	conn.pool.put(conn)
+}
+
+// Begin implements store.TxConn.Begin()
+// if the wrapped Conn supports transaction
+func (conn *PoolConn) Begin() (err error) {
+	txConn, c, err := conn.txConn()
+	if err != nil {
+		return
+	}
+	if err = txConn.Begin(); err == nil {
+		c.inTx = true
+	}
+	return
+}
+
+// Commit implements store.TxConn.Commit()
+// if the wrapped Conn supports transaction
+func (conn *PoolConn) Commit() (err error) {
+	txConn, c, err := conn.txConn()
+	if err != nil {
+		return
+	}
+	if err = txConn.Commit(); err == nil {
+		c.inTx = false
+	}
+	return
+}
+
+// Rollback implements store.TxConn.Rollback()
+// if the wrapped Conn supports transaction
+func (conn *PoolConn) Rollback() (err error) {
+	txConn, c, err := conn.txConn()
+	if err != nil {
+		return
+	}
+	if err = txConn.Rollback(); err == nil {
+		c.inTx = false
+	}
+	return
+}
+
+// inUse returns the connection wrapped, or error if closed
+func (conn *PoolConn) inUse() (c *pooledConn, err error) {
+	if conn.pool == nil || conn.conn == nil {
+		err = fmt.Errorf("connection not opened from pool")
+		return
+	}
+	conn.pool.mux.Lock()
+	defer conn.pool.mux.Unlock()
+	if conn.closed {
+		err = fmt.Errorf("connection already closed")
+		return
+	}
+	return conn.conn, nil
+}
+
+// txConn returns the connection wrapped as TxConn, or
+// error if closed or the connection does not support
+func (conn *PoolConn) txConn() (txConn TxConn, c *pooledConn, err error) {
+	if c, err = conn.inUse(); err != nil {
+		return
+	}
+	txConn, ok := c.dbConn.(TxConn)
+	if !ok {
+		err = fmt.Errorf("connection does not support transaction")
+	}
+	return
+}
+
+// PoolConfig configures SourcePool
+type PoolConfig struct {
+
+	// MaxOpen is the maximum number of connections open, in use
+	// or idle. Open blocks until a connection is returned when
+	// the maximum is reached. Zero means no limit
+	MaxOpen int
+
+	// MaxIdle is the maximum number of idle connections kept
+	// in the pool. Zero means no idle connection is kept
+	MaxIdle int
+
+	// IdleTimeout is the duration idle connections are kept
+	// before being closed. Zero means no timeout
+	IdleTimeout time.Duration
+
+	// MaxLifetime is the duration a connection could be reused.
+	// A connection older than that is closed instead of returned
+	// to the pool. Zero means no limit
+	MaxLifetime time.Duration
+
+	// ReclaimExpired, if true, stops counting a connection in use
+	// longer than MaxLifetime to MaxOpen, so connections never
+	// returned would not block Open forever. Otherwise connections
+	// in use always count to MaxOpen. Pool sets it
+	ReclaimExpired bool
+
+	// OpenTimeout is the timeout of Open waiting for connection.
+	// Zero means no timeout (see OpenContext)
+	OpenTimeout time.Duration
+
+	// HealthCheck, if not nil, checks an idle connection before
+	// checking out. Connection failed the check is closed
+	HealthCheck func(Conn) error
+}
+
+// PoolStats is a snapshot of statistics of SourcePool
+type PoolStats struct {
+
+	// Open is the number of connections open, in use or idle
+	Open int
+
+	// InUse is the number of connections in use
+	InUse int
+
+	// Idle is the number of idle connections
+	Idle int
+
+	// WaitCount is the total number of Open waited for connection
+	WaitCount uint64
+
+	// WaitDuration is the total duration of Open waited
+	WaitDuration time.Duration
+
+	// Opened is the total number of connections opened from the source
+	Opened uint64
+
+	// Closed is the total number of connections closed
+	Closed uint64
 }
 
 // SourcePool helps to pool connection of any given source
 type SourcePool struct {
-	conns   chan *PoolConn
 	src     Source
-	expires time.Duration
+	config  PoolConfig
+	mux     sync.Mutex
+	idle    []*pooledConn
+	numOpen int
+	waiters []chan bool
+	closed  bool
+	done    chan bool
+	stats   PoolStats
 }
 
-// Open implements Source.Open()
+// NewPool wraps a source into a SourcePool of the config
+func NewPool(src Source, config PoolConfig) *SourcePool {
+	pool := &SourcePool{
+		src:    src,
+		config: config,
+		done:   make(chan bool),
+	}
+
+	// close idle connections timed out or expired
+	interval := config.IdleTimeout
+	if interval == 0 || (config.MaxLifetime > 0 && config.MaxLifetime < interval) {
+		interval = config.MaxLifetime
+	}
+	if interval > 0 {
+		go pool.clean(interval)
+	}
+	return pool
+}
+
+// Pool wraps a source into a SourcePool of at most size connections,
+// which could be reused until expires. Connections in use longer than
+// expires no longer count to size (see PoolConfig.ReclaimExpired)
+func Pool(src Source, size uint, expires time.Duration) *SourcePool {
+	return NewPool(src, PoolConfig{
+		MaxOpen:        int(size),
+		MaxIdle:        int(size),
+		MaxLifetime:    expires,
+		ReclaimExpired: true,
+	})
+}
+
+// Open implements Source.Open(). Returns context.DeadlineExceeded
+// if no connection is available within OpenTimeout of the config
 func (pool *SourcePool) Open() (conn Conn, err error) {
-	var poolConn *PoolConn
-	for poolConn = <-pool.conns; poolConn.Expired(); poolConn = <-pool.conns {
-		// get connection that is not expired
+	ctx := context.Background()
+	if pool.config.OpenTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pool.config.OpenTimeout)
+		defer cancel()
 	}
-	err = poolConn.err
-	if err != nil {
-		return
+	return pool.OpenContext(ctx)
+}
+
+// OpenContext implements ContextSource. It checks out an idle
+// connection, or opens a new one from the source if the pool has
+// not reached MaxOpen. Otherwise it waits for a connection returned
+// until the context is done
+func (pool *SourcePool) OpenContext(ctx context.Context) (conn Conn, err error) {
+	for {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		pool.mux.Lock()
+		if pool.closed {
+			pool.mux.Unlock()
+			err = fmt.Errorf("source pool closed")
+			return
+		}
+
+		// reuse idle connection
+		if n := len(pool.idle); n > 0 {
+			c := pool.idle[n-1]
+			pool.idle = pool.idle[:n-1]
+			pool.mux.Unlock()
+			if pool.expired(c) {
+				pool.discard(c)
+				continue
+			}
+			if check := pool.config.HealthCheck; check != nil {
+				if check(c.dbConn) != nil {
+					pool.discard(c)
+					continue
+				}
+			}
+			return pool.checkout(c), nil
+		}
+
+		// open new connection
+		if pool.config.MaxOpen <= 0 || pool.numOpen < pool.config.MaxOpen {
+			pool.numOpen++
+			pool.mux.Unlock()
+
+			var dbConn Conn
+			if dbConn, err = pool.open(ctx); err != nil {
+				return
+			}
+			c := &pooledConn{dbConn: dbConn}
+			if pool.config.MaxLifetime > 0 {
+				c.expires = time.Now().Add(pool.config.MaxLifetime)
+			}
+
+			pool.mux.Lock()
+			pool.stats.Opened++
+			pool.mux.Unlock()
+			return pool.checkout(c), nil
+		}
+
+		// wait for connection returned
+		wait := make(chan bool, 1)
+		pool.waiters = append(pool.waiters, wait)
+		pool.stats.WaitCount++
+		pool.mux.Unlock()
+
+		begin := time.Now()
+		select {
+		case <-wait:
+			pool.mux.Lock()
+			pool.stats.WaitDuration += time.Since(begin)
+			pool.mux.Unlock()
+		case <-ctx.Done():
+			pool.mux.Lock()
+			pool.stats.WaitDuration += time.Since(begin)
+			for i, w := range pool.waiters {
+				if w == wait {
+					pool.waiters = append(pool.waiters[:i], pool.waiters[i+1:]...)
+					break
+				}
+			}
+			// pass on the signal received meanwhile, if any
+			select {
+			case <-wait:
+				pool.signal()
+			default:
+			}
+			pool.mux.Unlock()
+			err = ctx.Err()
+			return
+		}
+	}
+}
+
+// Close closes the pool and all the idle connections. Connections
+// in use are closed when returned. Open on closed pool returns error
+func (pool *SourcePool) Close() (err error) {
+	pool.mux.Lock()
+	if pool.closed {
+		pool.mux.Unlock()
+		return fmt.Errorf("source pool already closed")
+	}
+	pool.closed = true
+	close(pool.done)
+	idle := pool.idle
+	pool.idle = nil
+	pool.numOpen -= len(idle)
+	pool.stats.Closed += uint64(len(idle))
+	for _, wait := range pool.waiters {
+		wait <- true
+	}
+	pool.waiters = nil
+	pool.mux.Unlock()
+
+	for _, c := range idle {
+		c.dbConn.Close()
 	}
-	poolConn.pool = pool
-	conn = poolConn
 	return
 }
 
-// AddNewConn adds a new connection to the pool
-func (pool *SourcePool) AddNewConn() {
+// Stats returns a snapshot of statistics of the pool
+func (pool *SourcePool) Stats() (stats PoolStats) {
+	pool.mux.Lock()
+	defer pool.mux.Unlock()
+	stats = pool.stats
+	stats.Open = pool.numOpen
+	stats.Idle = len(pool.idle)
+	stats.InUse = pool.numOpen - len(pool.idle)
+	return
+}
+
+// open opens a new connection from the source, which counts
+// to MaxOpen already. Returns the context error if the context
+// is done before the connection opens, with the connection
+// closed when opened. The count is released on error
+func (pool *SourcePool) open(ctx context.Context) (dbConn Conn, err error) {
+	release := func() {
+		pool.mux.Lock()
+		pool.numOpen--
+		pool.signal()
+		pool.mux.Unlock()
+	}
+	if csrc, ok := pool.src.(ContextSource); ok {
+		if dbConn, err = csrc.OpenContext(ctx); err != nil {
+			release()
+		}
+		return
+	}
+
+	type result struct {
+		conn Conn
+		err  error
+	}
+	opened := make(chan result, 1)
 	go func() {
-		time.Sleep(pool.expires)
-		pool.AddNewConn()
+		conn, err := pool.src.Open()
+		opened <- result{conn, err}
 	}()
+	select {
+	case r := <-opened:
+		if r.err != nil {
+			release()
+		}
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-opened; r.err == nil {
+				r.conn.Close()
+			}
+			release()
+		}()
+		return nil, ctx.Err()
+	}
+}
 
-	dbConn, err := pool.src.Open()
-	expires := time.Now().Add(pool.expires)
-	if err != nil {
-		pool.conns <- &PoolConn{
-			err: err,
+// checkout returns a new PoolConn of the connection. If the
+// connection expires while in use and the pool reclaims expired
+// connections, it no longer counts to MaxOpen of the pool
+func (pool *SourcePool) checkout(c *pooledConn) *PoolConn {
+	pool.mux.Lock()
+	defer pool.mux.Unlock()
+	pc := &PoolConn{pool: pool, conn: c}
+	if c.expires.IsZero() || !pool.config.ReclaimExpired {
+		return pc
+	}
+	pc.timer = time.AfterFunc(c.expires.Sub(time.Now()), func() {
+		pool.mux.Lock()
+		defer pool.mux.Unlock()
+		if !pc.closed && !c.released {
+			c.released = true
+			pool.numOpen--
+			pool.signal()
 		}
+	})
+	return pc
+}
+
+// put returns the connection of the PoolConn to the pool, or closes
+// it. Transaction not committed is rolled back before the connection
+// is reused, or the connection is closed if it fails to roll back
+func (pool *SourcePool) put(pc *PoolConn) {
+	pool.mux.Lock()
+	if pc.closed || pc.conn == nil {
+		pool.mux.Unlock()
+		return
+	}
+	pc.closed = true
+	if pc.timer != nil {
+		pc.timer.Stop()
+	}
+	pool.mux.Unlock()
+
+	c := pc.conn
+	healthy := true
+	if c.inTx {
+		healthy = c.dbConn.(TxConn).Rollback() == nil
+		c.inTx = false
+	}
+
+	pool.mux.Lock()
+	keep := healthy && !pool.closed && !c.released && !pc.Expired() &&
+		len(pool.idle) < pool.config.MaxIdle
+	if keep {
+		c.idleSince = time.Now()
+		pool.idle = append(pool.idle, c)
 	} else {
-		pool.conns <- &PoolConn{
-			dbConn:  dbConn,
-			expires: expires,
+		if !c.released {
+			pool.numOpen--
 		}
+		pool.stats.Closed++
 	}
-}
+	pool.signal()
+	pool.mux.Unlock()
 
-// Pool wraps a source into a SourcePool
-func Pool(src Source, size uint, expires time.Duration) *SourcePool {
-	pool := &SourcePool{
-		conns:   make(chan *PoolConn, size),
-		src:     src,
-		expires: expires,
+	if !keep {
+		c.dbConn.Close()
 	}
+}
+
+// discard closes the connection checked out of idle
+func (pool *SourcePool) discard(c *pooledConn) {
+	pool.mux.Lock()
+	pool.numOpen--
+	pool.stats.Closed++
+	pool.signal()
+	pool.mux.Unlock()
+	c.dbConn.Close()
+}
 
-	// pump in new connections
-	for i := uint(0); i < size; i++ {
-		pool.AddNewConn()
+// expired returns whether the idle connection is
+// expired or idle longer than the IdleTimeout
+func (pool *SourcePool) expired(c *pooledConn) bool {
+	return (!c.expires.IsZero() && time.Now().After(c.expires)) ||
+		(pool.config.IdleTimeout > 0 && time.Since(c.idleSince) > pool.config.IdleTimeout)
+}
+
+// signal wakes up the first Open waiting for
+// connection, if any. Should be called with lock
+func (pool *SourcePool) signal() {
+	if len(pool.waiters) == 0 {
+		return
 	}
+	wait := pool.waiters[0]
+	pool.waiters = pool.waiters[1:]
+	wait <- true
+}
 
-	return pool
+// clean closes the idle connections expired
+// periodically, until the pool is closed
+func (pool *SourcePool) clean(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pool.done:
+			return
+		case <-ticker.C:
+		}
+
+		pool.mux.Lock()
+		kept := make([]*pooledConn, 0, len(pool.idle))
+		expired := make([]*pooledConn, 0)
+		for _, c := range pool.idle {
+			if pool.expired(c) {
+				expired = append(expired, c)
+			} else {
+				kept = append(kept, c)
+			}
+		}
+		pool.idle = kept
+		pool.numOpen -= len(expired)
+		pool.stats.Closed += uint64(len(expired))
+		for range expired {
+			pool.signal()
+		}
+		pool.mux.Unlock()
+
+		for _, c := range expired {
+			c.dbConn.Close()
+		}
+	}
 }
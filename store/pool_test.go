@@ -2,10 +2,12 @@ package store_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gourd/kit/store"
+	"golang.org/x/net/context"
 )
 
 // testConn implements store.Conn
@@ -14,7 +16,7 @@ type testConn struct {
 }
 
 func (conn *testConn) String() string   { return fmt.Sprintf("%d", conn.serial) }
-func (conn *testConn) Raw() interface{} { return nil }
+func (conn *testConn) Raw() interface{} { return conn }
 func (conn *testConn) Close()           {}
 
 // testSource implements store.Source
@@ -138,3 +140,309 @@ func TestSourcePool_connsReturning(t *testing.T) {
 		t.Errorf("failed to get connection after conn expires")
 	}
 }
+
+// test store.PoolConn implements store.TxConn
+func TestPoolConn_storeTxConn(t *testing.T) {
+	var conn store.TxConn = &store.PoolConn{}
+	_ = conn
+}
+
+// test store.SourcePool implements store.ContextSource
+func TestSourcePool_storeContextSource(t *testing.T) {
+	var src store.ContextSource = &store.SourcePool{}
+	_ = src
+}
+
+func TestSourcePool_OpenContext(t *testing.T) {
+	pool := store.NewPool(&testSource{}, store.PoolConfig{MaxOpen: 1, MaxIdle: 1})
+	defer pool.Close()
+
+	conn, err := pool.Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// pool is dry
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := pool.OpenContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %#v", err)
+	}
+
+	// the idle connection is reused
+	raw := conn.Raw()
+	conn.Close()
+	reused, err := pool.OpenContext(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := raw, reused.Raw(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	reused.Close()
+
+	stats := pool.Stats()
+	if want, have := 1, stats.Open; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 1, stats.Idle; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := uint64(1), stats.Opened; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := uint64(1), stats.WaitCount; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestSourcePool_OpenTimeout(t *testing.T) {
+	pool := store.NewPool(&testSource{}, store.PoolConfig{
+		MaxOpen:     1,
+		OpenTimeout: 10 * time.Millisecond,
+	})
+	defer pool.Close()
+
+	if _, err := pool.Open(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if _, err := pool.Open(); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %#v", err)
+	}
+}
+
+func TestSourcePool_maxIdle(t *testing.T) {
+	pool := store.NewPool(&testSource{}, store.PoolConfig{MaxIdle: 1})
+	defer pool.Close()
+
+	conns := make([]store.Conn, 3)
+	for i := range conns {
+		conns[i], _ = pool.Open()
+	}
+	if want, have := 3, pool.Stats().InUse; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	stats := pool.Stats()
+	if want, have := 1, stats.Idle; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := uint64(2), stats.Closed; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestSourcePool_idleTimeout(t *testing.T) {
+	pool := store.NewPool(&testSource{}, store.PoolConfig{
+		MaxIdle:     1,
+		IdleTimeout: 5 * time.Millisecond,
+	})
+	defer pool.Close()
+
+	conn, _ := pool.Open()
+	raw := conn.Raw()
+	conn.Close()
+	time.Sleep(20 * time.Millisecond)
+
+	if want, have := 0, pool.Stats().Idle; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if next, _ := pool.Open(); next.Raw() == raw {
+		t.Errorf("expected new connection after idle timeout")
+	}
+}
+
+func TestSourcePool_HealthCheck(t *testing.T) {
+	pool := store.NewPool(&testSource{}, store.PoolConfig{
+		MaxIdle: 1,
+		HealthCheck: func(conn store.Conn) error {
+			return fmt.Errorf("unhealthy")
+		},
+	})
+	defer pool.Close()
+
+	conn, _ := pool.Open()
+	raw := conn.Raw()
+	conn.Close()
+	if next, _ := pool.Open(); next.Raw() == raw {
+		t.Errorf("expected unhealthy connection to be replaced")
+	}
+	if want, have := uint64(2), pool.Stats().Opened; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestSourcePool_Close(t *testing.T) {
+	pool := store.NewPool(&testSource{}, store.PoolConfig{MaxOpen: 2, MaxIdle: 2})
+
+	idle, _ := pool.Open()
+	inUse, _ := pool.Open()
+	idle.Close()
+
+	// Open waiting is released by Close
+	errs := make(chan error)
+	go func() {
+		_, err := pool.Open()
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	<-errs // takes the idle one
+
+	go func() {
+		_, err := pool.Open()
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := pool.Close(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected waiting Open to be released")
+	}
+
+	if err := pool.Close(); err == nil {
+		t.Errorf("expected error closing twice, got nil")
+	}
+	if _, err := pool.Open(); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	// connection in use is closed when returned
+	inUse.Close()
+	if want, have := 1, pool.Stats().Open; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestPoolConn_closeTwice(t *testing.T) {
+	pool := store.NewPool(&testSource{}, store.PoolConfig{MaxOpen: 1, MaxIdle: 1})
+	defer pool.Close()
+
+	stale, _ := pool.Open()
+	raw := stale.Raw()
+	stale.Close()
+	conn, _ := pool.Open()
+	if want, have := raw, conn.Raw(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// closing the stale one does not return the connection in use
+	stale.Close()
+	if want, have := 1, pool.Stats().InUse; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if have := stale.Raw(); have != nil {
+		t.Errorf("expected nil, got %#v", have)
+	}
+	if err := stale.(store.TxConn).Begin(); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestSourcePool_MaxLifetime(t *testing.T) {
+	pool := store.NewPool(&testSource{}, store.PoolConfig{
+		MaxOpen:     1,
+		MaxIdle:     1,
+		MaxLifetime: time.Millisecond,
+		OpenTimeout: 20 * time.Millisecond,
+	})
+	defer pool.Close()
+
+	// expired connection in use still counts to MaxOpen
+	conn, _ := pool.Open()
+	time.Sleep(5 * time.Millisecond)
+	if _, err := pool.Open(); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %#v", err)
+	}
+
+	// and is closed when returned
+	conn.Close()
+	stats := pool.Stats()
+	if want, have := 0, stats.Open; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := uint64(1), stats.Closed; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+// txLogConn implements store.TxConn with the
+// transaction operations logged
+type txLogConn struct {
+	testConn
+	log []string
+}
+
+func (conn *txLogConn) Begin() error    { conn.log = append(conn.log, "begin"); return nil }
+func (conn *txLogConn) Commit() error   { conn.log = append(conn.log, "commit"); return nil }
+func (conn *txLogConn) Rollback() error { conn.log = append(conn.log, "rollback"); return nil }
+
+func TestSourcePool_rollback(t *testing.T) {
+	raw := &txLogConn{}
+	pool := store.NewPool(store.SourceFunc(func() (store.Conn, error) {
+		return raw, nil
+	}), store.PoolConfig{MaxOpen: 1, MaxIdle: 1})
+	defer pool.Close()
+
+	// transaction left open is rolled back when returned
+	conn, _ := pool.Open()
+	if err := conn.(store.TxConn).Begin(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	conn.Close()
+
+	// committed transaction is not
+	conn, _ = pool.Open()
+	conn.(store.TxConn).Begin()
+	conn.(store.TxConn).Commit()
+	conn.Close()
+
+	if want, have := "begin,rollback,begin,commit", strings.Join(raw.log, ","); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := uint64(1), pool.Stats().Opened; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+// slowSource implements store.Source which
+// opens connection after the delay
+type slowSource struct {
+	delay time.Duration
+}
+
+func (src slowSource) Open() (store.Conn, error) {
+	time.Sleep(src.delay)
+	return &testConn{}, nil
+}
+
+func TestSourcePool_OpenContext_slowSource(t *testing.T) {
+	pool := store.NewPool(slowSource{50 * time.Millisecond}, store.PoolConfig{MaxOpen: 1})
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	if _, err := pool.OpenContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %#v", err)
+	}
+	if d := time.Since(begin); d > 40*time.Millisecond {
+		t.Errorf("expected OpenContext to return on context done, took %s", d)
+	}
+
+	// the connection opened meanwhile is closed, and
+	// no longer counts to MaxOpen
+	conn, err := pool.Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	conn.Close()
+}
@@ -23,6 +23,9 @@ type Query interface {
 	// AddCond add a Cond to Conds
 	AddCond(prop string, val interface{}) Query
 
+	// AddCondOp add a Cond with comparison operator to Conds
+	AddCondOp(prop string, op Op, val interface{}) Query
+
 	// SetSorts sets the Sorts interface withing
 	SetSorts(Sorts) Query
 
@@ -31,6 +34,40 @@ type Query interface {
 
 	// AddSort add a Sort to Sorts
 	Sort(sstr string) Query
+
+	// SetCursor sets the keyset pagination cursor
+	SetCursor(*Cursor) Query
+
+	// GetCursor gets the keyset pagination cursor
+	GetCursor() *Cursor
+
+	// Select sets the fields to fetch. Selecting no
+	// field means to fetch all fields
+	Select(fields ...string) Query
+
+	// GetFields gets the fields to fetch
+	GetFields() []string
+
+	// GroupBy sets the properties to group by in aggregation
+	GroupBy(props ...string) Query
+
+	// GetGroupBy gets the properties to group by in aggregation
+	GetGroupBy() []string
+
+	// Aggregate adds an aggregate function of the property
+	// to the aggregation. Empty prop for AggCount counts entities
+	Aggregate(fn AggFunc, prop string) Query
+
+	// GetAggregates gets the aggregates of the aggregation
+	GetAggregates() []Aggregate
+
+	// SetIncludeDeleted sets if soft deleted entities
+	// should be included (see SoftDeleteProp)
+	SetIncludeDeleted(bool) Query
+
+	// GetIncludeDeleted gets if soft deleted entities
+	// should be included
+	GetIncludeDeleted() bool
 }
 
 // NewQuery constructs a *BasicQuery and return as Query
@@ -48,6 +85,12 @@ type BasicQuery struct {
 	Sorts  Sorts
 	Limit  uint64
 	Offset uint64
+	Cursor *Cursor
+	Fields []string
+	Groups []string
+	Aggs   []Aggregate
+
+	IncludeDeleted bool
 }
 
 // SetLimit is setter of limit
@@ -89,6 +132,12 @@ func (q *BasicQuery) AddCond(p string, v interface{}) Query {
 	return q
 }
 
+// AddCondOp add a Cond with comparison operator to Conds
+func (q *BasicQuery) AddCondOp(p string, op Op, v interface{}) Query {
+	q.Conds.AddOp(p, op, v)
+	return q
+}
+
 // SetSorts set the Sorts interface within
 func (q *BasicQuery) SetSorts(cs Sorts) Query {
 	q.Sorts = cs
@@ -105,3 +154,58 @@ func (q *BasicQuery) Sort(sstr string) Query {
 	q.Sorts.Add(sstr)
 	return q
 }
+
+// SetCursor sets the keyset pagination cursor
+func (q *BasicQuery) SetCursor(c *Cursor) Query {
+	q.Cursor = c
+	return q
+}
+
+// GetCursor gets the keyset pagination cursor
+func (q *BasicQuery) GetCursor() *Cursor {
+	return q.Cursor
+}
+
+// Select sets the fields to fetch
+func (q *BasicQuery) Select(fields ...string) Query {
+	q.Fields = fields
+	return q
+}
+
+// GetFields gets the fields to fetch
+func (q *BasicQuery) GetFields() []string {
+	return q.Fields
+}
+
+// GroupBy sets the properties to group by in aggregation
+func (q *BasicQuery) GroupBy(props ...string) Query {
+	q.Groups = props
+	return q
+}
+
+// GetGroupBy gets the properties to group by in aggregation
+func (q *BasicQuery) GetGroupBy() []string {
+	return q.Groups
+}
+
+// Aggregate adds an aggregate function of the property
+func (q *BasicQuery) Aggregate(fn AggFunc, prop string) Query {
+	q.Aggs = append(q.Aggs, Aggregate{Func: fn, Prop: prop})
+	return q
+}
+
+// GetAggregates gets the aggregates of the aggregation
+func (q *BasicQuery) GetAggregates() []Aggregate {
+	return q.Aggs
+}
+
+// SetIncludeDeleted sets if soft deleted entities should be included
+func (q *BasicQuery) SetIncludeDeleted(include bool) Query {
+	q.IncludeDeleted = include
+	return q
+}
+
+// GetIncludeDeleted gets if soft deleted entities should be included
+func (q *BasicQuery) GetIncludeDeleted() bool {
+	return q.IncludeDeleted
+}
@@ -119,3 +119,70 @@ func TestBasicQuery_Sorts(t *testing.T) {
 		t.Log("Query cond routine works expectedly")
 	}
 }
+
+func TestBasicQuery_CondOp(t *testing.T) {
+	q := store.NewQuery().
+		AddCondOp("Age", store.Between, []int{18, 30})
+	cs := q.GetConds().GetAll()
+
+	if want, have := 1, len(cs); want != have {
+		t.Fatalf("want: %#v, got: %#v", want, have)
+	}
+	if want, have := "Age", cs[0].Prop; want != have {
+		t.Errorf("want: %#v, got: %#v", want, have)
+	}
+	if want, have := store.Between, cs[0].Op; want != have {
+		t.Errorf("want: %s, got: %s", want, have)
+	}
+}
+
+func TestBasicQuery_Cursor(t *testing.T) {
+	q := store.NewQuery()
+	if have := q.GetCursor(); have != nil {
+		t.Errorf("expected nil, got %#v", have)
+	}
+	c := &store.Cursor{Sorts: []string{"id"}, Values: []interface{}{"abc"}}
+	if want, have := c, q.SetCursor(c).GetCursor(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestBasicQuery_Select(t *testing.T) {
+	q := store.NewQuery()
+	if have := q.GetFields(); len(have) != 0 {
+		t.Errorf("expected no fields, got %#v", have)
+	}
+	fields := q.Select("id", "name").GetFields()
+	if want, have := 2, len(fields); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "name", fields[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestBasicQuery_Aggregate(t *testing.T) {
+	q := store.NewQuery().GroupBy("group").
+		Aggregate(store.AggCount, "").
+		Aggregate(store.AggSum, "amount")
+	if want, have := 1, len(q.GetGroupBy()); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	aggs := q.GetAggregates()
+	if want, have := 2, len(aggs); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Aggregate{Func: store.AggSum, Prop: "amount"}), aggs[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestBasicQuery_IncludeDeleted(t *testing.T) {
+	q := store.NewQuery()
+	if q.GetIncludeDeleted() {
+		t.Errorf("expected deleted entities to be excluded by default")
+	}
+	if !q.SetIncludeDeleted(true).GetIncludeDeleted() {
+		t.Errorf("expected deleted entities to be included")
+	}
+}
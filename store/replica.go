@@ -0,0 +1,243 @@
+package store
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// Balance is the policy to pick a replica source for reads
+type Balance int
+
+// replica balancing policies
+const (
+
+	// RoundRobin picks the replica sources in turn
+	RoundRobin Balance = iota
+
+	// LeastLoaded picks the replica source read by
+	// the least contexts (see WithFactory) at the moment
+	LeastLoaded
+)
+
+// Replicas defines the read replica sources of a store
+// (see ReplicaFactory)
+type Replicas struct {
+
+	// Sources are the source keys of the replicas
+	Sources []interface{}
+
+	// Balance is the policy to pick replica for reads
+	Balance Balance
+
+	// PinAfterWrite, if true, pins reads to the primary source
+	// for the rest of the context (see WithFactory) after a
+	// successful write to the primary source
+	PinAfterWrite bool
+
+	mux   sync.Mutex
+	next  int
+	loads map[interface{}]int
+}
+
+// pick returns the source key of the replica to read from,
+// with its load added until done is called
+func (r *Replicas) pick() (srcKey interface{}, done func()) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.loads == nil {
+		r.loads = make(map[interface{}]int)
+	}
+
+	switch r.Balance {
+	case LeastLoaded:
+		srcKey = r.Sources[0]
+		for _, key := range r.Sources[1:] {
+			if r.loads[key] < r.loads[srcKey] {
+				srcKey = key
+			}
+		}
+	default:
+		srcKey = r.Sources[r.next%len(r.Sources)]
+		r.next = (r.next + 1) % len(r.Sources)
+	}
+
+	r.loads[srcKey]++
+	done = func() {
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		r.loads[srcKey]--
+	}
+	return
+}
+
+// splitStore implements Store with reads from replica sources and
+// writes to the primary source. Reads go to the primary source in
+// transaction, or after write if the replicas pin after write
+type splitStore struct {
+	mux          sync.Mutex
+	primaryStore Store // the store of the primary source, once opened
+
+	ctx      context.Context
+	sts      *stores
+	srcKey   interface{}
+	provider Provider
+	replicas *Replicas
+	proto    Store // the store for memory allocation
+}
+
+// newSplitStore returns splitStore of the primary source key,
+// with the provider and replicas
+func newSplitStore(ctx context.Context, sts *stores, srcKey interface{},
+	provider Provider, replicas *Replicas) (s *splitStore, err error) {
+
+	s = &splitStore{
+		ctx:      ctx,
+		sts:      sts,
+		srcKey:   srcKey,
+		provider: provider,
+		replicas: replicas,
+	}
+	if s.proto, err = s.reader(func(Store) error { return nil }); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// primary returns the store of the primary source
+func (s *splitStore) primary() (ps Store, err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.primaryStore == nil {
+		if s.primaryStore, err = s.sts.open(s.ctx, s.srcKey, s.provider, false); err != nil {
+			return
+		}
+	}
+	return s.primaryStore, nil
+}
+
+// reader runs the read operation on the store of a replica source,
+// or of the primary source if in transaction or pinned. Reads in the
+// same context go to the same replica
+func (s *splitStore) reader(op func(Store) error) (rs Store, err error) {
+	if s.sts.inTransaction() || s.sts.isPinned(s.srcKey) {
+		if rs, err = s.primary(); err != nil {
+			return
+		}
+		err = op(rs)
+		return
+	}
+
+	srcKey := s.sts.replica(s.srcKey, s.replicas)
+	if rs, err = s.sts.open(s.ctx, srcKey, s.provider, true); err != nil {
+		return
+	}
+	err = op(rs)
+	return
+}
+
+// writer runs the write operation on the store of the primary source
+func (s *splitStore) writer(op func(Store) error) (err error) {
+	ps, err := s.primary()
+	if err != nil {
+		return
+	}
+	if err = op(ps); err == nil && s.replicas.PinAfterWrite {
+		s.sts.pin(s.srcKey)
+	}
+	return
+}
+
+// Create implements Store
+func (s *splitStore) Create(c Conds, ep EntityPtr) error {
+	return s.writer(func(ps Store) error {
+		return ps.Create(c, ep)
+	})
+}
+
+// Search implements Store. The replica is picked on Search
+func (s *splitStore) Search(q Query) (res Result) {
+	if _, err := s.reader(func(rs Store) error {
+		res = rs.Search(q)
+		return nil
+	}); err != nil {
+		return &errResult{err: err}
+	}
+	return
+}
+
+// One implements Store
+func (s *splitStore) One(c Conds, ep EntityPtr) (err error) {
+	_, err = s.reader(func(rs Store) error {
+		return rs.One(c, ep)
+	})
+	return
+}
+
+// Update implements Store
+func (s *splitStore) Update(c Conds, ep EntityPtr) error {
+	return s.writer(func(ps Store) error {
+		return ps.Update(c, ep)
+	})
+}
+
+// Delete implements Store
+func (s *splitStore) Delete(c Conds) error {
+	return s.writer(func(ps Store) error {
+		return ps.Delete(c)
+	})
+}
+
+// CreateMany implements BulkStore
+func (s *splitStore) CreateMany(c Conds, el EntityListPtr) error {
+	return s.writer(func(ps Store) error {
+		return CreateMany(ps, c, el)
+	})
+}
+
+// UpdateMany implements BulkStore
+func (s *splitStore) UpdateMany(c Conds, fields map[string]interface{}) error {
+	return s.writer(func(ps Store) error {
+		return UpdateMany(ps, c, fields)
+	})
+}
+
+// Upsert implements UpsertStore
+func (s *splitStore) Upsert(c Conds, ep EntityPtr) error {
+	return s.writer(func(ps Store) error {
+		return Upsert(ps, c, ep)
+	})
+}
+
+// AllocEntity implements Store
+func (s *splitStore) AllocEntity() EntityPtr {
+	return s.proto.AllocEntity()
+}
+
+// AllocEntityList implements Store
+func (s *splitStore) AllocEntityList() EntityListPtr {
+	return s.proto.AllocEntityList()
+}
+
+// Len implements Store
+func (s *splitStore) Len(el EntityListPtr) int64 {
+	return s.proto.Len(el)
+}
+
+// Close implements Store
+func (s *splitStore) Close() error {
+	return s.proto.Close()
+}
+
+// errResult implements Result which fails with the error
+type errResult struct {
+	err error
+}
+
+func (res *errResult) All(el interface{}) error           { return res.err }
+func (res *errResult) Raw() (interface{}, error)          { return nil, res.err }
+func (res *errResult) Count() (uint64, error)             { return 0, res.err }
+func (res *errResult) Aggregate() ([]AggregateRow, error) { return nil, res.err }
+func (res *errResult) Next(ep EntityPtr) bool             { return false }
+func (res *errResult) Err() error                         { return res.err }
+func (res *errResult) Close() error                       { return nil }
@@ -0,0 +1,196 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+	"golang.org/x/net/context"
+)
+
+type replicaKey int
+
+const (
+	primarySrc replicaKey = iota
+	replicaSrc1
+	replicaSrc2
+	replicaStore
+)
+
+// replicaFactory returns a factory of a store with a
+// primary source and 2 replica sources, with an entity
+// named after the source in each
+func replicaFactory(t *testing.T, replicas *store.Replicas) store.Factory {
+	factory := store.NewFactory()
+	provider := memstore.Provider("entity", &bulkEntity{})
+	for srcKey, name := range map[replicaKey]string{
+		primarySrc:  "primary",
+		replicaSrc1: "replica1",
+		replicaSrc2: "replica2",
+	} {
+		src := memstore.NewSource()
+		conn, _ := src.Open()
+		s, _ := provider(conn.Raw())
+		if err := s.Create(nil, &bulkEntity{ID: "1", Name: name}); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		factory.SetSource(srcKey, src)
+	}
+	factory.Set(replicaStore, primarySrc, provider)
+	replicas.Sources = []interface{}{replicaSrc1, replicaSrc2}
+	factory.(store.ReplicaFactory).SetReplicas(replicaStore, replicas)
+	return factory
+}
+
+// readFrom returns the name of the entity read by the store
+func readFrom(t *testing.T, s store.Store) string {
+	e := &bulkEntity{}
+	if err := s.One(store.NewConds().Add("id", "1"), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	return e.Name
+}
+
+func TestReplicas_RoundRobin(t *testing.T) {
+	factory := replicaFactory(t, &store.Replicas{Balance: store.RoundRobin})
+
+	for _, want := range []string{"replica1", "replica2", "replica1"} {
+		ctx := store.WithFactory(context.Background(), factory)
+		s, err := store.Get(ctx, replicaStore)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+
+		// reads in the same context go to the same replica
+		for i := 0; i < 2; i++ {
+			if have := readFrom(t, s); want != have {
+				t.Errorf("expected %#v, got %#v", want, have)
+			}
+		}
+		store.CloseAllIn(ctx)
+	}
+}
+
+func TestReplicas_LeastLoaded(t *testing.T) {
+	factory := replicaFactory(t, &store.Replicas{Balance: store.LeastLoaded})
+
+	ctx1 := store.WithFactory(context.Background(), factory)
+	s1, _ := store.Get(ctx1, replicaStore)
+	if want, have := "replica1", readFrom(t, s1); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// replica1 is in use by ctx1
+	ctx2 := store.WithFactory(context.Background(), factory)
+	s2, _ := store.Get(ctx2, replicaStore)
+	if want, have := "replica2", readFrom(t, s2); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	store.CloseAllIn(ctx1)
+
+	// replica1 is released by ctx1
+	ctx3 := store.WithFactory(context.Background(), factory)
+	s3, _ := store.Get(ctx3, replicaStore)
+	if want, have := "replica1", readFrom(t, s3); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	store.CloseAllIn(ctx2)
+	store.CloseAllIn(ctx3)
+}
+
+func TestReplicas_write(t *testing.T) {
+	for _, pin := range []bool{false, true} {
+		factory := replicaFactory(t, &store.Replicas{PinAfterWrite: pin})
+		ctx := store.WithFactory(context.Background(), factory)
+
+		s, err := store.Get(ctx, replicaStore)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if err := s.Update(store.NewConds().Add("id", "1"),
+			&bulkEntity{ID: "1", Name: "updated"}); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+
+		want := "replica1"
+		if pin {
+			want = "updated"
+		}
+		if have := readFrom(t, s); want != have {
+			t.Errorf("pin %t: expected %#v, got %#v", pin, want, have)
+		}
+		store.CloseAllIn(ctx)
+	}
+}
+
+func TestReplicas_failedWrite(t *testing.T) {
+	factory := replicaFactory(t, &store.Replicas{PinAfterWrite: true})
+	ctx := store.WithFactory(context.Background(), factory)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, replicaStore)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := store.UpdateMany(s, store.NewConds().Add("id", "1"),
+		map[string]interface{}{"unknown": "updated"}); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	// failed writes do not pin reads
+	if want, have := "replica1", readFrom(t, s); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestReplicas_transaction(t *testing.T) {
+	factory := replicaFactory(t, &store.Replicas{})
+	src := factory.GetSource(primarySrc)
+	factory.SetSource(primarySrc, store.SourceFunc(func() (store.Conn, error) {
+		conn, err := src.Open()
+		return noopTxConn{conn}, err
+	}))
+	ctx := store.WithFactory(context.Background(), factory)
+	defer store.CloseAllIn(ctx)
+
+	if err := store.Begin(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	s, err := store.Get(ctx, replicaStore)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "primary", readFrom(t, s); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestReplicas_beginAfterRead(t *testing.T) {
+	factory := replicaFactory(t, &store.Replicas{})
+	src := factory.GetSource(primarySrc)
+	factory.SetSource(primarySrc, store.SourceFunc(func() (store.Conn, error) {
+		conn, err := src.Open()
+		return noopTxConn{conn}, err
+	}))
+	ctx := store.WithFactory(context.Background(), factory)
+	defer store.CloseAllIn(ctx)
+
+	s, err := store.Get(ctx, replicaStore)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "replica1", readFrom(t, s); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// replica connection does not join the transaction
+	if err := store.Begin(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "primary", readFrom(t, s); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if err := store.Commit(ctx); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+}
@@ -14,6 +14,32 @@ type Result interface {
 	// Count returns the number of items matches match the given query
 	Count() (count uint64, err error)
 
-	// Close closes the result set
+	// Aggregate groups the entities matching the query conditions by
+	// the group-by properties of the query and computes the aggregates
+	// of the query for each group (see Query.GroupBy, Query.Aggregate)
+	Aggregate() (rows []AggregateRow, err error)
+
+	// Next fetches the next entity of the result set into the given
+	// entity pointer. Returns false if there is no more entity or
+	// there is error. Iteration error could be retrieved by Err
+	//
+	// Entities are fetched one by one so large result set
+	// could be walked through with constant memory:
+	//
+	//	res := s.Search(q)
+	//	defer res.Close()
+	//	for e := s.AllocEntity(); res.Next(e); {
+	//		// do something with e
+	//	}
+	//	if err := res.Err(); err != nil {
+	//		// handle error
+	//	}
+	Next(ep EntityPtr) bool
+
+	// Err returns the error, if any, encountered in Next
+	Err() error
+
+	// Close closes the result set and releases the
+	// underlying resources, if any
 	Close() error
 }
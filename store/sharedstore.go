@@ -0,0 +1,272 @@
+package store
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// sharedStore implements Store on a connection shared in a stores.
+// Each operation holds a reference to the connection while in flight,
+// so the connection would not be closed under it (see stores.Close).
+//
+// Raw of TxConn changes on Begin, Commit and Rollback (see TxConn),
+// so operations run on the Store provided again with the current
+// session if the transaction of the stores has changed since. Stores
+// obtained before Begin would also join the transaction.
+//
+// It implements BulkStore, UpsertStore and ContextStore with the
+// native operations of the wrapped Store, if any
+type sharedStore struct {
+	Store
+	sts      *stores
+	conn     *sharedConn
+	provider Provider
+
+	mux     sync.Mutex
+	bound   Store
+	session uint64
+}
+
+// newSharedStore returns the sharedStore of the provider
+// on the connection, bound to its current session
+func newSharedStore(sts *stores, sc *sharedConn, provider Provider) (s *sharedStore, err error) {
+	raw, session := sts.session(sc)
+	inner, err := provider(raw)
+	if err != nil {
+		return
+	}
+	s = &sharedStore{Store: inner, sts: sts, conn: sc, provider: provider,
+		bound: inner, session: session}
+	return
+}
+
+// current returns the Store bound to the current session
+// of the connection, providing it again if changed
+func (s *sharedStore) current() (Store, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	raw, session := s.sts.session(s.conn)
+	if session != s.session {
+		inner, err := s.provider(raw)
+		if err != nil {
+			return nil, err
+		}
+		s.bound, s.session = inner, session
+	}
+	return s.bound, nil
+}
+
+// do runs the operation on the Store bound to the current
+// session, with a reference to the connection
+func (s *sharedStore) do(op func(Store) error) (err error) {
+	if err = s.sts.acquire(s.conn); err != nil {
+		return
+	}
+	defer s.sts.release(s.conn)
+	inner, err := s.current()
+	if err != nil {
+		return
+	}
+	return op(inner)
+}
+
+// Create implements Store
+func (s *sharedStore) Create(c Conds, ep EntityPtr) error {
+	return s.do(func(inner Store) error {
+		return inner.Create(c, ep)
+	})
+}
+
+// Search implements Store
+func (s *sharedStore) Search(q Query) Result {
+	return &sharedResult{store: s, query: q}
+}
+
+// One implements Store
+func (s *sharedStore) One(c Conds, ep EntityPtr) error {
+	return s.do(func(inner Store) error {
+		return inner.One(c, ep)
+	})
+}
+
+// Update implements Store
+func (s *sharedStore) Update(c Conds, ep EntityPtr) error {
+	return s.do(func(inner Store) error {
+		return inner.Update(c, ep)
+	})
+}
+
+// Delete implements Store
+func (s *sharedStore) Delete(c Conds) error {
+	return s.do(func(inner Store) error {
+		return inner.Delete(c)
+	})
+}
+
+// CreateMany implements BulkStore
+func (s *sharedStore) CreateMany(c Conds, el EntityListPtr) error {
+	return s.do(func(inner Store) error {
+		return CreateMany(inner, c, el)
+	})
+}
+
+// UpdateMany implements BulkStore
+func (s *sharedStore) UpdateMany(c Conds, fields map[string]interface{}) error {
+	return s.do(func(inner Store) error {
+		return UpdateMany(inner, c, fields)
+	})
+}
+
+// Upsert implements UpsertStore
+func (s *sharedStore) Upsert(c Conds, ep EntityPtr) error {
+	return s.do(func(inner Store) error {
+		return Upsert(inner, c, ep)
+	})
+}
+
+// CreateContext implements ContextStore
+func (s *sharedStore) CreateContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	if _, ok := s.Store.(ContextStore); !ok {
+		return s.contextStore().CreateContext(ctx, c, ep)
+	}
+	return s.do(func(inner Store) error {
+		return inner.(ContextStore).CreateContext(ctx, c, ep)
+	})
+}
+
+// SearchContext implements ContextStore
+func (s *sharedStore) SearchContext(ctx context.Context, q Query) Result {
+	if _, ok := s.Store.(ContextStore); !ok {
+		return s.contextStore().SearchContext(ctx, q)
+	}
+	return &sharedResult{store: s, query: q, ctx: ctx}
+}
+
+// OneContext implements ContextStore
+func (s *sharedStore) OneContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	if _, ok := s.Store.(ContextStore); !ok {
+		return s.contextStore().OneContext(ctx, c, ep)
+	}
+	return s.do(func(inner Store) error {
+		return inner.(ContextStore).OneContext(ctx, c, ep)
+	})
+}
+
+// UpdateContext implements ContextStore
+func (s *sharedStore) UpdateContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	if _, ok := s.Store.(ContextStore); !ok {
+		return s.contextStore().UpdateContext(ctx, c, ep)
+	}
+	return s.do(func(inner Store) error {
+		return inner.(ContextStore).UpdateContext(ctx, c, ep)
+	})
+}
+
+// DeleteContext implements ContextStore
+func (s *sharedStore) DeleteContext(ctx context.Context, c Conds) error {
+	if _, ok := s.Store.(ContextStore); !ok {
+		return s.contextStore().DeleteContext(ctx, c)
+	}
+	return s.do(func(inner Store) error {
+		return inner.(ContextStore).DeleteContext(ctx, c)
+	})
+}
+
+// contextStore returns the ContextStore of the operations of the
+// sharedStore, for wrapped Store without native ContextStore.
+// Operations abandoned when the context is done keep running on
+// the sharedStore, so the reference to the connection is held
+// until they actually return
+func (s *sharedStore) contextStore() ContextStore {
+	return &contextStore{s}
+}
+
+// sharedResult implements Result of sharedStore. The underlying
+// search is done on the first use, with reference to the connection
+// held by each method in flight. The search is done with the context,
+// if not nil, on the wrapped Store implementing ContextStore
+type sharedResult struct {
+	store *sharedStore
+	query Query
+	ctx   context.Context
+	res   Result
+	err   error
+}
+
+// do runs the operation on the underlying result
+// with a reference to the connection
+func (res *sharedResult) do(op func(Result) error) error {
+	return res.store.do(func(inner Store) error {
+		if res.res == nil {
+			if cs, ok := inner.(ContextStore); ok && res.ctx != nil {
+				res.res = cs.SearchContext(res.ctx, res.query)
+			} else {
+				res.res = inner.Search(res.query)
+			}
+		}
+		return op(res.res)
+	})
+}
+
+// All implements Result
+func (res *sharedResult) All(el interface{}) error {
+	return res.do(func(r Result) error {
+		return r.All(el)
+	})
+}
+
+// Raw implements Result
+func (res *sharedResult) Raw() (raw interface{}, err error) {
+	err = res.do(func(r Result) (err error) {
+		raw, err = r.Raw()
+		return
+	})
+	return
+}
+
+// Count implements Result
+func (res *sharedResult) Count() (count uint64, err error) {
+	err = res.do(func(r Result) (err error) {
+		count, err = r.Count()
+		return
+	})
+	return
+}
+
+// Aggregate implements Result
+func (res *sharedResult) Aggregate() (rows []AggregateRow, err error) {
+	err = res.do(func(r Result) (err error) {
+		rows, err = r.Aggregate()
+		return
+	})
+	return
+}
+
+// Next implements Result
+func (res *sharedResult) Next(ep EntityPtr) (ok bool) {
+	res.err = res.do(func(r Result) error {
+		ok = r.Next(ep)
+		return nil
+	})
+	return ok && res.err == nil
+}
+
+// Err implements Result
+func (res *sharedResult) Err() error {
+	if res.err != nil {
+		return res.err
+	}
+	if res.res == nil {
+		return nil
+	}
+	return res.res.Err()
+}
+
+// Close implements Result
+func (res *sharedResult) Close() error {
+	if res.res == nil {
+		return nil
+	}
+	return res.res.Close()
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SoftDeleteProp returns the property name of the soft delete field
+// of the entity (or pointer to entity). The field should be a *time.Time
+// tagged with `gourddelete:"soft"`, e.g.
+//
+//	DeletedAt *time.Time `db:"deleted_at" gourddelete:"soft"`
+//
+// Entity with the field is soft deleted by setting the field to the
+// time of deletion, instead of being removed. A nil field means the
+// entity is not deleted. The property name is the name in "db" tag,
+// or the field name if not tagged
+func SoftDeleteProp(entity interface{}) (prop string, ok bool) {
+	typ := reflect.TypeOf(entity)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Tag.Get("gourddelete") != "soft" ||
+			field.Type != reflect.TypeOf(&time.Time{}) {
+			continue
+		}
+		if prop = strings.Split(field.Tag.Get("db"), ",")[0]; prop == "" {
+			prop = field.Name
+		}
+		return prop, true
+	}
+	return
+}
+
+// NotDeleted returns the conditions with additional condition to
+// exclude soft deleted entities. Returns the conditions as is if
+// the entity has no soft delete field
+func NotDeleted(c Conds, entity interface{}) Conds {
+	return softDeleteConds(c, entity, IsNull)
+}
+
+// Deleted returns the conditions with additional condition to
+// match only soft deleted entities. Returns the conditions as is
+// if the entity has no soft delete field
+func Deleted(c Conds, entity interface{}) Conds {
+	return softDeleteConds(c, entity, NotNull)
+}
+
+// softDeleteConds adds the condition of the operator
+// on soft delete property to the conditions
+func softDeleteConds(c Conds, entity interface{}, op Op) Conds {
+	prop, ok := SoftDeleteProp(entity)
+	if !ok {
+		return c
+	}
+	cs := NewConds()
+	if c != nil && len(c.GetAll()) > 0 {
+		cs.Add("", c)
+	}
+	return cs.AddOp(prop, op, nil)
+}
+
+// SearchConds returns the conditions to search entities by the
+// query (see QueryConds). Soft deleted entities are excluded,
+// unless the query includes deleted
+func SearchConds(q Query, entity interface{}) (cs Conds, err error) {
+	if cs, err = QueryConds(q); err != nil || q.GetIncludeDeleted() {
+		return
+	}
+	cs = NotDeleted(cs, entity)
+	return
+}
+
+// SoftDeleteFields returns the fields (see UpdateMany) to soft
+// delete the entity. Returns false if the entity has no soft
+// delete field
+func SoftDeleteFields(entity interface{}) (fields map[string]interface{}, ok bool) {
+	prop, ok := SoftDeleteProp(entity)
+	if !ok {
+		return
+	}
+	fields = map[string]interface{}{prop: time.Now()}
+	return
+}
+
+// Restore restores the soft deleted entities matching the conditions.
+// Returns 500 StoreError if the entity of the store has no soft
+// delete field
+func Restore(s Store, c Conds) error {
+	entity := s.AllocEntity()
+	prop, ok := SoftDeleteProp(entity)
+	if !ok {
+		return Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("%T has no soft delete field", entity)
+	}
+	return UpdateMany(s, Deleted(c, entity), map[string]interface{}{prop: nil})
+}
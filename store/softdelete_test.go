@@ -0,0 +1,138 @@
+package store_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+)
+
+type softEntity struct {
+	ID        string     `db:"id,omitempty"`
+	Name      string     `db:"name"`
+	DeletedAt *time.Time `db:"deleted_at" gourddelete:"soft"`
+}
+
+func softStore(t *testing.T) store.Store {
+	conn, err := memstore.NewSource().Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	s, err := memstore.Provider("entity", &softEntity{})(conn.Raw())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	return s
+}
+
+func TestSoftDeleteProp(t *testing.T) {
+	prop, ok := store.SoftDeleteProp(&softEntity{})
+	if !ok {
+		t.Fatalf("expected soft delete field")
+	}
+	if want, have := "deleted_at", prop; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if _, ok := store.SoftDeleteProp(bulkEntity{}); ok {
+		t.Errorf("expected no soft delete field")
+	}
+	if _, ok := store.SoftDeleteProp(struct {
+		DeletedAt time.Time `gourddelete:"soft"`
+	}{}); ok {
+		t.Errorf("expected time.Time field to be ignored")
+	}
+	if _, ok := store.SoftDeleteProp(nil); ok {
+		t.Errorf("expected no soft delete field")
+	}
+}
+
+func TestNotDeleted(t *testing.T) {
+	c := store.NewConds().Add("name", "foo")
+
+	cs := store.NotDeleted(c, &softEntity{}).GetAll()
+	if want, have := 2, len(cs); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := c, cs[0].Value; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Cond{Prop: "deleted_at", Op: store.IsNull}), cs[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	cs = store.Deleted(nil, &softEntity{}).GetAll()
+	if want, have := 1, len(cs); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := store.NotNull, cs[0].Op; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if want, have := c, store.NotDeleted(c, &bulkEntity{}); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	for _, s := range []store.Store{softStore(t), plainStore{softStore(t)}} {
+		for _, name := range []string{"foo", "bar"} {
+			if err := s.Create(nil, &softEntity{Name: name}); err != nil {
+				t.Fatalf("unexpected error: %#v", err.Error())
+			}
+		}
+
+		// soft delete
+		foo := store.NewConds().Add("name", "foo")
+		if err := s.Delete(foo); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		err := s.One(foo, &softEntity{})
+		if err == nil {
+			t.Fatalf("expected soft deleted entity not found")
+		}
+		if want, have := http.StatusNotFound, store.ExpandError(err).Status; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+
+		found := &[]softEntity{}
+		if err := s.Search(store.NewQuery()).All(found); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if want, have := 1, len(*found); want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+
+		// include deleted
+		found = &[]softEntity{}
+		q := store.NewQuery().SetConds(foo).SetIncludeDeleted(true)
+		if err := s.Search(q).All(found); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if want, have := 1, len(*found); want != have {
+			t.Fatalf("expected %#v, got %#v", want, have)
+		}
+		if (*found)[0].DeletedAt == nil {
+			t.Errorf("expected deleted_at to be set")
+		}
+
+		// restore
+		if err := store.Restore(s, foo); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		restored := &softEntity{}
+		if err := s.One(foo, restored); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if restored.DeletedAt != nil {
+			t.Errorf("expected deleted_at to be cleared, got %#v", restored.DeletedAt)
+		}
+	}
+
+	// store without soft delete field
+	if err := store.Restore(bulkStore(t), nil); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
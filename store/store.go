@@ -1,8 +1,15 @@
 package store
 
+import (
+	"golang.org/x/net/context"
+)
+
 // Store defines interface of an entity service
 type Store interface {
-	// Basic entity operations
+	// Basic entity operations.
+	//
+	// Create generates a random id for entity of empty string
+	// id (field of db name "id"), or keeps the id already set
 	Create(Conds, EntityPtr) error
 	Search(Query) Result
 	One(Conds, EntityPtr) error
@@ -20,3 +27,30 @@ type Store interface {
 	// service is using
 	Close() error
 }
+
+// ContextStore defines the context-aware interface of an entity service.
+//
+// Operations should abort and return the context error when the
+// context is done (e.g. request cancelled or deadline exceeded).
+// Aborting only stops the waiting: the query sent to the database is
+// not necessarily cancelled. Stores wrapped by NewContextStore leave
+// the query running to its end (see NewContextStore)
+type ContextStore interface {
+	// Basic entity operations
+	CreateContext(context.Context, Conds, EntityPtr) error
+	SearchContext(context.Context, Query) Result
+	OneContext(context.Context, Conds, EntityPtr) error
+	UpdateContext(context.Context, Conds, EntityPtr) error
+	DeleteContext(context.Context, Conds) error
+
+	// Memory allocation
+	AllocEntity() EntityPtr
+	AllocEntityList() EntityListPtr
+
+	// Helper
+	Len(EntityListPtr) int64
+
+	// Close the database session this
+	// service is using
+	Close() error
+}
@@ -0,0 +1,121 @@
+// Package storetest contains tests of the behaviours common to all
+// store.Store implementations. Tests of each implementation run them
+// against its stores, so the implementations behave the same
+package storetest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gourd/kit/store"
+)
+
+// Entity is the entity of the stores to test. Stores of SQL
+// database should be of a table of the columns:
+//
+//	id text PRIMARY KEY, name text, version integer
+type Entity struct {
+	ID      string `db:"id,omitempty"`
+	Name    string `db:"name"`
+	Version int64  `db:"version" gourdupdate:"version"`
+}
+
+// NewStore returns a Store of Entity in an empty collection
+type NewStore func(t *testing.T) store.Store
+
+// create creates the entity in the store
+func create(t *testing.T, s store.Store, e *Entity) {
+	if err := s.Create(nil, e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+}
+
+// read returns the entity of the id in the store
+func read(t *testing.T, s store.Store, id string) *Entity {
+	e := &Entity{}
+	if err := s.One(store.NewConds().Add("id", id), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	return e
+}
+
+// CreateID tests the id of entity created (see store.Store.Create).
+// Entities of empty id get a new and distinct id, while the id
+// already set is kept
+func CreateID(t *testing.T, newStore NewStore) {
+	s := newStore(t)
+	e1, e2 := &Entity{Name: "foo"}, &Entity{Name: "bar"}
+	create(t, s, e1)
+	create(t, s, e2)
+	if e1.ID == "" || e2.ID == "" {
+		t.Errorf("expected ids generated, got %#v and %#v", e1.ID, e2.ID)
+	}
+	if e1.ID == e2.ID {
+		t.Errorf("expected distinct ids, got %#v twice", e1.ID)
+	}
+	if want, have := "bar", read(t, s, e2.ID).Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	e := &Entity{ID: "given", Name: "baz"}
+	create(t, s, e)
+	if want, have := "given", e.ID; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "baz", read(t, s, "given").Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+// UpdateVersion tests Update of entity with version field (see
+// store.VersionProp). Conditions matching more than one entity
+// are rejected, and the version of the entity matched is checked
+// and incremented
+func UpdateVersion(t *testing.T, newStore NewStore) {
+	s := newStore(t)
+	e1, e2 := &Entity{Name: "foo"}, &Entity{Name: "foo"}
+	create(t, s, e1)
+	create(t, s, e2)
+
+	// conditions matching more than one entity
+	err := s.Update(store.NewConds().Add("name", "foo"), &Entity{Name: "bar"})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if want, have := http.StatusBadRequest, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	for _, id := range []string{e1.ID, e2.ID} {
+		if want, have := "foo", read(t, s, id).Name; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+
+	// both based on version 0, only the first succeeds
+	c := store.NewConds().Add("id", e1.ID)
+	e := &Entity{ID: e1.ID, Name: "bar"}
+	if err := s.Update(c, e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := int64(1), e.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	err = s.Update(c, &Entity{ID: e1.ID, Name: "baz"})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if want, have := http.StatusConflict, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	found := read(t, s, e1.ID)
+	if want, have := "bar", found.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := int64(1), found.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := int64(0), read(t, s, e2.ID).Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
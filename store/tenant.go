@@ -0,0 +1,275 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+type tenantKeys int
+
+const (
+	tenantKey tenantKeys = iota
+)
+
+// WithTenant attaches the tenant id to the context
+// for stores obtained by Get (see NewTenantFactory)
+func WithTenant(parent context.Context, tenant interface{}) context.Context {
+	return context.WithValue(parent, tenantKey, tenant)
+}
+
+// TenantOf returns the tenant id in the context, if any
+func TenantOf(ctx context.Context) (tenant interface{}, ok bool) {
+	tenant = ctx.Value(tenantKey)
+	ok = tenant != nil
+	return
+}
+
+// Tenancy defines how stores are scoped by tenant
+type Tenancy struct {
+
+	// Prop is the property of tenant id of entities. Conditions
+	// of the tenant id in the context are added to every Search,
+	// One, Update, Delete, UpdateMany and Upsert, and the tenant id
+	// is set to entities to Create, Update, CreateMany or Upsert.
+	// Get fails with 500 StoreError if the entity of the store has
+	// no such property, unless the store is Shared. Empty Prop
+	// means no condition added
+	Prop string
+
+	// Shared, if not nil, tells if the store of the store key is
+	// shared by all tenants (e.g. store of the tenants themselves),
+	// so it is not scoped by Prop
+	Shared func(key interface{}) bool
+
+	// Source, if not nil, returns the source key of the tenant
+	// to route stores to, instead of the source key of the store
+	// key (see Factory.Set). The source of the source key should
+	// be set to the Factory (see Factory.SetSource).
+	//
+	// Results cached (see CacheFactory) are keyed by the tenant,
+	// so tenants of different sources do not share them
+	Source func(tenant interface{}) (srcKey interface{})
+}
+
+// NewTenantFactory returns the factory with stores obtained through
+// Get scoped by the tenant id in the context (see WithTenant).
+// Get fails with 500 StoreError if there is no tenant in context
+//
+// Hooks, cache and others of the optional interfaces of Factory
+// (e.g. HookFactory) should be set to the factory wrapped
+func NewTenantFactory(factory Factory, tenancy Tenancy) Factory {
+	return &tenantFactory{Factory: factory, tenancy: tenancy}
+}
+
+// tenantFactory implements Factory with tenancy
+type tenantFactory struct {
+	Factory
+	tenancy Tenancy
+}
+
+// tenantOf returns the tenant in the context, or error if none
+func tenantOf(ctx context.Context) (tenant interface{}, err error) {
+	tenant, ok := TenantOf(ctx)
+	if !ok {
+		err = Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("tenant not found in context")
+	}
+	return
+}
+
+// source returns the source key of the tenant in the context
+func (f *tenantFactory) source(ctx context.Context, srcKey interface{}) (interface{}, error) {
+	if f.tenancy.Source == nil {
+		return srcKey, nil
+	}
+	tenant, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return f.tenancy.Source(tenant), nil
+}
+
+// scope returns the Store of the store key scoped by the tenant
+// in the context
+func (f *tenantFactory) scope(ctx context.Context, key interface{}, s Store) (Store, error) {
+	tenant, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if f.tenancy.Prop == "" || (f.tenancy.Shared != nil && f.tenancy.Shared(key)) {
+		return s, nil
+	}
+	entity := reflect.ValueOf(s.AllocEntity())
+	for entity.Kind() == reflect.Ptr {
+		entity = entity.Elem()
+	}
+	if entity.Kind() == reflect.Struct {
+		if _, ok := propField(entity, f.tenancy.Prop); ok {
+			return &tenantStore{Store: s, prop: f.tenancy.Prop, tenant: tenant}, nil
+		}
+	}
+	return nil, Error(http.StatusInternalServerError,
+		http.StatusText(http.StatusInternalServerError)).
+		TellServer("tenant property %#v not found in entity %T of store %v",
+			f.tenancy.Prop, s.AllocEntity(), key)
+}
+
+// cacheKey returns the namespace of cache keys of the tenant in
+// the context, if stores of tenants are routed to their sources
+func (f *tenantFactory) cacheKey(ctx context.Context) string {
+	if f.tenancy.Source == nil {
+		return ""
+	}
+	tenant, _ := TenantOf(ctx)
+	return fmt.Sprintf("tenant %#v ", tenant)
+}
+
+// tenantStore implements Store scoped by a tenant.
+//
+// It implements BulkStore, UpsertStore and ContextStore with the
+// native operations of the wrapped Store, if any
+type tenantStore struct {
+	Store
+	prop   string
+	tenant interface{}
+}
+
+// conds returns the conditions with the tenant condition
+func (s *tenantStore) conds(c Conds) Conds {
+	cs := NewConds()
+	if c != nil && len(c.GetAll()) > 0 {
+		cs.Add("", c)
+	}
+	return cs.Add(s.prop, s.tenant)
+}
+
+// query returns a copy of the query with the tenant condition
+func (s *tenantStore) query(q Query) Query {
+	if q == nil {
+		q = NewQuery()
+	}
+	if bq, ok := q.(*BasicQuery); ok {
+		cp := *bq // leave the query of the caller as is
+		q = &cp
+	}
+	return q.SetConds(s.conds(q.GetConds()))
+}
+
+// stamp sets the tenant id to the entity
+func (s *tenantStore) stamp(ep EntityPtr) error {
+	val := reflect.ValueOf(ep)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	return setProp(val.Elem(), s.prop, s.tenant)
+}
+
+// Create implements Store
+func (s *tenantStore) Create(c Conds, ep EntityPtr) (err error) {
+	if err = s.stamp(ep); err != nil {
+		return
+	}
+	return s.Store.Create(c, ep)
+}
+
+// Search implements Store
+func (s *tenantStore) Search(q Query) Result {
+	return s.Store.Search(s.query(q))
+}
+
+// One implements Store
+func (s *tenantStore) One(c Conds, ep EntityPtr) error {
+	return s.Store.One(s.conds(c), ep)
+}
+
+// Update implements Store
+func (s *tenantStore) Update(c Conds, ep EntityPtr) (err error) {
+	if err = s.stamp(ep); err != nil {
+		return
+	}
+	return s.Store.Update(s.conds(c), ep)
+}
+
+// Delete implements Store
+func (s *tenantStore) Delete(c Conds) error {
+	return s.Store.Delete(s.conds(c))
+}
+
+// CreateMany implements BulkStore
+func (s *tenantStore) CreateMany(c Conds, el EntityListPtr) (err error) {
+	list, err := bulkList(el)
+	if err != nil {
+		return
+	}
+	for i := 0; i < list.Len(); i++ {
+		if err = s.stamp(list.Index(i).Addr().Interface()); err != nil {
+			return
+		}
+	}
+	return CreateMany(s.Store, c, el)
+}
+
+// UpdateMany implements BulkStore. The tenant
+// property in the fields is set to the tenant
+func (s *tenantStore) UpdateMany(c Conds, fields map[string]interface{}) error {
+	scoped := make(map[string]interface{}, len(fields))
+	for name, v := range fields {
+		scoped[name] = v
+	}
+	if _, ok := scoped[s.prop]; ok {
+		scoped[s.prop] = s.tenant
+	}
+	return UpdateMany(s.Store, s.conds(c), scoped)
+}
+
+// Upsert implements UpsertStore. The tenant condition is added to
+// the equality conditions, so the unique field set of native upsert
+// (see UpsertStore) should include the tenant property
+func (s *tenantStore) Upsert(c Conds, ep EntityPtr) (err error) {
+	if err = s.stamp(ep); err != nil {
+		return
+	}
+	if c == nil || c.GetRel() != And {
+		return Upsert(s.Store, s.conds(c), ep)
+	}
+	cs := NewConds()
+	for _, cond := range c.GetAll() {
+		cs.AddOp(cond.Prop, cond.Op, cond.Value)
+	}
+	return Upsert(s.Store, cs.Add(s.prop, s.tenant), ep)
+}
+
+// CreateContext implements ContextStore
+func (s *tenantStore) CreateContext(ctx context.Context, c Conds, ep EntityPtr) (err error) {
+	if err = s.stamp(ep); err != nil {
+		return
+	}
+	return NewContextStore(s.Store).CreateContext(ctx, c, ep)
+}
+
+// SearchContext implements ContextStore
+func (s *tenantStore) SearchContext(ctx context.Context, q Query) Result {
+	return NewContextStore(s.Store).SearchContext(ctx, s.query(q))
+}
+
+// OneContext implements ContextStore
+func (s *tenantStore) OneContext(ctx context.Context, c Conds, ep EntityPtr) error {
+	return NewContextStore(s.Store).OneContext(ctx, s.conds(c), ep)
+}
+
+// UpdateContext implements ContextStore
+func (s *tenantStore) UpdateContext(ctx context.Context, c Conds, ep EntityPtr) (err error) {
+	if err = s.stamp(ep); err != nil {
+		return
+	}
+	return NewContextStore(s.Store).UpdateContext(ctx, s.conds(c), ep)
+}
+
+// DeleteContext implements ContextStore
+func (s *tenantStore) DeleteContext(ctx context.Context, c Conds) error {
+	return NewContextStore(s.Store).DeleteContext(ctx, s.conds(c))
+}
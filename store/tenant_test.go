@@ -0,0 +1,361 @@
+package store_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+	"golang.org/x/net/context"
+)
+
+type tenantKey int
+
+const (
+	tenantStore tenantKey = iota
+	plainTenantStore
+	tenantSrcA
+	tenantSrcB
+)
+
+type tenantEntity struct {
+	ID     string `db:"id,omitempty"`
+	Tenant string `db:"tenant"`
+	Name   string `db:"name"`
+}
+
+// tenantContext returns a context of the tenant
+// with the factory for tenant stores
+func tenantContext(factory store.Factory, tenant string) context.Context {
+	return store.WithTenant(store.WithFactory(context.Background(), factory), tenant)
+}
+
+func TestTenantOf(t *testing.T) {
+	if _, ok := store.TenantOf(context.Background()); ok {
+		t.Errorf("expected no tenant")
+	}
+	tenant, ok := store.TenantOf(store.WithTenant(context.Background(), "acme"))
+	if !ok {
+		t.Fatalf("expected tenant")
+	}
+	if want, have := "acme", tenant; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestNewTenantFactory_noTenant(t *testing.T) {
+	factory := store.NewTenantFactory(store.NewFactory(), store.Tenancy{Prop: "tenant"})
+	factory.SetSource(store.DefaultSrc, memstore.NewSource())
+	factory.Set(tenantStore, store.DefaultSrc, memstore.Provider("entity", &tenantEntity{}))
+
+	ctx := store.WithFactory(context.Background(), factory)
+	defer store.CloseAllIn(ctx)
+	_, err := store.Get(ctx, tenantStore)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	serr, ok := err.(*store.StoreError)
+	if !ok {
+		t.Fatalf("expected StoreError, got %#v", err)
+	}
+	if want, have := http.StatusInternalServerError, serr.Code; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestNewTenantFactory_prop(t *testing.T) {
+	factory := store.NewTenantFactory(store.NewFactory(), store.Tenancy{Prop: "tenant"})
+	factory.SetSource(store.DefaultSrc, memstore.NewSource())
+	factory.Set(tenantStore, store.DefaultSrc, memstore.Provider("entity", &tenantEntity{}))
+
+	// create entities as different tenants
+	for _, tenant := range []string{"a", "b"} {
+		ctx := tenantContext(factory, tenant)
+		s, err := store.Get(ctx, tenantStore)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		for _, id := range []string{"1", "2"} {
+			// tenant of the entity is overridden
+			e := &tenantEntity{ID: tenant + id, Tenant: "other", Name: "name"}
+			if err := s.Create(nil, e); err != nil {
+				t.Fatalf("unexpected error: %#v", err.Error())
+			}
+			if want, have := tenant, e.Tenant; want != have {
+				t.Errorf("expected %#v, got %#v", want, have)
+			}
+		}
+		store.CloseAllIn(ctx)
+	}
+
+	ctx := tenantContext(factory, "a")
+	defer store.CloseAllIn(ctx)
+	s, err := store.Get(ctx, tenantStore)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// Search only finds entities of the tenant
+	list := []tenantEntity{}
+	if err := s.Search(store.NewQuery().SetConds(
+		store.NewConds().Add("name", "name"))).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(list); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	for _, e := range list {
+		if want, have := "a", e.Tenant; want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+	}
+
+	// One does not find entity of other tenant
+	e := &tenantEntity{}
+	if err := s.One(store.NewConds().Add("id", "b1"), e); err == nil {
+		t.Errorf("expected error, got %#v", e)
+	}
+
+	// Update and Delete do not touch entity of other tenant
+	if err := s.Update(store.NewConds().Add("id", "b1"),
+		&tenantEntity{ID: "b1", Name: "updated"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.Delete(store.NewConds().Add("name", "name")); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	ctxB := tenantContext(factory, "b")
+	defer store.CloseAllIn(ctxB)
+	sb, _ := store.Get(ctxB, tenantStore)
+	if want, have := "name", readTenant(t, sb, "b1").Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	list = []tenantEntity{}
+	if err := sb.Search(store.NewQuery()).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(list); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestNewTenantFactory_noProp(t *testing.T) {
+	factory := store.NewTenantFactory(store.NewFactory(), store.Tenancy{Prop: "tenant"})
+	factory.SetSource(store.DefaultSrc, memstore.NewSource())
+	factory.Set(plainTenantStore, store.DefaultSrc, memstore.Provider("entity", &bulkEntity{}))
+
+	// stores of entity without the prop are not obtained
+	ctx := tenantContext(factory, "a")
+	defer store.CloseAllIn(ctx)
+	_, err := store.Get(ctx, plainTenantStore)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if want, have := http.StatusInternalServerError, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestNewTenantFactory_misspeltProp(t *testing.T) {
+	factory := store.NewTenantFactory(store.NewFactory(), store.Tenancy{Prop: "tenat"})
+	factory.SetSource(store.DefaultSrc, memstore.NewSource())
+	factory.Set(tenantStore, store.DefaultSrc, memstore.Provider("entity", &tenantEntity{}))
+
+	ctx := tenantContext(factory, "a")
+	defer store.CloseAllIn(ctx)
+	s, err := store.Get(ctx, tenantStore)
+	if err == nil {
+		t.Fatalf("expected error, got store %#v", s)
+	}
+	if want, have := http.StatusInternalServerError, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestNewTenantFactory_shared(t *testing.T) {
+	factory := store.NewTenantFactory(store.NewFactory(), store.Tenancy{
+		Prop: "tenant",
+		Shared: func(key interface{}) bool {
+			return key == plainTenantStore
+		},
+	})
+	factory.SetSource(store.DefaultSrc, memstore.NewSource())
+	factory.Set(plainTenantStore, store.DefaultSrc, memstore.Provider("entity", &bulkEntity{}))
+
+	// shared stores are not scoped
+	for _, tenant := range []string{"a", "b"} {
+		ctx := tenantContext(factory, tenant)
+		s, err := store.Get(ctx, plainTenantStore)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if err := s.Create(nil, &bulkEntity{ID: tenant}); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		store.CloseAllIn(ctx)
+	}
+
+	ctx := tenantContext(factory, "a")
+	defer store.CloseAllIn(ctx)
+	s, _ := store.Get(ctx, plainTenantStore)
+	list := []bulkEntity{}
+	if err := s.Search(store.NewQuery()).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(list); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestNewTenantFactory_source(t *testing.T) {
+	factory := store.NewTenantFactory(store.NewFactory(), store.Tenancy{
+		Source: func(tenant interface{}) interface{} {
+			if tenant == "a" {
+				return tenantSrcA
+			}
+			return tenantSrcB
+		},
+	})
+	factory.SetSource(tenantSrcA, memstore.NewSource())
+	factory.SetSource(tenantSrcB, memstore.NewSource())
+	factory.Set(tenantStore, store.DefaultSrc, memstore.Provider("entity", &tenantEntity{}))
+
+	ctxA := tenantContext(factory, "a")
+	defer store.CloseAllIn(ctxA)
+	sa, err := store.Get(ctxA, tenantStore)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := sa.Create(nil, &tenantEntity{ID: "1", Name: "of a"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	ctxB := tenantContext(factory, "b")
+	defer store.CloseAllIn(ctxB)
+	sb, err := store.Get(ctxB, tenantStore)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := sb.One(store.NewConds().Add("id", "1"), &tenantEntity{}); err == nil {
+		t.Errorf("expected error")
+	}
+	if want, have := "of a", readTenant(t, sa, "1").Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestNewTenantFactory_sourceCache(t *testing.T) {
+	base := store.NewFactory()
+	factory := store.NewTenantFactory(base, store.Tenancy{
+		Source: func(tenant interface{}) interface{} {
+			if tenant == "a" {
+				return tenantSrcA
+			}
+			return tenantSrcB
+		},
+	})
+	factory.SetSource(tenantSrcA, memstore.NewSource())
+	factory.SetSource(tenantSrcB, memstore.NewSource())
+	factory.Set(tenantStore, store.DefaultSrc, memstore.Provider("entity", &tenantEntity{}))
+	base.(store.CacheFactory).SetCache(tenantStore, store.NewLRUCache(10, 0))
+
+	ctxA := tenantContext(factory, "a")
+	defer store.CloseAllIn(ctxA)
+	sa, err := store.Get(ctxA, tenantStore)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := sa.Create(nil, &tenantEntity{ID: "1", Name: "of a"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	readTenant(t, sa, "1") // cached
+
+	// results cached for tenant a are not served to tenant b
+	ctxB := tenantContext(factory, "b")
+	defer store.CloseAllIn(ctxB)
+	sb, err := store.Get(ctxB, tenantStore)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := sb.One(store.NewConds().Add("id", "1"), &tenantEntity{}); err == nil {
+		t.Errorf("expected error")
+	}
+	list := []tenantEntity{}
+	if err := sb.Search(store.NewQuery()).All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 0, len(list); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+// readTenant returns the entity of the id read by the store
+func readTenant(t *testing.T, s store.Store, id string) *tenantEntity {
+	e := &tenantEntity{}
+	if err := s.One(store.NewConds().Add("id", id), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	return e
+}
+
+func TestNewTenantFactory_bulk(t *testing.T) {
+	factory := store.NewTenantFactory(store.NewFactory(), store.Tenancy{Prop: "tenant"})
+	factory.SetSource(store.DefaultSrc, memstore.NewSource())
+	factory.Set(tenantStore, store.DefaultSrc, memstore.Provider("entity", &tenantEntity{}))
+
+	// create entities as different tenants
+	for _, tenant := range []string{"a", "b"} {
+		ctx := tenantContext(factory, tenant)
+		s, err := store.Get(ctx, tenantStore)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		if err := store.CreateMany(s, nil, &[]tenantEntity{
+			{ID: tenant + "1", Tenant: "other", Name: "name"},
+		}); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		store.CloseAllIn(ctx)
+	}
+
+	ctx := tenantContext(factory, "a")
+	defer store.CloseAllIn(ctx)
+	s, err := store.Get(ctx, tenantStore)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "a", readTenant(t, s, "a1").Tenant; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// UpdateMany and Upsert do not touch entity of other tenant
+	if err := store.UpdateMany(s, store.NewConds().Add("name", "name"),
+		map[string]interface{}{"name": "updated", "tenant": "b"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := store.Upsert(s, store.NewConds().Add("id", "a1"),
+		&tenantEntity{Name: "upserted"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	e := readTenant(t, s, "a1")
+	if want, have := "a", e.Tenant; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "upserted", e.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// context operations are scoped
+	if err := s.(store.ContextStore).OneContext(context.Background(),
+		store.NewConds().Add("id", "b1"), &tenantEntity{}); err == nil {
+		t.Errorf("expected error")
+	}
+
+	ctxB := tenantContext(factory, "b")
+	defer store.CloseAllIn(ctxB)
+	sb, _ := store.Get(ctxB, tenantStore)
+	if want, have := "name", readTenant(t, sb, "b1").Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
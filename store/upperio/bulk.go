@@ -0,0 +1,70 @@
+package upperio
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/gourd/kit/store"
+	"upper.io/db.v1"
+)
+
+// CreateMany appends all entities of the list to the collection. The
+// el is a pointer to slice of entities. Entities implementing
+// db.Marshaler are marshaled before append.
+//
+// Returns store.BulkError if any of the entities failed. To commit
+// all entities at once, run it in a transaction (see store.Begin)
+func CreateMany(coll db.Collection, el interface{}) error {
+	list := reflect.ValueOf(el)
+	if list.Kind() != reflect.Ptr || list.IsNil() || list.Elem().Kind() != reflect.Slice {
+		return store.Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("expected pointer to slice, got %#v", el)
+	}
+	list = list.Elem()
+
+	errs := make(store.BulkError, list.Len())
+	failed := false
+	for i := 0; i < list.Len(); i++ {
+		var item interface{} = list.Index(i).Addr().Interface()
+
+		// Marshal the item, if possible
+		// (quick fix for upperio problem with db.Marshaler)
+		var err error
+		if me, ok := item.(db.Marshaler); ok {
+			item, err = me.MarshalDB()
+		}
+		if err == nil {
+			_, err = coll.Append(item)
+		}
+		if err != nil {
+			errs[i] = store.Error(http.StatusInternalServerError,
+				http.StatusText(http.StatusInternalServerError)).
+				TellServer("error creating item %d: %s", i, err)
+			failed = true
+		}
+	}
+	if failed {
+		return errs
+	}
+	return nil
+}
+
+// UpdateMany sets the fields (column name to value) of all
+// entities matching the conditions in a single update
+func UpdateMany(coll db.Collection, c store.Conds, fields map[string]interface{}) (err error) {
+	if len(fields) == 0 {
+		return
+	}
+
+	res, err := find(coll, c)
+	if err != nil {
+		return
+	}
+	if err = res.Update(fields); err != nil {
+		err = store.Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("error updating entities: %s", err)
+	}
+	return
+}
@@ -0,0 +1,68 @@
+package upperio_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/upperio"
+	"upper.io/db.v1"
+)
+
+func TestBulk(t *testing.T) {
+
+	fn := "./test8.tmp"
+	defer os.Remove(fn)
+
+	source := upperio.NewSource(testUpperDb(fn))
+	if err := testUpperDbData(source); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	conn, err := source.Open()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	coll, err := conn.Raw().(db.Database).Collection("dummy_data")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// create many
+	el := &[]testData{
+		{HelloWorld: "bulk 1", FooBar: "bulk", Data: "something"},
+		{HelloWorld: "bulk 2", FooBar: "bulk", Data: "something"},
+	}
+	if err := upperio.CreateMany(coll, el); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if n, err := coll.Find(db.Cond{"FooBar": "bulk"}).Count(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	} else if want, have := uint64(2), n; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// update many
+	err = upperio.UpdateMany(coll, store.NewConds().Add("FooBar", "bulk"),
+		map[string]interface{}{"Data": "updated"})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if n, err := coll.Find(db.Cond{"Data": "updated"}).Count(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	} else if want, have := uint64(2), n; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if n, err := coll.Find(db.Cond{"Data": "something"}).Count(); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	} else if want, have := uint64(1), n; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// not a list
+	if err := upperio.CreateMany(coll, &testData{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
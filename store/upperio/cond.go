@@ -4,12 +4,29 @@ import (
 	"github.com/gourd/kit/store"
 
 	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"unicode/utf8"
 	"upper.io/db.v1"
 )
 
 // Conds Translate the store.Conds interface into
-// upperio flavor conditions representation
+// upperio flavor conditions representation.
+// Panics if the conditions are malformed (see TranslateConds)
 func Conds(cs store.Conds) interface{} {
+	conds, err := TranslateConds(cs)
+	if err != nil {
+		panic(err.Error())
+	}
+	return conds
+}
+
+// TranslateConds translates the store.Conds interface into
+// upperio flavor conditions representation. Returns 400
+// StoreError if any of the conditions is malformed (e.g.
+// unknown operator or Between without 2 values)
+func TranslateConds(cs store.Conds) (interface{}, error) {
 	conds := cs.GetAll()
 	out := make([]interface{}, 0)
 
@@ -20,7 +37,10 @@ func Conds(cs store.Conds) interface{} {
 			if v, ok := cond.Value.(string); ok {
 				out = append(out, db.Raw{v})
 			} else if v, ok := cond.Value.(store.Conds); ok {
-				leaf := Conds(v)
+				leaf, err := TranslateConds(v)
+				if err != nil {
+					return nil, err
+				}
 				out = append(out, leaf)
 			} else if v, ok := cond.Value.(db.Raw); ok {
 				out = append(out, v)
@@ -30,22 +50,92 @@ func Conds(cs store.Conds) interface{} {
 				out = append(out, v)
 			}
 		} else {
-			out = append(out, db.Cond{cond.Prop: cond.Value})
+			leaf, err := opCond(cond)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, leaf)
 		}
 	}
 
 	if len(out) == 0 {
-		return nil // nil for empty query, searchs everything
+		return nil, nil // nil for empty query, searchs everything
 	}
 
 	// determine relations
 	if cs.GetRel() == store.And {
-		return db.And(out)
+		return db.And(out), nil
 	} else if cs.GetRel() == store.Or {
-		return db.Or(out)
+		return db.Or(out), nil
 	}
 
-	panic(fmt.Sprintf("Incorrect value of Rel in %#v", cs))
-	return nil
+	return nil, store.Error(http.StatusInternalServerError,
+		http.StatusText(http.StatusInternalServerError)).
+		TellServer("Incorrect value of Rel in %#v", cs)
+}
 
+// propName matches the property names which could be
+// used in raw SQL as is (e.g. "deleted_at" or "t.deleted_at")
+var propName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// condError returns 400 StoreError of malformed condition
+func condError(msg string, v ...interface{}) error {
+	return store.Error(http.StatusBadRequest, msg, v...)
+}
+
+// opCond translates a store.Cond with property into
+// upperio flavor condition according to its operator
+func opCond(cond store.Cond) (interface{}, error) {
+	switch cond.Op {
+	case store.Eq:
+		return db.Cond{cond.Prop: cond.Value}, nil
+	case store.Ne, store.Gt, store.Gte, store.Lt, store.Lte,
+		store.In, store.NotIn, store.Like:
+		return db.Cond{cond.Prop + " " + cond.Op.String(): cond.Value}, nil
+	case store.Prefix:
+		return prefixCond(cond.Prop, fmt.Sprintf("%s", cond.Value)), nil
+	case store.IsNull, store.NotNull:
+		if !propName.MatchString(cond.Prop) {
+			return nil, condError("invalid property name %#v", cond.Prop)
+		}
+		return db.Raw{Value: cond.Prop + " " + cond.Op.String()}, nil
+	case store.Between:
+		v := reflect.ValueOf(cond.Value)
+		if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Len() != 2 {
+			return nil, condError("BETWEEN expects 2 values, got %#v", cond.Value)
+		}
+		return db.And{
+			db.Cond{cond.Prop + " >=": v.Index(0).Interface()},
+			db.Cond{cond.Prop + " <=": v.Index(1).Interface()},
+		}, nil
+	}
+
+	return nil, condError("unknown operator %s of %#v", cond.Op, cond.Prop)
+}
+
+// prefixCond returns the condition of property starting with
+// the prefix. It is the range from the prefix (inclusive) to the
+// least string greater than all strings of the prefix (exclusive),
+// so "%" and "_" in the prefix are not taken as LIKE wildcards.
+//
+// The range follows the byte order of strings, so the column has
+// to be compared with a binary collation (the default of SQLite
+// and of PostgreSQL "C" locale). With a case insensitive or locale
+// aware collation (e.g. MySQL utf8_general_ci) the range misses or
+// includes strings that do not share the prefix byte by byte; use
+// Like with an escaped pattern for such columns instead
+func prefixCond(prop, prefix string) interface{} {
+	from := db.Cond{prop + " >=": prefix}
+	for end := prefix; end != ""; {
+		r, size := utf8.DecodeLastRuneInString(end)
+		end = end[:len(end)-size]
+		if r == utf8.MaxRune || r == utf8.RuneError {
+			continue // carry to the previous rune
+		}
+		if r++; r >= 0xD800 && r <= 0xDFFF {
+			r = 0xE000 // skip surrogates
+		}
+		return db.And{from, db.Cond{prop + " <": end + string(r)}}
+	}
+	return from
 }
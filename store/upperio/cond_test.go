@@ -120,3 +120,157 @@ func TestConds_branching(t *testing.T) {
 	}
 
 }
+
+func TestConds_ops(t *testing.T) {
+	q := store.NewQuery().
+		AddCondOp("age", store.Gte, 18).
+		AddCondOp("status", store.NotIn, []string{"a", "b"}).
+		AddCondOp("name", store.Prefix, "jo").
+		AddCondOp("deleted", store.IsNull, nil).
+		AddCondOp("created", store.Between, []int{1, 2})
+
+	and, ok := upperio.Conds(q.GetConds()).(db.And)
+	if !ok {
+		t.Fatalf("expected db.And, got %#v", upperio.Conds(q.GetConds()))
+	}
+	if want, have := 5, len(and); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+
+	if cond, ok := and[0].(db.Cond); !ok {
+		t.Errorf("expected db.Cond, got %#v", and[0])
+	} else if want, have := 18, cond["age >="]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if cond, ok := and[1].(db.Cond); !ok {
+		t.Errorf("expected db.Cond, got %#v", and[1])
+	} else if _, ok := cond["status NOT IN"]; !ok {
+		t.Errorf("expected key \"status NOT IN\", got %#v", cond)
+	}
+
+	if prefix, ok := and[2].(db.And); !ok || len(prefix) != 2 {
+		t.Errorf("expected db.And of 2 conditions, got %#v", and[2])
+	} else if want, have := "jo", prefix[0].(db.Cond)["name >="]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	} else if want, have := "jp", prefix[1].(db.Cond)["name <"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if raw, ok := and[3].(db.Raw); !ok {
+		t.Errorf("expected db.Raw, got %#v", and[3])
+	} else if want, have := "deleted IS NULL", raw.Value; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if between, ok := and[4].(db.And); !ok {
+		t.Errorf("expected db.And, got %#v", and[4])
+	} else if want, have := 2, len(between); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestTranslateConds_malformed(t *testing.T) {
+	for i, conds := range []store.Conds{
+		store.NewConds().AddOp("created", store.Between, []int{1}),
+		store.NewConds().AddOp("created", store.Between, 1),
+		store.NewConds().AddOp("created", store.Op(99), 1),
+		store.NewConds().AddOp("deleted OR 1=1", store.IsNull, nil),
+		store.NewConds().Add("", store.NewConds().AddOp("deleted;", store.NotNull, nil)),
+	} {
+		_, err := upperio.TranslateConds(conds)
+		if err == nil {
+			t.Errorf("[%d] expected error", i)
+			continue
+		}
+		if want, have := 400, store.ExpandError(err).Status; want != have {
+			t.Errorf("[%d] expected %#v, got %#v", i, want, have)
+		}
+	}
+}
+
+func TestConds_prefix(t *testing.T) {
+
+	fn := "./test5.tmp"
+	defer os.Remove(fn)
+
+	// test source
+	source := upperio.NewSource(testUpperDb(fn))
+	if err := testUpperDbData(source); err != nil {
+		t.Fatal(err.Error())
+	}
+	conn, err := source.Open()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+	coll, err := conn.Raw().(db.Database).Collection("dummy_data")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// wildcards in prefix are matched literally, and
+	// strings are compared by the binary collation of SQLite
+	for prefix, expLen := range map[string]int{
+		"something":  3,
+		"something ": 2,
+		"some_hing":  0,
+		"%":          0,
+		"":           3,
+		"Something":  0,
+		"SOMETHING":  0,
+	} {
+		q := store.NewQuery().AddCondOp("Data", store.Prefix, prefix)
+		var tds []testData
+		coll.Find(upperio.Conds(q.GetConds())).All(&tds)
+		if want, have := expLen, len(tds); want != have {
+			t.Errorf("prefix %#v: expected %#v, got %#v", prefix, want, have)
+		}
+	}
+}
+
+func TestConds_opsQuery(t *testing.T) {
+
+	var err error
+
+	fn := "./test4.tmp"
+
+	q := store.NewQuery().
+		AddCondOp("HelloWorld", store.In, []string{"foo bar", "foo bar 3"}).
+		AddCondOp("Data", store.Prefix, "something")
+
+	// test source
+	source := upperio.NewSource(testUpperDb(fn))
+
+	// add dummy data to the database
+	if err := testUpperDbData(source); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// connect to database again
+	conn, err := source.Open()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer conn.Close()
+
+	// query connection
+	sess := conn.Raw().(db.Database)
+	coll, err := sess.Collection("dummy_data")
+	res := coll.Find(upperio.Conds(q.GetConds()))
+	var tds []testData
+	res.All(&tds)
+
+	expLen := 2
+	if l := len(tds); l != expLen {
+		t.Errorf("result set size expected: %d, got: %d\ntest data set:\t%#v",
+			expLen, l, tds)
+	}
+
+	// clean up the temp database
+	err = os.Remove(fn)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+}
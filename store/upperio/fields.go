@@ -0,0 +1,44 @@
+package upperio
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gourd/kit/store"
+	"upper.io/db.v1"
+)
+
+// Fields take a store query and returns upperio Select usable
+// column list of the selected fields. Returns nil if the query
+// selects no field (i.e. all columns)
+func Fields(q store.Query) (res []interface{}) {
+	fields := q.GetFields()
+	if len(fields) == 0 {
+		return
+	}
+	res = make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		res = append(res, field)
+	}
+	return
+}
+
+// Aggregate take a store query and returns upperio Group usable
+// properties and Select usable columns of the aggregation. Each
+// aggregate is selected as a SQL aggregate function named by
+// store.Aggregate.Name (e.g. "SUM(amount) AS sum_amount")
+func Aggregate(q store.Query) (groupBy, cols []interface{}) {
+	for _, prop := range q.GetGroupBy() {
+		groupBy = append(groupBy, prop)
+		cols = append(cols, prop)
+	}
+	for _, agg := range q.GetAggregates() {
+		prop := agg.Prop
+		if prop == "" {
+			prop = "*"
+		}
+		cols = append(cols, db.Raw{Value: fmt.Sprintf("%s(%s) AS %s",
+			strings.ToUpper(agg.Func.String()), prop, agg.Name())})
+	}
+	return
+}
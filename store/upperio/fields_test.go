@@ -0,0 +1,49 @@
+package upperio_test
+
+import (
+	"testing"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/upperio"
+	"upper.io/db.v1"
+)
+
+func TestFields(t *testing.T) {
+	if res := upperio.Fields(store.NewQuery()); res != nil {
+		t.Errorf("expected nil, got %#v", res)
+	}
+
+	res := upperio.Fields(store.NewQuery().Select("id", "name"))
+	if want, have := 2, len(res); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "id", res[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "name", res[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	q := store.NewQuery().GroupBy("status").
+		Aggregate(store.AggCount, "").
+		Aggregate(store.AggSum, "amount")
+
+	groupBy, cols := upperio.Aggregate(q)
+	if want, have := 1, len(groupBy); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "status", groupBy[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 3, len(cols); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (db.Raw{Value: "COUNT(*) AS count"}), cols[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (db.Raw{Value: "SUM(amount) AS sum_amount"}), cols[2]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
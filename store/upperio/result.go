@@ -1,17 +1,49 @@
 package upperio
 
 import (
+	"net/http"
+	"reflect"
+
 	"github.com/gourd/kit/store"
 	"upper.io/db.v1"
 )
 
+// NewResult returns store.Result of the db.Result provided by fn
 func NewResult(fn func() (db.Result, error)) store.Result {
-	return &Result{fn}
+	return &Result{resultFunc: fn}
+}
+
+// NewQueryResult returns store.Result of the db.Result provided by fn
+// for the query. If the query has a cursor before a position, the
+// entities fetched (in reversed sorting) would be reversed back
+// to the query sorting. If the query selects fields, only the
+// columns of the fields would be fetched
+func NewQueryResult(q store.Query, fn func() (db.Result, error)) store.Result {
+	c := q.GetCursor()
+	return &Result{
+		resultFunc: fn,
+		reverse:    c != nil && c.Before,
+		fields:     Fields(q),
+		query:      q,
+	}
 }
 
 // Result implements store.Result
 type Result struct {
 	resultFunc func() (db.Result, error)
+	reverse    bool
+	fields     []interface{}
+	query      store.Query
+
+	// opened keeps all db.Result opened by resultFunc
+	// so they could be closed by Close
+	opened []db.Result
+
+	// iteration states of Next
+	iter db.Result
+	buf  reflect.Value
+	err  error
+	done bool
 }
 
 // All fetches all results within the result set and dumps them into the
@@ -24,22 +56,44 @@ func (res *Result) All(el interface{}) (err error) {
 
 	err = raw.All(el)
 	if err != nil {
-		serr := store.ErrorInternal
-		serr.ServerMsg = err.Error()
-		err = serr
+		err = store.ErrorInternal.TellServer("%s", err).Wrap(err)
+		return
+	}
+
+	if res.reverse {
+		reverse(el)
 	}
 	return
 }
 
+// reverse reverses the slice the given pointer points to
+func reverse(el interface{}) {
+	list := reflect.ValueOf(el)
+	for list.Kind() == reflect.Ptr && !list.IsNil() {
+		list = list.Elem()
+	}
+	if list.Kind() != reflect.Slice {
+		return
+	}
+	swap := reflect.New(list.Type().Elem()).Elem()
+	for i, j := 0, list.Len()-1; i < j; i, j = i+1, j-1 {
+		swap.Set(list.Index(i))
+		list.Index(i).Set(list.Index(j))
+		list.Index(j).Set(swap)
+	}
+}
+
 // raw returns raw db.Result, or error
 func (res *Result) raw() (raw db.Result, err error) {
 	raw, err = res.resultFunc()
 	if err != nil {
-		serr := store.ErrorInternal
-		serr.ServerMsg = err.Error()
-		err = serr
+		err = store.ErrorInternal.TellServer("%s", err).Wrap(err)
 		return
 	}
+	if len(res.fields) > 0 {
+		raw = raw.Select(res.fields...)
+	}
+	res.opened = append(res.opened, raw)
 	return
 }
 
@@ -52,22 +106,141 @@ func (res *Result) Raw() (interface{}, error) {
 func (res *Result) Count() (count uint64, err error) {
 	dbres, err := res.raw()
 	if err != nil {
-		serr := store.ErrorInternal
-		serr.ServerMsg = err.Error()
-		err = serr
+		err = store.ErrorInternal.TellServer("%s", err).Wrap(err)
 		return
 	}
 
 	count, err = dbres.Count()
 	if err != nil {
-		serr := store.ErrorInternal
-		serr.ServerMsg = err.Error()
-		err = serr
+		err = store.ErrorInternal.TellServer("%s", err).Wrap(err)
 	}
 	return
 }
 
-// Close closes the result set
-func (res *Result) Close() error {
-	return res.Close()
+// Aggregate groups the entities with db.Result.Group and computes
+// the aggregates with SQL aggregate functions (e.g. "SUM(amount) AS
+// sum_amount"). Only available to Result created by NewQueryResult.
+//
+// Limit, offset and sorts of the query apply to the rows
+func (res *Result) Aggregate() (rows []store.AggregateRow, err error) {
+	if res.query == nil {
+		err = store.Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("aggregation requires the query of result (see NewQueryResult)")
+		return
+	}
+
+	raw, err := res.raw()
+	if err != nil {
+		return
+	}
+
+	groupBy, cols := Aggregate(res.query)
+	if len(groupBy) > 0 {
+		raw = raw.Group(groupBy...)
+	}
+
+	var maps []map[string]interface{}
+	if err = raw.Select(cols...).All(&maps); err != nil {
+		err = store.Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("error aggregating: %s", err)
+		return
+	}
+
+	rows = make([]store.AggregateRow, 0, len(maps))
+	for _, m := range maps {
+		row := make(store.AggregateRow, len(m))
+		for k, v := range m {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			row[k] = v
+		}
+		rows = append(rows, row)
+	}
+	return
+}
+
+// Next fetches the next entity of the result set into the given
+// entity pointer with db.Result.Next. Returns false if there is
+// no more entity or there is error
+func (res *Result) Next(ep store.EntityPtr) bool {
+	if res.done {
+		return false
+	}
+
+	// entities before cursor are fetched in reversed sorting
+	// and have to be buffered to be iterated in query sorting
+	if res.reverse {
+		return res.nextBuffered(ep)
+	}
+
+	if res.iter == nil {
+		if res.iter, res.err = res.raw(); res.err != nil {
+			res.done = true
+			return false
+		}
+	}
+
+	if err := res.iter.Next(ep); err != nil {
+		res.done = true
+		if err != db.ErrNoMoreRows {
+			res.err = store.Error(http.StatusInternalServerError,
+				http.StatusText(http.StatusInternalServerError)).
+				TellServer("error fetching next entity: %s", err)
+		}
+		return false
+	}
+	return true
+}
+
+// nextBuffered fetches all entities on first call, then
+// sets the next buffered entity to the given entity pointer
+func (res *Result) nextBuffered(ep store.EntityPtr) bool {
+	ptr := reflect.ValueOf(ep)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		res.done = true
+		res.err = store.Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("expected entity pointer, got %#v", ep)
+		return false
+	}
+
+	if !res.buf.IsValid() {
+		list := reflect.New(reflect.SliceOf(ptr.Elem().Type()))
+		if res.err = res.All(list.Interface()); res.err != nil {
+			res.done = true
+			return false
+		}
+		res.buf = list.Elem()
+	}
+
+	if res.buf.Len() == 0 {
+		res.done = true
+		return false
+	}
+	ptr.Elem().Set(res.buf.Index(0))
+	res.buf = res.buf.Slice(1, res.buf.Len())
+	return true
+}
+
+// Err returns the error, if any, encountered in Next
+func (res *Result) Err() error {
+	return res.err
+}
+
+// Close closes all the underlying db.Result opened
+// by the Result
+func (res *Result) Close() (err error) {
+	for _, raw := range res.opened {
+		if cerr := raw.Close(); cerr != nil && err == nil {
+			err = store.Error(http.StatusInternalServerError,
+				http.StatusText(http.StatusInternalServerError)).
+				TellServer("error closing result: %s", cerr)
+		}
+	}
+	res.opened, res.iter, res.buf = nil, nil, reflect.Value{}
+	res.done = true
+	return
 }
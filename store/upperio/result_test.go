@@ -0,0 +1,121 @@
+package upperio_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/upperio"
+	"upper.io/db.v1"
+)
+
+func TestResult_Next(t *testing.T) {
+
+	fn := "./test6.tmp"
+	defer os.Remove(fn)
+
+	source := upperio.NewSource(testUpperDb(fn))
+	if err := testUpperDbData(source); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	conn, err := source.Open()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	coll, err := conn.Raw().(db.Database).Collection("dummy_data")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	iterate := func(q store.Query) (names []string) {
+		res := upperio.NewQueryResult(q, func() (db.Result, error) {
+			return coll.Find().Sort(upperio.Sort(q)...), nil
+		})
+		defer func() {
+			if err := res.Close(); err != nil {
+				t.Errorf("unexpected error: %#v", err.Error())
+			}
+		}()
+		for td := (&testData{}); res.Next(td); {
+			names = append(names, td.HelloWorld)
+		}
+		if err := res.Err(); err != nil {
+			t.Errorf("unexpected error: %#v", err.Error())
+		}
+		if res.Next(&testData{}) {
+			t.Errorf("expected no more entity")
+		}
+		return
+	}
+
+	names := iterate(store.NewQuery().Sort("HelloWorld"))
+	if want, have := "[foo bar foo bar 2 foo bar 3]", fmt.Sprintf("%v", names); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// entities before cursor are restored to the query sorting
+	names = iterate(store.NewQuery().Sort("HelloWorld").
+		SetCursor(&store.Cursor{Before: true}))
+	if want, have := "[foo bar foo bar 2 foo bar 3]", fmt.Sprintf("%v", names); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestResult_Aggregate(t *testing.T) {
+
+	fn := "./test7.tmp"
+	defer os.Remove(fn)
+
+	source := upperio.NewSource(testUpperDb(fn))
+	if err := testUpperDbData(source); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	conn, err := source.Open()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	coll, err := conn.Raw().(db.Database).Collection("dummy_data")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	q := store.NewQuery().GroupBy("Data").
+		Aggregate(store.AggCount, "").
+		Aggregate(store.AggMax, "HelloWorld").
+		Sort("Data")
+	res := upperio.NewQueryResult(q, func() (db.Result, error) {
+		return coll.Find().Sort(upperio.Sort(q)...), nil
+	})
+	defer res.Close()
+
+	rows, err := res.Aggregate()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 3, len(rows); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "something", rows[0]["Data"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := int64(1), rows[0]["count"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "foo bar", rows[0]["max_HelloWorld"]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// result without query
+	if _, err := upperio.NewResult(func() (db.Result, error) {
+		return coll.Find(), nil
+	}).Aggregate(); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"github.com/gourd/kit/store"
 )
 
-// Sort take a store query and returns upperio Sort usable parameter
+// Sort take a store query and returns upperio Sort usable parameter.
+// The sorting is reversed for query with cursor before a position
+// (see store.QuerySorts)
 func Sort(q store.Query) (res []interface{}) {
-	ss := q.GetSorts().GetAll()
+	ss := store.QuerySorts(q)
 	res = make([]interface{}, 0, len(ss))
 	for _, s := range ss {
 		res = append(res, s.String())
@@ -28,3 +28,20 @@ func TestSort(t *testing.T) {
 		t.Errorf("result[2] expected: %d, get: %d", expStr, res[2])
 	}
 }
+
+func TestSort_cursorBefore(t *testing.T) {
+	q := store.NewQuery()
+	q.GetSorts().Add("para1").Add("-para2")
+	q.SetCursor(&store.Cursor{Before: true})
+
+	res := upperio.Sort(q)
+	if want, have := 2, len(res); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "-para1", res[0]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "para2", res[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
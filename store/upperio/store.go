@@ -0,0 +1,413 @@
+package upperio
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gourd/kit/store"
+	"github.com/satori/go.uuid"
+	"upper.io/db.v1"
+)
+
+// NewStore returns the store.Provider of Store of the collection,
+// with entities of the struct type of entity (or pointer to it).
+// Columns of the entities are defined by the "db" tags of fields.
+//
+// Entities with string id (field tagged "id", or named "ID" if none)
+// are given a random id on Create
+func NewStore(coll string, entity interface{}) store.Provider {
+	typ := reflect.TypeOf(entity)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	idx, hasID := idField(typ)
+	return func(sess interface{}) (s store.Store, err error) {
+		dbSess, ok := sess.(db.Database)
+		if !ok {
+			err = fmt.Errorf("expected db.Database in sess, got %#v", sess)
+			return
+		}
+		s = &Store{
+			Db:     dbSess,
+			coll:   coll,
+			typ:    typ,
+			idx:    idx,
+			hasID:  hasID,
+			logger: log.NewLogfmtLogger(ioutil.Discard),
+		}
+		return
+	}
+}
+
+// idField returns the index of the string id field of the struct type
+func idField(typ reflect.Type) (idx int, ok bool) {
+	if typ.Kind() != reflect.Struct {
+		return
+	}
+	idx = -1
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		name := strings.Split(field.Tag.Get("db"), ",")[0]
+		if name == "id" {
+			idx = i
+			break
+		} else if field.Name == "ID" && idx < 0 {
+			idx = i
+		}
+	}
+	ok = idx >= 0 && typ.Field(idx).Type.Kind() == reflect.String
+	return
+}
+
+// Store implements store.Store, store.BulkStore and store.UpsertStore
+// of entities of a struct type in an upper.io collection
+type Store struct {
+	Db     db.Database
+	coll   string
+	typ    reflect.Type
+	idx    int
+	hasID  bool
+	logger log.Logger
+}
+
+// entity returns the struct value the entity pointer points to
+func (s *Store) entity(ep store.EntityPtr) (val reflect.Value, err error) {
+	val = reflect.ValueOf(ep)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Type() != s.typ {
+		err = s.errorf("expected *%s, got %#v", s.typ, ep)
+		return
+	}
+	return val.Elem(), nil
+}
+
+// list returns the slice value the entity list pointer points to
+func (s *Store) list(el store.EntityListPtr) (val reflect.Value, err error) {
+	val = reflect.ValueOf(el)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Type() != reflect.SliceOf(s.typ) {
+		err = s.errorf("expected *[]%s, got %#v", s.typ, el)
+		return
+	}
+	return val.Elem(), nil
+}
+
+// setID applies random uuid string to the string id of the
+// entity, if any. Entity of non-empty id is untouched
+func (s *Store) setID(val reflect.Value) {
+	if !s.hasID {
+		return
+	}
+	if id := val.Field(s.idx); id.String() == "" {
+		uid := uuid.NewV4()
+		id.SetString(base64.RawURLEncoding.EncodeToString(uid[:]))
+	}
+}
+
+// marshal returns the entity to write to the database.
+// (quick fix for upperio problem with db.Marshaler)
+func marshal(ep store.EntityPtr) (interface{}, error) {
+	if me, ok := ep.(db.Marshaler); ok {
+		return me.MarshalDB()
+	}
+	return ep, nil
+}
+
+// find returns the result of the conditions in the collection
+func find(coll db.Collection, c store.Conds) (res db.Result, err error) {
+	if c == nil {
+		return coll.Find(), nil
+	}
+	cond, err := TranslateConds(c)
+	if err != nil {
+		return
+	} else if cond == nil {
+		return coll.Find(), nil
+	}
+	return coll.Find(cond), nil
+}
+
+// Create an entity in the database, of the parent
+func (s *Store) Create(
+	cond store.Conds, ep store.EntityPtr) (err error) {
+
+	// get collection
+	coll, err := s.Coll()
+	if err != nil {
+		return
+	}
+
+	// apply random uuid string to empty string id
+	val, err := s.entity(ep)
+	if err != nil {
+		return
+	}
+	s.setID(val)
+
+	// add the entity to collection
+	item, err := marshal(ep)
+	if err != nil {
+		return
+	}
+	if _, err = coll.Append(item); err != nil {
+		err = s.errorf("Error creating %s: %s", s.typ.Name(), err.Error())
+	}
+	return
+}
+
+// Search entities by the query
+func (s *Store) Search(
+	q store.Query) store.Result {
+
+	return NewQueryResult(q, func() (res db.Result, err error) {
+		// get collection
+		coll, err := s.Coll()
+		if err != nil {
+			return
+		}
+
+		// retrieve entities by given query conditions
+		// (and keyset conditions of cursor, if any),
+		// excluding soft deleted entities
+		qconds, err := store.SearchConds(q, s.AllocEntity())
+		if err != nil {
+			return
+		}
+		if res, err = find(coll, qconds); err != nil {
+			return
+		}
+
+		// add sorting information, if any
+		res = res.Sort(Sort(q)...)
+
+		// handle paging
+		if q.GetOffset() != 0 {
+			res = res.Skip(uint(q.GetOffset()))
+		}
+		if q.GetLimit() != 0 {
+			res = res.Limit(uint(q.GetLimit()))
+		}
+
+		return
+	})
+}
+
+// One returns the first entity matches condition(s)
+func (s *Store) One(
+	c store.Conds, ep store.EntityPtr) (err error) {
+
+	val, err := s.entity(ep)
+	if err != nil {
+		return
+	}
+
+	// retrieve results from database
+	el := s.AllocEntityList()
+	if err = s.Search(store.NewQuery().SetConds(c)).All(el); err != nil {
+		return
+	}
+
+	// if not found, report
+	list := reflect.ValueOf(el).Elem()
+	if list.Len() == 0 {
+		err = store.ErrorNotFound
+		return
+	}
+
+	// assign the first retrieved value to the entity
+	val.Set(list.Index(0))
+	return
+}
+
+// Update entities on condition(s). If the entity has version field
+// (see store.VersionProp), the conditions should match at most one
+// entity (see store.VersionedMany), which is updated with its version
+// checked and incremented in the same statement
+func (s *Store) Update(
+	c store.Conds, ep store.EntityPtr) (err error) {
+
+	// get collection
+	coll, err := s.Coll()
+	if err != nil {
+		return
+	}
+	if _, err = s.entity(ep); err != nil {
+		return
+	}
+
+	// check and increment the version atomically, if the entity
+	// has version field and the database supports
+	if _, ok := store.VersionProp(ep); ok {
+		if err = s.checkSingle(coll, c); err != nil {
+			return
+		}
+		var done bool
+		if done, err = s.updateVersioned(coll, c, ep); done || err != nil {
+			return
+		}
+	}
+
+	// check and increment the version, if the entity has version field
+	if c, err = store.CheckVersion(s, c, ep); err != nil {
+		return
+	}
+
+	// update the matched entities
+	// (with the checked version, if any)
+	item, err := marshal(ep)
+	if err != nil {
+		return
+	}
+	res, err := find(coll, c)
+	if err != nil {
+		return
+	}
+	if err = res.Update(item); err != nil {
+		err = s.errorf("Error updating %s: %s", s.typ.Name(), err.Error())
+	}
+	return
+}
+
+// CreateMany creates all entities in the list in the database
+func (s *Store) CreateMany(
+	cond store.Conds, el store.EntityListPtr) (err error) {
+
+	// get collection
+	coll, err := s.Coll()
+	if err != nil {
+		return
+	}
+
+	// apply random uuid string to empty string id
+	list, err := s.list(el)
+	if err != nil {
+		return
+	}
+	for i := 0; i < list.Len(); i++ {
+		s.setID(list.Index(i))
+	}
+
+	// add the entities to collection
+	return CreateMany(coll, el)
+}
+
+// UpdateMany sets the fields of entities on condition(s)
+func (s *Store) UpdateMany(
+	c store.Conds, fields map[string]interface{}) (err error) {
+
+	// get collection
+	coll, err := s.Coll()
+	if err != nil {
+		return
+	}
+
+	// update the fields (and versions, if any) of matched entities
+	return s.updateMany(coll, c, fields)
+}
+
+// Upsert creates the entity, or updates the existing one on condition(s).
+// The id of the existing entity is kept (see Upsert)
+func (s *Store) Upsert(
+	c store.Conds, ep store.EntityPtr) (err error) {
+
+	// get collection
+	coll, err := s.Coll()
+	if err != nil {
+		return
+	}
+
+	// apply random uuid string to empty string id
+	val, err := s.entity(ep)
+	if err != nil {
+		return
+	}
+	s.setID(val)
+
+	// create or update the entity
+	return Upsert(s.Db, coll, c, ep)
+}
+
+// Delete entities on condition(s)
+func (s *Store) Delete(
+	c store.Conds) (err error) {
+
+	// get collection
+	coll, err := s.Coll()
+	if err != nil {
+		return
+	}
+
+	// soft delete the matched entities, if the entity has soft delete field
+	if fields, ok := store.SoftDeleteFields(s.AllocEntity()); ok {
+		return s.updateMany(coll, store.NotDeleted(c, s.AllocEntity()), fields)
+	}
+
+	// remove the matched entities
+	res, err := find(coll, c)
+	if err != nil {
+		return
+	}
+	if err = res.Remove(); err != nil {
+		err = s.errorf("Error deleting %s: %s", s.typ.Name(), err.Error())
+	}
+	return
+}
+
+// AllocEntity allocate memory for an entity
+func (s *Store) AllocEntity() store.EntityPtr {
+	return reflect.New(s.typ).Interface()
+}
+
+// AllocEntityList allocate memory for an entity list
+func (s *Store) AllocEntityList() store.EntityListPtr {
+	list := reflect.New(reflect.SliceOf(s.typ))
+	list.Elem().Set(reflect.MakeSlice(reflect.SliceOf(s.typ), 0, 0))
+	return list.Interface()
+}
+
+// Len inspect the length of an entity list
+func (s *Store) Len(pl store.EntityListPtr) int64 {
+	list, err := s.list(pl)
+	if err != nil {
+		return 0
+	}
+	return int64(list.Len())
+}
+
+// Coll return the raw upper.io collection
+func (s *Store) Coll() (coll db.Collection, err error) {
+	// get raw collection
+	coll, err = s.Db.Collection(s.coll)
+	if err != nil {
+		err = s.errorf("Error connecting collection %s: %s",
+			s.coll, err.Error())
+	}
+	return
+}
+
+// SetLogger set the logger for the Store
+func (s *Store) SetLogger(logger log.Logger) {
+	s.logger = logger
+}
+
+// errorf logs the message and returns it as internal server error
+func (s *Store) errorf(msg string, v ...interface{}) error {
+	msg = fmt.Sprintf(msg, v...)
+	s.logger.Log("store", s.coll, "message", msg)
+	return store.Error(http.StatusInternalServerError,
+		http.StatusText(http.StatusInternalServerError)).
+		TellServer("%s", msg)
+}
+
+// Close would not close database connection at all.
+// Please use store.CloseAllIn(ctx) to wrap up connections
+// in a context
+func (s *Store) Close() error {
+	return nil
+}
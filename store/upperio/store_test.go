@@ -0,0 +1,255 @@
+package upperio_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/storetest"
+	"github.com/gourd/kit/store/upperio"
+	"upper.io/db.v1"
+)
+
+type storeData struct {
+	ID   string `db:"id,omitempty"`
+	Name string `db:"name"`
+	Age  int    `db:"age"`
+}
+
+func TestNewStore(t *testing.T) {
+
+	fn := "./test10.tmp"
+	defer os.Remove(fn)
+
+	source := upperio.NewSource(testUpperDb(fn))
+	conn, err := source.Open()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	sess := conn.Raw().(db.Database)
+	if _, err = sess.Driver().(*sql.DB).Exec(`
+		CREATE TABLE store_data (
+			id text PRIMARY KEY,
+			name text,
+			age integer
+		)
+	`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s, err := upperio.NewStore("store_data", &storeData{})(sess)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// create with given id, and with random id
+	e := &storeData{ID: "given", Name: "foo", Age: 10}
+	if err := s.Create(nil, e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "given", e.ID; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	e2 := &storeData{Name: "bar", Age: 20}
+	if err := s.Create(nil, e2); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if e2.ID == "" {
+		t.Errorf("expected random id, got %#v", e2.ID)
+	}
+
+	// search
+	el := s.AllocEntityList()
+	if err := s.Search(store.NewQuery().AddCond("age >", 5)).All(el); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := int64(2), s.Len(el); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// one
+	found := s.AllocEntity().(*storeData)
+	if err := s.One(store.NewConds().Add("id", e.ID), found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := *e, *found; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// update
+	e.Name = "updated"
+	if err := s.Update(store.NewConds().Add("id", e.ID), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := s.One(store.NewConds().Add("id", e.ID), found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "updated", found.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// delete
+	if err := s.Delete(store.NewConds().Add("id", e.ID)); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	err = s.One(store.NewConds().Add("id", e.ID), found)
+	if serr, ok := err.(*store.StoreError); !ok || serr.Code != 404 {
+		t.Errorf("expected not found error, got %#v", err)
+	}
+}
+
+func TestNewStore_entityType(t *testing.T) {
+
+	fn := "./test11.tmp"
+	defer os.Remove(fn)
+
+	source := upperio.NewSource(testUpperDb(fn))
+	conn, err := source.Open()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	s, err := upperio.NewStore("store_data", storeData{})(conn.Raw())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if _, ok := s.AllocEntity().(*storeData); !ok {
+		t.Errorf("expected *storeData, got %#v", s.AllocEntity())
+	}
+	if _, ok := s.AllocEntityList().(*[]storeData); !ok {
+		t.Errorf("expected *[]storeData, got %#v", s.AllocEntityList())
+	}
+	if err := s.Create(nil, &testData{}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestNewStore_sess(t *testing.T) {
+	if _, err := upperio.NewStore("store_data", storeData{})("not a session"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+type versionData struct {
+	ID      string `db:"id,omitempty"`
+	Name    string `db:"name"`
+	Version int64  `db:"version" gourdupdate:"version"`
+}
+
+func TestStore_version(t *testing.T) {
+
+	fn := "./test11.tmp"
+	defer os.Remove(fn)
+
+	source := upperio.NewSource(testUpperDb(fn))
+	conn, err := source.Open()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	sess := conn.Raw().(db.Database)
+	if _, err = sess.Driver().(*sql.DB).Exec(`
+		CREATE TABLE version_data (
+			id text PRIMARY KEY,
+			name text,
+			version integer
+		)
+	`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s, err := upperio.NewStore("version_data", &versionData{})(sess)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	e := &versionData{Name: "foo"}
+	if err := s.Create(nil, e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	c := store.NewConds().Add("id", e.ID)
+
+	// both based on version 0, only the first succeeds
+	if err := s.Update(c, &versionData{ID: e.ID, Name: "bar"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	err = s.Update(c, &versionData{ID: e.ID, Name: "baz"})
+	if want, have := 409, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// bulk update increments the version
+	if err := store.UpdateMany(s, c, map[string]interface{}{"name": "qux"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	found := &versionData{}
+	if err := s.One(c, found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "qux", found.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := int64(2), found.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	err = s.Update(c, &versionData{ID: e.ID, Name: "baz", Version: 1})
+	if want, have := 409, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// upsert checks and increments the version
+	u := &versionData{Name: "upserted", Version: 2}
+	if err := store.Upsert(s, c, u); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := int64(3), u.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	err = store.Upsert(s, c, &versionData{Name: "stale", Version: 2})
+	if want, have := 409, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+// entityStores returns the NewStore of stores of storetest.Entity
+// in the database of the file
+func entityStores(t *testing.T, fn string) storetest.NewStore {
+	conn, err := upperio.NewSource(testUpperDb(fn)).Open()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sess := conn.Raw().(db.Database)
+	return func(t *testing.T) store.Store {
+		if _, err := sess.Driver().(*sql.DB).Exec(`
+			DROP TABLE IF EXISTS entity;
+			CREATE TABLE entity (
+				id text PRIMARY KEY,
+				name text,
+				version integer
+			);
+		`); err != nil {
+			t.Fatal(err.Error())
+		}
+		s, err := upperio.NewStore("entity", &storetest.Entity{})(sess)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+		return s
+	}
+}
+
+func TestStore_CreateID(t *testing.T) {
+	fn := "./test13.tmp"
+	defer os.Remove(fn)
+	storetest.CreateID(t, entityStores(t, fn))
+}
+
+func TestStore_UpdateVersion(t *testing.T) {
+	fn := "./test12.tmp"
+	defer os.Remove(fn)
+	storetest.UpdateVersion(t, entityStores(t, fn))
+}
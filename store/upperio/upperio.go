@@ -1,25 +1,68 @@
 package upperio
 
 import (
+	"fmt"
+
 	"github.com/gourd/kit/store"
 	"upper.io/db.v1"
 )
 
-// Conn implements store.Conn
+// Conn implements store.Conn and store.TxConn
 type Conn struct {
 	db db.Database
+	tx db.Tx
 }
 
-// Raw implements store.Conn.Raw()
+// Raw implements store.Conn.Raw().
+// Returns the db.Tx session within transaction
 func (conn *Conn) Raw() interface{} {
+	if conn.tx != nil {
+		return conn.tx
+	}
 	return conn.db
 }
 
-// Close implements store.Conn.Close()
+// Close implements store.Conn.Close().
+// Unfinished transaction would be rolled back
 func (conn *Conn) Close() {
+	if conn.tx != nil {
+		conn.Rollback()
+	}
 	conn.db.Close()
 }
 
+// Begin implements store.TxConn.Begin()
+func (conn *Conn) Begin() (err error) {
+	if conn.tx != nil {
+		err = fmt.Errorf("transaction already begun")
+		return
+	}
+	conn.tx, err = conn.db.Transaction()
+	return
+}
+
+// Commit implements store.TxConn.Commit()
+func (conn *Conn) Commit() (err error) {
+	if conn.tx == nil {
+		err = fmt.Errorf("transaction not begun")
+		return
+	}
+	err = conn.tx.Commit()
+	conn.tx = nil
+	return
+}
+
+// Rollback implements store.TxConn.Rollback()
+func (conn *Conn) Rollback() (err error) {
+	if conn.tx == nil {
+		err = fmt.Errorf("transaction not begun")
+		return
+	}
+	err = conn.tx.Rollback()
+	conn.tx = nil
+	return
+}
+
 // Source is the upperio implementation of store.Source
 type Source struct {
 	adapter string
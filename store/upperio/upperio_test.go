@@ -4,7 +4,9 @@ import (
 	"os"
 	"testing"
 
+	"github.com/gourd/kit/store"
 	"github.com/gourd/kit/store/upperio"
+	"upper.io/db.v1"
 )
 
 func TestSource(t *testing.T) {
@@ -27,3 +29,58 @@ func TestSource(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+func TestConn_tx(t *testing.T) {
+	var conn store.TxConn = &upperio.Conn{}
+	_ = conn
+}
+
+func TestConn_rollback(t *testing.T) {
+
+	fn := "./test5.tmp"
+	source := upperio.NewSource(testUpperDb(fn))
+
+	// add dummy data to the database
+	if err := testUpperDbData(source); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	conn, err := source.Open()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer conn.Close()
+
+	txConn := conn.(store.TxConn)
+	if err := txConn.Begin(); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// append within transaction then rollback
+	coll, err := txConn.Raw().(db.Database).Collection("dummy_data")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if _, err := coll.Append(&testData{HelloWorld: "in transaction"}); err != nil {
+		t.Errorf(err.Error())
+	}
+	if err := txConn.Rollback(); err != nil {
+		t.Errorf(err.Error())
+	}
+
+	coll, err = txConn.Raw().(db.Database).Collection("dummy_data")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if count, err := coll.Find(db.Cond{"HelloWorld": "in transaction"}).Count(); err != nil {
+		t.Errorf(err.Error())
+	} else if want, have := uint64(0), count; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// clean up the temp database
+	err = os.Remove(fn)
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
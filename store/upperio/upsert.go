@@ -0,0 +1,412 @@
+package upperio
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gourd/kit/store"
+	"upper.io/db.v1"
+)
+
+// dialects of SQL upsert statement
+const (
+	dialectNone = iota
+	dialectSQLite
+	dialectPostgreSQL
+	dialectMySQL
+)
+
+// Upsert creates the entity in the collection, or updates the existing
+// row matching the conditions. The conditions should be equalities
+// (see store.Conds.Add) of columns with unique constraint, and the entity
+// should carry the same values.
+//
+// For SQL adapters, it runs as a single statement:
+//
+//	SQLite (3.24+)   INSERT ... ON CONFLICT (keys) DO UPDATE
+//	PostgreSQL       INSERT ... ON CONFLICT (keys) DO UPDATE
+//	MySQL            INSERT ... ON DUPLICATE KEY UPDATE
+//
+// Columns of an existing row which are not in the entity are kept, and
+// its "id" column is not updated. The id of the existing row is set to
+// the entity, if the entity is a struct with string id.
+//
+// If the entity has version field (see store.VersionProp), the existing
+// row is updated only if of the version of the entity, in the same
+// statement, and the version is incremented. Otherwise 409 StoreError
+// (see store.VersionConflict) is returned.
+//
+// For other adapters, or within a transaction, it finds the row then
+// updates or appends (see store.Upsert)
+func Upsert(sess db.Database, coll db.Collection, c store.Conds, ep interface{}) (err error) {
+
+	keys, err := upsertKeys(c)
+	if err != nil {
+		return
+	}
+
+	// keep the id of the existing row, if any,
+	// and check its version before writing
+	found, err := existing(coll, c, ep)
+	if err != nil {
+		return
+	}
+	prop, versioned := store.VersionProp(ep)
+	expected, _ := store.Version(ep)
+	if found != nil {
+		keepID(ep, found)
+		if stored, _ := store.Version(found); versioned && stored != expected {
+			return store.VersionConflict(expected, stored)
+		}
+	}
+
+	cols, vals, err := columns(ep)
+	if err != nil {
+		return
+	}
+	if versioned && !inStrings(cols, prop) {
+		cols, vals = append(cols, prop), append(vals, expected)
+	}
+
+	drv, dialect := sqlDialect(sess)
+	if dialect == dialectNone {
+		return upsertEach(coll, c, ep, cols, vals)
+	}
+
+	if !versioned {
+		prop = ""
+	}
+	query, err := upsertSQL(dialect, coll.Name(), keys, cols, prop)
+	if err != nil {
+		return
+	}
+	res, err := drv.Exec(query, vals...)
+	if err != nil {
+		return upsertError("error upserting %s: %s", coll.Name(), err)
+	}
+	if !versioned {
+		return
+	}
+
+	// nothing written if the version check failed
+	if n, rerr := res.RowsAffected(); rerr == nil && n == 0 {
+		if found, err = existing(coll, c, ep); err != nil {
+			return
+		}
+		var stored int64
+		if found != nil {
+			stored, _ = store.Version(found)
+		}
+		return store.VersionConflict(expected, stored)
+	}
+	if found != nil {
+		store.SetVersion(ep, expected+1)
+	}
+	return
+}
+
+// existing returns the row matching the conditions, as the struct
+// type of the entity, if any. Returns nil if the entity is not a
+// pointer to struct
+func existing(coll db.Collection, c store.Conds, ep interface{}) (found interface{}, err error) {
+	typ := reflect.TypeOf(ep)
+	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return
+	}
+	res, err := find(coll, c)
+	if err != nil {
+		return
+	}
+	ptr := reflect.New(typ.Elem())
+	if err = res.One(ptr.Interface()); err == db.ErrNoMoreRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, upsertError("error upserting %s: %s", coll.Name(), err)
+	}
+	return ptr.Interface(), nil
+}
+
+// keepID sets the string id of the row found to the entity, if any
+func keepID(ep, found interface{}) {
+	val := reflect.ValueOf(ep).Elem()
+	if idx, ok := idField(val.Type()); ok {
+		val.Field(idx).Set(reflect.ValueOf(found).Elem().Field(idx))
+	}
+}
+
+// upsertEach updates the row matching the conditions, or appends a new
+// one if none. If appending fails because of a concurrent append of the
+// same key, the row is updated instead
+func upsertEach(coll db.Collection, c store.Conds, ep interface{}, cols []string, vals []interface{}) (err error) {
+	row := make(map[string]interface{}, len(cols))
+	for i, col := range cols {
+		row[col] = vals[i]
+	}
+
+	updated, err := updateEach(coll, c, ep, row)
+	if err != nil || updated {
+		return
+	}
+	if _, err = coll.Append(row); err == nil {
+		return
+	}
+
+	// the key might be appended concurrently, retry updating it
+	if updated, uerr := updateEach(coll, c, ep, row); uerr != nil {
+		return uerr
+	} else if updated {
+		return nil
+	}
+	return upsertError("error upserting %s: %s", coll.Name(), err)
+}
+
+// updateEach updates the row matching the conditions, except its
+// "id" column, if any. If the entity has version field, the row
+// should be of the version of the entity, which is incremented
+func updateEach(coll db.Collection, c store.Conds, ep interface{}, row map[string]interface{}) (updated bool, err error) {
+	update := make(map[string]interface{}, len(row))
+	for col, v := range row {
+		if col != "id" {
+			update[col] = v
+		}
+	}
+
+	// update the row of the checked version only
+	prop, versioned := store.VersionProp(ep)
+	expected, _ := store.Version(ep)
+	if versioned {
+		found, ferr := existing(coll, c, ep)
+		if ferr != nil || found == nil {
+			return false, ferr
+		}
+		if stored, _ := store.Version(found); stored != expected {
+			return false, store.VersionConflict(expected, stored)
+		}
+		vc := store.NewConds().Add("", c)
+		c, update[prop] = vc.Add(prop, expected), expected+1
+	}
+
+	res, err := find(coll, c)
+	if err != nil {
+		return
+	}
+	n, err := res.Count()
+	if err != nil {
+		return false, upsertError("error upserting %s: %s", coll.Name(), err)
+	} else if n == 0 {
+		return
+	}
+	if err = res.Update(update); err != nil {
+		return false, upsertError("error upserting %s: %s", coll.Name(), err)
+	}
+	if versioned {
+		store.SetVersion(ep, expected+1)
+	}
+	return true, nil
+}
+
+// sqlDialect returns the *sql.DB driver and the upsert dialect of the
+// session. Returns dialectNone for non-SQL adapters or transaction, as
+// the driver of a transaction session is not the transaction itself
+func sqlDialect(sess db.Database) (drv *sql.DB, dialect int) {
+	if _, ok := sess.(db.Tx); ok {
+		return
+	}
+	drv, ok := sess.Driver().(*sql.DB)
+	if !ok {
+		return nil, dialectNone
+	}
+	name := strings.ToLower(fmt.Sprintf("%T", drv.Driver()))
+	switch {
+	case strings.Contains(name, "sqlite"):
+		dialect = dialectSQLite
+	case strings.Contains(name, "pq.") || strings.Contains(name, "postgres"):
+		dialect = dialectPostgreSQL
+	case strings.Contains(name, "mysql"):
+		dialect = dialectMySQL
+	}
+	return
+}
+
+// quoteName quotes the table or column name in the dialect
+func quoteName(dialect int, name string) string {
+	if dialect == dialectMySQL {
+		return "`" + strings.Replace(name, "`", "``", -1) + "`"
+	}
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// upsertSQL builds the upsert statement of the dialect. If version is
+// not empty, the existing row is updated only if its version column is
+// of the value to insert, and the version is incremented
+func upsertSQL(dialect int, table string, keys, cols []string, version string) (query string, err error) {
+	quote := func(name string) string {
+		return quoteName(dialect, name)
+	}
+	qtable, qversion := quote(table), quote(version)
+
+	qcols := make([]string, len(cols))
+	holders := make([]string, len(cols))
+	var updates []string
+	for i, col := range cols {
+		qcols[i] = quote(col)
+		holders[i] = "?"
+		if dialect == dialectPostgreSQL {
+			holders[i] = fmt.Sprintf("$%d", i+1)
+		}
+		if col == "id" || col == version || inStrings(keys, col) {
+			continue
+		}
+		switch {
+		case dialect != dialectMySQL:
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", qcols[i], qcols[i]))
+		case version != "":
+			updates = append(updates, fmt.Sprintf("%s = IF(%s = VALUES(%s), VALUES(%s), %s)",
+				qcols[i], qversion, qversion, qcols[i], qcols[i]))
+		default:
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", qcols[i], qcols[i]))
+		}
+	}
+
+	// increment the version last, after MySQL compares it
+	if version != "" {
+		if dialect == dialectMySQL {
+			updates = append(updates, fmt.Sprintf("%s = IF(%s = VALUES(%s), %s + 1, %s)",
+				qversion, qversion, qversion, qversion, qversion))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = %s.%s + 1", qversion, qtable, qversion))
+		}
+	}
+	for _, key := range keys {
+		if !inStrings(cols, key) {
+			err = upsertError("key %#v is not a column of the entity", key)
+			return
+		}
+	}
+
+	insert := fmt.Sprintf("INTO %s (%s) VALUES (%s)", qtable,
+		strings.Join(qcols, ", "), strings.Join(holders, ", "))
+	switch dialect {
+	case dialectSQLite, dialectPostgreSQL:
+		qkeys := make([]string, len(keys))
+		for i, key := range keys {
+			qkeys[i] = quote(key)
+		}
+		query = fmt.Sprintf("INSERT %s ON CONFLICT (%s) ", insert, strings.Join(qkeys, ", "))
+		if len(updates) == 0 {
+			query += "DO NOTHING"
+		} else {
+			query += "DO UPDATE SET " + strings.Join(updates, ", ")
+		}
+		if version != "" {
+			query += fmt.Sprintf(" WHERE %s.%s = EXCLUDED.%s", qtable, qversion, qversion)
+		}
+	case dialectMySQL:
+		if len(updates) == 0 {
+			query = "INSERT IGNORE " + insert
+		} else {
+			query = "INSERT " + insert + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+		}
+	}
+	return
+}
+
+// upsertKeys returns the key columns of equality conditions
+func upsertKeys(c store.Conds) (keys []string, err error) {
+	if c == nil || len(c.GetAll()) == 0 || c.GetRel() != store.And {
+		err = upsertError("upsert expects AND conditions of a unique field set")
+		return
+	}
+	for _, cond := range c.GetAll() {
+		if cond.Prop == "" || cond.Op != store.Eq {
+			err = upsertError("upsert expects equality conditions, got %#v", cond)
+			return
+		}
+		keys = append(keys, cond.Prop)
+	}
+	return
+}
+
+// columns returns the column names and values of the entity, by
+// the "db" tags of struct fields. Entity implementing db.Marshaler
+// is marshaled first. Fields tagged "-", or tagged "omitempty" with
+// zero value, are skipped
+func columns(entity interface{}) (cols []string, vals []interface{}, err error) {
+	if me, ok := entity.(db.Marshaler); ok {
+		if entity, err = me.MarshalDB(); err != nil {
+			return
+		}
+	}
+
+	val := reflect.ValueOf(entity)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+
+	switch val.Kind() {
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			break
+		}
+		for _, key := range val.MapKeys() {
+			cols = append(cols, key.String())
+		}
+		sort.Strings(cols)
+		for _, col := range cols {
+			vals = append(vals, val.MapIndex(reflect.ValueOf(col)).Interface())
+		}
+		return
+	case reflect.Struct:
+		typ := val.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" {
+				continue // unexported
+			}
+			tags := strings.Split(field.Tag.Get("db"), ",")
+			name := tags[0]
+			if name == "-" {
+				continue
+			} else if name == "" {
+				name = field.Name
+			}
+			fval := val.Field(i)
+			if inStrings(tags[1:], "omitempty") &&
+				reflect.DeepEqual(fval.Interface(), reflect.Zero(fval.Type()).Interface()) {
+				continue
+			}
+			v := fval.Interface()
+			if me, ok := v.(db.Marshaler); ok {
+				if v, err = me.MarshalDB(); err != nil {
+					return
+				}
+			}
+			cols, vals = append(cols, name), append(vals, v)
+		}
+		return
+	}
+
+	err = upsertError("expected struct or map entity, got %#v", entity)
+	return
+}
+
+// inStrings tells if the string is in the list
+func inStrings(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
+
+// upsertError returns an internal server error with the server message
+func upsertError(msg string, v ...interface{}) error {
+	return store.Error(http.StatusInternalServerError,
+		http.StatusText(http.StatusInternalServerError)).
+		TellServer(msg, v...)
+}
@@ -0,0 +1,108 @@
+package upperio_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/upperio"
+	"upper.io/db.v1"
+)
+
+type upsertData struct {
+	ID   string `db:"id,omitempty"`
+	Code string `db:"code"`
+	Name string `db:"name"`
+}
+
+func TestUpsert(t *testing.T) {
+
+	fn := "./test9.tmp"
+	defer os.Remove(fn)
+
+	source := upperio.NewSource(testUpperDb(fn))
+	conn, err := source.Open()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	sess := conn.Raw().(db.Database)
+	_, err = sess.Driver().(*sql.DB).Exec(`
+		CREATE TABLE upsert_data (
+			id text PRIMARY KEY,
+			code text,
+			name text
+		);
+		CREATE UNIQUE INDEX upsert_data_code ON upsert_data (code);
+	`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	coll, err := sess.Collection("upsert_data")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cond := store.NewConds().Add("code", "a")
+	upsert := func(e *upsertData) {
+		if err := upperio.Upsert(sess, coll, cond, e); err != nil {
+			t.Fatalf("unexpected error: %#v", err.Error())
+		}
+	}
+	upsert(&upsertData{ID: "1", Code: "a", Name: "foo"})
+	e := &upsertData{ID: "2", Code: "a", Name: "bar"}
+	upsert(e)
+	upsert(&upsertData{ID: "3", Code: "b", Name: "baz"})
+
+	// id of the existing row is kept
+	if want, have := "1", e.ID; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	var list []upsertData
+	if err := coll.Find().Sort("code").All(&list); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 2, len(list); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "bar", list[0].Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "1", list[0].ID; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// upsert within transaction
+	if err := conn.(store.TxConn).Begin(); err != nil {
+		t.Fatal(err.Error())
+	}
+	tx := conn.Raw().(db.Database)
+	txColl, err := tx.Collection("upsert_data")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = upperio.Upsert(tx, txColl, cond, &upsertData{ID: "4", Code: "a", Name: "in transaction"})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if err := conn.(store.TxConn).Commit(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	found := &upsertData{}
+	if err := coll.Find(db.Cond{"code": "a"}).One(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "in transaction", found.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// non-equality conditions
+	err = upperio.Upsert(sess, coll, store.NewConds().AddOp("code", store.Ne, "a"), &upsertData{})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,199 @@
+package upperio
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gourd/kit/store"
+	"upper.io/db.v1"
+)
+
+// maxKeys is the maximum number of keys in an update statement,
+// within the limit of variables in an SQLite statement
+const maxKeys = 500
+
+// idColumn returns the column name of the string id field
+func (s *Store) idColumn() string {
+	field := s.typ.Field(s.idx)
+	if name := strings.Split(field.Tag.Get("db"), ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// checkSingle returns 400 StoreError if the conditions match more
+// than one entity, for update of entity with version field
+func (s *Store) checkSingle(coll db.Collection, c store.Conds) error {
+	res, err := find(coll, c)
+	if err != nil {
+		return err
+	}
+	n, err := res.Count()
+	if err != nil {
+		return s.errorf("Error updating %s: %s", s.typ.Name(), err.Error())
+	} else if n > 1 {
+		return store.VersionedMany(int64(n))
+	}
+	return nil
+}
+
+// updateVersioned updates the entity matching the conditions,
+// if its stored version is the version of the entity, and increments
+// the version of the entity. The check and the update run as a single
+// statement, so an entity updated by others in between is reported as
+// 409 StoreError (see store.VersionConflict) instead of being lost.
+//
+// Returns false if it could not run in a single statement (e.g. not
+// SQL database or within a transaction), for the caller to fall back
+func (s *Store) updateVersioned(coll db.Collection, c store.Conds, ep store.EntityPtr) (done bool, err error) {
+	drv, dialect := sqlDialect(s.Db)
+	if dialect == dialectNone || !s.hasID {
+		return
+	}
+	done = true
+	prop, _ := store.VersionProp(ep)
+	expected, _ := store.Version(ep)
+
+	// find the stored entity to update, if any
+	found := s.AllocEntity()
+	if err = s.One(c, found); err != nil {
+		if store.ExpandError(err).Status == http.StatusNotFound {
+			err = nil // nothing to update
+		}
+		return
+	}
+	if stored, _ := store.Version(found); stored != expected {
+		err = store.VersionConflict(expected, stored)
+		return
+	}
+	idCol, id := s.idColumn(), reflect.ValueOf(found).Elem().Field(s.idx).String()
+
+	// update the entity of the id only if still of the expected version
+	store.SetVersion(ep, expected+1)
+	cols, vals, err := columns(ep)
+	if err != nil {
+		store.SetVersion(ep, expected)
+		return
+	}
+	var setCols []string
+	var args []interface{}
+	for i, col := range cols {
+		if col != idCol {
+			setCols, args = append(setCols, col), append(args, vals[i])
+		}
+	}
+	args = append(args, id, expected)
+	query := updateSQL(dialect, coll.Name(), setCols, "", idCol, 1, prop)
+	res, err := drv.Exec(query, args...)
+	if err != nil {
+		store.SetVersion(ep, expected)
+		err = s.errorf("Error updating %s: %s", s.typ.Name(), err.Error())
+		return
+	}
+	if n, rerr := res.RowsAffected(); rerr == nil && n == 0 {
+		store.SetVersion(ep, expected)
+		if err = s.One(store.NewConds().Add(idCol, id), found); err != nil {
+			return
+		}
+		stored, _ := store.Version(found)
+		err = store.VersionConflict(expected, stored)
+	}
+	return
+}
+
+// updateMany sets the fields of all entities matching the conditions.
+// If the entity has version field, the versions of the entities are
+// incremented, so their former versions no longer pass the check
+func (s *Store) updateMany(coll db.Collection, c store.Conds, fields map[string]interface{}) (err error) {
+	prop, ok := store.VersionProp(s.AllocEntity())
+	if _, set := fields[prop]; !ok || set || !s.hasID || len(fields) == 0 {
+		return UpdateMany(coll, c, fields)
+	}
+
+	// the entities to update
+	res, err := find(coll, c)
+	if err != nil {
+		return
+	}
+	el := s.AllocEntityList()
+	if err = res.All(el); err != nil {
+		return s.errorf("Error updating %s: %s", s.typ.Name(), err.Error())
+	}
+	list := reflect.ValueOf(el).Elem()
+	if list.Len() == 0 {
+		return
+	}
+
+	// increment the versions in the update statement, if SQL
+	idCol := s.idColumn()
+	drv, dialect := sqlDialect(s.Db)
+	if dialect != dialectNone {
+		cols, vals, _ := columns(fields)
+		for start := 0; start < list.Len(); start += maxKeys {
+			end := start + maxKeys
+			if end > list.Len() {
+				end = list.Len()
+			}
+			args := append([]interface{}{}, vals...)
+			for i := start; i < end; i++ {
+				args = append(args, list.Index(i).Field(s.idx).String())
+			}
+			query := updateSQL(dialect, coll.Name(), cols, prop, idCol, end-start)
+			if _, err = drv.Exec(query, args...); err != nil {
+				return s.errorf("Error updating %s: %s", s.typ.Name(), err.Error())
+			}
+		}
+		return
+	}
+
+	// otherwise, update the entities one by one with their next versions
+	for i := 0; i < list.Len(); i++ {
+		item := list.Index(i)
+		version, _ := store.Version(item.Addr().Interface())
+		row := make(map[string]interface{}, len(fields)+1)
+		for name, v := range fields {
+			row[name] = v
+		}
+		row[prop] = version + 1
+		if err = coll.Find(db.Cond{idCol: item.Field(s.idx).String()}).Update(row); err != nil {
+			return s.errorf("Error updating %s: %s", s.typ.Name(), err.Error())
+		}
+	}
+	return
+}
+
+// updateSQL builds the update statement of the dialect. It sets the
+// columns to placeholders, and increments the incr column (if not
+// empty), of the rows with key column in n placeholders and the
+// conds columns equal to placeholders. Placeholders are in the order
+// of the columns, the keys, then the conds
+func updateSQL(dialect int, table string, cols []string, incr, key string, n int, conds ...string) string {
+	var count int
+	holder := func() string {
+		if count++; dialect == dialectPostgreSQL {
+			return fmt.Sprintf("$%d", count)
+		}
+		return "?"
+	}
+
+	sets := make([]string, 0, len(cols)+1)
+	for _, col := range cols {
+		sets = append(sets, quoteName(dialect, col)+" = "+holder())
+	}
+	if incr != "" {
+		q := quoteName(dialect, incr)
+		sets = append(sets, q+" = "+q+" + 1")
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = holder()
+	}
+	where := []string{fmt.Sprintf("%s IN (%s)", quoteName(dialect, key), strings.Join(keys, ", "))}
+	for _, col := range conds {
+		where = append(where, quoteName(dialect, col)+" = "+holder())
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s", quoteName(dialect, table),
+		strings.Join(sets, ", "), strings.Join(where, " AND "))
+}
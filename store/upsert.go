@@ -0,0 +1,103 @@
+package store
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// UpsertStore is a Store with native upsert operation
+type UpsertStore interface {
+	Store
+
+	// Upsert creates the entity, or updates the existing entity
+	// matching the conditions, as a single atomic operation.
+	// The conditions are equalities of a unique field set. The
+	// id of the existing entity is kept, and set to the entity.
+	//
+	// If the entity has version field (see VersionProp), the
+	// existing entity should be of the version of the entity, or
+	// 409 StoreError is returned. The version is incremented on
+	// update, as Update does
+	Upsert(c Conds, ep EntityPtr) error
+}
+
+// Upsert creates the entity, or updates the existing entity matching
+// the conditions. The conditions should be equalities (see Conds.Add)
+// of a unique field set. Their values are set to the entity before
+// upsert.
+//
+// Uses the native operation of UpsertStore, if implemented. Otherwise
+// falls back to One then Update or Create. If Create fails because of
+// a concurrent create of the same key, the entity is updated instead
+func Upsert(s Store, c Conds, ep EntityPtr) (err error) {
+	if err = upsertKeys(c, ep); err != nil {
+		return
+	}
+	if us, ok := s.(UpsertStore); ok {
+		return us.Upsert(c, ep)
+	}
+
+	updated, err := upsertExisting(s, c, ep)
+	if err != nil || updated {
+		return
+	}
+	if err = s.Create(c, ep); err == nil {
+		return
+	}
+
+	// the key might be created concurrently, retry updating it
+	if updated, uerr := upsertExisting(s, c, ep); uerr == nil && updated {
+		err = nil
+	}
+	return
+}
+
+// upsertExisting updates the existing entity matching the conditions,
+// if any. The id of the existing entity is kept
+func upsertExisting(s Store, c Conds, ep EntityPtr) (updated bool, err error) {
+	found := s.AllocEntity()
+	if err = s.One(c, found); err != nil {
+		if ExpandError(err).Status == http.StatusNotFound {
+			err = nil
+		}
+		return
+	}
+
+	val := reflect.ValueOf(ep).Elem()
+	if id, ok := propField(val, "id"); ok {
+		if fid, ok := propField(reflect.ValueOf(found).Elem(), "id"); ok {
+			id.Set(fid)
+		}
+	}
+	if err = s.Update(c, ep); err == nil {
+		updated = true
+	}
+	return
+}
+
+// upsertKeys validates the conditions of upsert and
+// sets the condition values to the entity
+func upsertKeys(c Conds, ep EntityPtr) error {
+	val := reflect.ValueOf(ep)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("expected pointer to struct, got %#v", ep)
+	}
+	if c == nil || len(c.GetAll()) == 0 || c.GetRel() != And {
+		return Error(http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)).
+			TellServer("upsert expects AND conditions of a unique field set")
+	}
+	for _, cond := range c.GetAll() {
+		if cond.Prop == "" || cond.Op != Eq {
+			return Error(http.StatusInternalServerError,
+				http.StatusText(http.StatusInternalServerError)).
+				TellServer("upsert expects equality conditions, got %#v", cond)
+		}
+		if err := setProp(val.Elem(), cond.Prop, cond.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
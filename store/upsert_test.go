@@ -0,0 +1,144 @@
+package store_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+)
+
+func TestUpsertStore(t *testing.T) {
+	var s store.UpsertStore = &memstore.Store{}
+	_ = s
+	if _, ok := interface{}(plainStore{}).(store.UpsertStore); ok {
+		t.Errorf("plainStore should not implement store.UpsertStore")
+	}
+}
+
+func testUpsert(t *testing.T, s store.Store) {
+	cond := store.NewConds().Add("name", "foo")
+
+	// create
+	e1 := &bulkEntity{Age: 1}
+	if err := store.Upsert(s, cond, e1); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "foo", e1.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if e1.ID == "" {
+		t.Errorf("id is not generated")
+	}
+
+	// update, keeping the id
+	e2 := &bulkEntity{Age: 2}
+	if err := store.Upsert(s, cond, e2); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := e1.ID, e2.ID; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	found := &[]bulkEntity{}
+	if err := s.Search(store.NewQuery()).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 1, len(*found); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 2, (*found)[0].Age; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// non-equality conditions
+	if err := store.Upsert(s, store.NewConds().AddOp("age", store.Gt, 1), &bulkEntity{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+	if err := store.Upsert(s, store.NewConds(), &bulkEntity{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	testUpsert(t, bulkStore(t))
+}
+
+func TestUpsert_fallback(t *testing.T) {
+	testUpsert(t, plainStore{bulkStore(t)})
+}
+
+// racyStore creates the entity of a concurrent
+// writer before the entity of Create
+type racyStore struct {
+	plainStore
+}
+
+func (s racyStore) Create(c store.Conds, ep store.EntityPtr) error {
+	if err := s.Store.Create(c, &bulkEntity{Name: "foo", Age: 1}); err != nil {
+		return err
+	}
+	return store.Error(http.StatusConflict, "duplicated key")
+}
+
+func TestUpsert_race(t *testing.T) {
+	s := racyStore{plainStore{bulkStore(t)}}
+
+	e := &bulkEntity{Age: 2}
+	if err := store.Upsert(s, store.NewConds().Add("name", "foo"), e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	found := &[]bulkEntity{}
+	if err := s.Search(store.NewQuery()).All(found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := 1, len(*found); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := 2, (*found)[0].Age; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func testUpsertVersion(t *testing.T, s store.Store) {
+	c := store.NewConds().Add("id", "1")
+	if err := store.Upsert(s, c, &versionEntity{Name: "foo"}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+
+	// both based on version 0, only the first succeeds
+	e := &versionEntity{Name: "bar"}
+	if err := store.Upsert(s, c, e); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := uint32(1), e.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	err := store.Upsert(s, c, &versionEntity{Name: "baz"})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if want, have := http.StatusConflict, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	found := &versionEntity{}
+	if err := s.One(c, found); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := "bar", found.Name; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := uint32(1), found.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestUpsert_version(t *testing.T) {
+	testUpsertVersion(t, versionStore(t))
+}
+
+func TestUpsert_versionFallback(t *testing.T) {
+	testUpsertVersion(t, plainStore{versionStore(t)})
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// VersionProp returns the property name of the version field of the
+// entity (or pointer to entity). The field should be an integer tagged
+// with `gourdupdate:"version"`, e.g.
+//
+//	Version int64 `db:"version" gourdupdate:"version"`
+//
+// Stores check the version on update: the entity to update should
+// carry the version it is based on. If the stored version differs,
+// update fails with 409 StoreError (see VersionConflict). Otherwise
+// the version is incremented. As the version is of a single entity,
+// the conditions of Update should match at most one entity, or Update
+// fails with 400 StoreError (see VersionedMany). The property name is
+// the name in "db" tag, or the field name if not tagged
+func VersionProp(entity interface{}) (prop string, ok bool) {
+	val, ok := versionField(entity)
+	if !ok {
+		return
+	}
+	field, _ := val.Type().FieldByName(versionFieldName(val.Type()))
+	if prop = strings.Split(field.Tag.Get("db"), ",")[0]; prop == "" {
+		prop = field.Name
+	}
+	return prop, true
+}
+
+// Version returns the version of the entity. Returns
+// false if the entity has no version field
+func Version(entity interface{}) (version int64, ok bool) {
+	val, ok := versionField(entity)
+	if !ok {
+		return
+	}
+	field := val.FieldByName(versionFieldName(val.Type()))
+	switch field.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(field.Uint()), true
+	}
+	return field.Int(), true
+}
+
+// SetVersion sets the version of the entity, if the entity is a
+// pointer to struct with version field. Returns false otherwise
+func SetVersion(ep EntityPtr, version int64) bool {
+	ptr := reflect.ValueOf(ep)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return false
+	}
+	val, ok := versionField(ep)
+	if !ok {
+		return false
+	}
+	field := val.FieldByName(versionFieldName(val.Type()))
+	switch field.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(version))
+	default:
+		field.SetInt(version)
+	}
+	return true
+}
+
+// VersionConflict returns a 409 StoreError of updating an
+// entity of the expected version while the stored differs
+func VersionConflict(expected, stored int64) *StoreError {
+	return Error(http.StatusConflict, "version conflict").
+		TellDeveloper("expected version %d, but the stored version is %d",
+			expected, stored).
+		TellServer("version conflict: expected %d, stored %d", expected, stored)
+}
+
+// VersionedMany returns a 400 StoreError of updating
+// n entities of version field at once by Update
+func VersionedMany(n int64) *StoreError {
+	return Error(http.StatusBadRequest, "versioned update matches more than one entity").
+		TellDeveloper("conditions of update of entity with version field "+
+			"should match a single entity, but matched %d", n).
+		TellServer("versioned update matches %d entities", n)
+}
+
+// CheckVersion checks the version of the entity to update against the
+// stored entity matching the conditions. If matches, the version of the
+// entity is incremented and the conditions are returned with additional
+// condition of the checked version, for the update to match only the
+// checked version. Returns 409 StoreError if the stored version differs.
+//
+// Returns the conditions as is if the entity has no version field,
+// or no stored entity matches the conditions
+func CheckVersion(s Store, c Conds, ep EntityPtr) (vc Conds, err error) {
+	prop, ok := VersionProp(ep)
+	if !ok {
+		return c, nil
+	}
+	expected, _ := Version(ep)
+
+	found := s.AllocEntity()
+	if err = s.One(c, found); err != nil {
+		if ExpandError(err).Status == http.StatusNotFound {
+			return c, nil
+		}
+		return
+	}
+	if stored, _ := Version(found); stored != expected {
+		err = VersionConflict(expected, stored)
+		return
+	}
+
+	SetVersion(ep, expected+1)
+	vc = NewConds()
+	if c != nil && len(c.GetAll()) > 0 {
+		vc.Add("", c)
+	}
+	vc.Add(prop, expected)
+	return
+}
+
+// versionField returns the struct value of the entity,
+// if the entity has version field
+func versionField(entity interface{}) (val reflect.Value, ok bool) {
+	val = reflect.ValueOf(entity)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return
+	}
+	ok = versionFieldName(val.Type()) != ""
+	return
+}
+
+// versionFieldName returns the name of the version
+// field of the struct type, or empty if none
+func versionFieldName(typ reflect.Type) string {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Tag.Get("gourdupdate") != "version" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return field.Name
+		}
+	}
+	return ""
+}
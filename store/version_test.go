@@ -0,0 +1,124 @@
+package store_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gourd/kit/store"
+	"github.com/gourd/kit/store/memstore"
+)
+
+type versionEntity struct {
+	ID      string `db:"id,omitempty"`
+	Name    string `db:"name"`
+	Version uint32 `db:"version" gourdupdate:"version"`
+}
+
+func versionStore(t *testing.T) store.Store {
+	conn, err := memstore.NewSource().Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	s, err := memstore.Provider("entity", &versionEntity{})(conn.Raw())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	return s
+}
+
+func TestVersionProp(t *testing.T) {
+	prop, ok := store.VersionProp(versionEntity{})
+	if !ok {
+		t.Fatalf("expected version field")
+	}
+	if want, have := "version", prop; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if _, ok := store.VersionProp(&bulkEntity{}); ok {
+		t.Errorf("expected no version field")
+	}
+	if _, ok := store.VersionProp(struct {
+		Version string `gourdupdate:"version"`
+	}{}); ok {
+		t.Errorf("expected non-integer field to be ignored")
+	}
+}
+
+func TestSetVersion(t *testing.T) {
+	e := &versionEntity{Version: 2}
+	if version, ok := store.Version(e); !ok {
+		t.Errorf("expected version field")
+	} else if want, have := int64(2), version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if !store.SetVersion(e, 3) {
+		t.Errorf("expected version to be set")
+	}
+	if want, have := uint32(3), e.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if store.SetVersion(versionEntity{}, 3) {
+		t.Errorf("expected non-pointer not to be set")
+	}
+	if store.SetVersion(&bulkEntity{}, 3) {
+		t.Errorf("expected entity without version field not to be set")
+	}
+}
+
+func TestVersionConflict(t *testing.T) {
+	serr := store.VersionConflict(1, 2)
+	if want, have := http.StatusConflict, serr.Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "expected version 1, but the stored version is 2", serr.DeveloperMsg; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	s := versionStore(t)
+	if err := s.Create(nil, &versionEntity{ID: "1", Name: "foo", Version: 1}); err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	c := store.NewConds().Add("id", "1")
+
+	// stored version matches
+	e := &versionEntity{ID: "1", Name: "bar", Version: 1}
+	vc, err := store.CheckVersion(s, c, e)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err.Error())
+	}
+	if want, have := uint32(2), e.Version; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	cs := vc.GetAll()
+	if want, have := 2, len(cs); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := (store.Cond{Prop: "version", Value: int64(1)}), cs[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// stored version differs
+	_, err = store.CheckVersion(s, c, &versionEntity{ID: "1", Version: 3})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if want, have := http.StatusConflict, store.ExpandError(err).Status; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// nothing to update, or no version field
+	if vc, err := store.CheckVersion(s, store.NewConds().Add("id", "2"), &versionEntity{}); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	} else if want, have := 1, len(vc.GetAll()); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if vc, err := store.CheckVersion(bulkStore(t), c, &bulkEntity{}); err != nil {
+		t.Errorf("unexpected error: %#v", err.Error())
+	} else if want, have := c, vc; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}